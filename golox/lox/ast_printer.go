@@ -0,0 +1,24 @@
+package lox
+
+import "strings"
+
+// AstPrinter prints AST as parenthesized lisp-like strings, useful for
+// debugging parser precedence issues
+type AstPrinter struct{}
+
+func NewAstPrinter() *AstPrinter {
+	return &AstPrinter{}
+}
+
+func (p *AstPrinter) PrintExpr(expr Expr) string {
+	return expr.Print()
+}
+
+// one top level statement per line
+func (p *AstPrinter) PrintStmts(stmts []Stmt) string {
+	lines := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		lines = append(lines, stmt.Print())
+	}
+	return strings.Join(lines, "\n")
+}
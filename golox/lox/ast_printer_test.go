@@ -0,0 +1,73 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAstPrinterExpr(t *testing.T) {
+	cases := map[string]string{
+		"-1 * (2 + 3)":                      "(* (- 1) (group (+ 2 3)))",
+		"1 + 2 * 3 - 4":                     "(- (+ 1 (* 2 3)) 4)",
+		"-a ** b ** c * d":                  "(* (- (** a (** b c))) d)",
+		"a = b = c":                         "(assign a (assign b c))",
+		"!a or b and c":                     "(or (! a) (and b c))",
+		"a ? b : c ? d : e":                 "(?: a b (?: c d e))",
+		`f(1, "a")(nil)`:                    `((f 1 "a") <nil>)`,
+		"a.b.c = d[0]":                      "(set c (get b a) (index d 0))",
+		"[1, {true: false}]":                "(list 1 (map true false))",
+		"func (a, b) { return a; }":         "(func (a b) (return a))",
+		"func (a, b...) { return b; }":      "(func (a b...) (return b))",
+		"func (a, b = a + 1) { return b; }": "(func (a b=(+ a 1)) (return b))",
+		`"a ${b} c ${d + 1}"`:               `(interpolate "a " b " c " (+ d 1))`,
+	}
+
+	printer := NewAstPrinter()
+	for source, expected := range cases {
+		tokens, err := NewScanner().Scan(source)
+		assert.Nil(t, err)
+		parser := NewParser()
+		parser.reset(tokens)
+		assert.Equal(t, expected, printer.PrintExpr(parser.Expression()), source)
+	}
+}
+
+func TestAstPrinterStmts(t *testing.T) {
+	ast, err := New().PrintAst(`
+var a = 1;
+var b;
+print a;
+{
+  a;
+}
+if (a) print a; else print b;
+while (true) break;
+for (; a < 10;) continue;
+func add(a, b) {
+  return a + b;
+}
+class B < A {
+  init() {
+    return;
+  }
+  size {
+    return 1;
+  }
+  class make() {}
+}
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, `(var a 1)
+(var b)
+(print a)
+(block (; a))
+(if a (print a) (print b))
+(while true (break))
+(for _ (< a 10) _ (continue))
+(func add (a b) (return (+ a b)))
+(class B < A (func init () (return)) (getter size (return 1)) (class (func make ())))`, ast)
+
+	_, err = New().PrintAst("var;")
+	assert.EqualError(t, err, "parse error: line 1, col 4, at ';', expect variable name")
+}
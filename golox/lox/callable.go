@@ -0,0 +1,157 @@
+package lox
+
+type Callable interface {
+	Call(in *Interpreter, arguments []Val) Val
+	// number of arguments should be in [min, max], max is -1 if there is
+	// no upper limit
+	Arity() (min, max int)
+}
+
+// native function
+type Function struct {
+	name     string
+	min, max int
+	function func(*Interpreter, []Val) Val
+}
+
+func NewFunction(name string, arity int, function func(*Interpreter, []Val) Val) *Function {
+	return &Function{name, arity, arity, function}
+}
+
+// accepts any number of arguments but at least min
+func NewVariadicFunction(name string, min int, function func(*Interpreter, []Val) Val) *Function {
+	return &Function{name, min, -1, function}
+}
+
+func (f *Function) String() string {
+	return "<native fn " + f.name + ">"
+}
+
+func (f *Function) Arity() (int, int) {
+	return f.min, f.max
+}
+
+func (f *Function) Call(in *Interpreter, arguments []Val) Val {
+	return f.function(in, arguments)
+}
+
+/*----------  Lox Function  ----------*/
+
+// every evaluation of a function declaration creates a new function value,
+// so closures created by the same declaration don't share env
+func (s *StmtFuncDecl) withClosure(env *Env) *StmtFuncDecl {
+	fn := *s
+	fn.closure = env
+	return &fn
+}
+
+// bind `this` to instance in a new env enclosing the method's closure
+func (s *StmtFuncDecl) bind(instance *LoxInstance) *StmtFuncDecl {
+	env := NewEnv(s.closure)
+	env.DefineSlot(0, "this", instance)
+	return s.withClosure(env)
+}
+
+func (s *StmtFuncDecl) String() string {
+	if s.name == nil {
+		return "<fn>"
+	}
+	return "<fn " + s.name.lexeme + ">"
+}
+
+// parameters with default value are optional
+func (s *StmtFuncDecl) Arity() (int, int) {
+	n := len(s.parameters)
+	if s.variadic {
+		n--
+	}
+	required := 0
+	for required < n && s.defaults[required] == nil {
+		required++
+	}
+	if s.variadic {
+		return required, -1
+	}
+	return required, n
+}
+
+// a self-recursive tail call reruns the body in a loop rather than growing
+// the Go stack, other tail calls are made as usual
+func (s *StmtFuncDecl) Call(in *Interpreter, arguments []Val) Val {
+	for {
+		result, tail := s.execute(in, arguments)
+		if tail == nil {
+			return result
+		}
+		if tail.function.declaration != s.declaration {
+			return tail.expr.call(in, tail.function, tail.arguments)
+		}
+		s, arguments = tail.function, tail.arguments
+	}
+}
+
+// run body once, tail is non-nil if it ends with a tail call
+func (s *StmtFuncDecl) execute(in *Interpreter, arguments []Val) (result Val, tail *TailCall) {
+	newEnv := NewEnv(s.closure)
+	n := len(s.parameters)
+	if s.variadic {
+		n--
+	}
+	for i, param := range s.parameters[:n] {
+		var val Val
+		if i < len(arguments) {
+			val = arguments[i]
+		} else {
+			// evaluated every call, earlier parameters are visible
+			val = in.evaluateIn(s.defaults[i], newEnv)
+		}
+		newEnv.DefineSlot(i, param.lexeme, val)
+	}
+	if s.variadic {
+		// the last parameter collects remaining arguments
+		rest := []Val{}
+		if len(arguments) > n {
+			rest = append(rest, arguments[n:]...)
+		}
+		newEnv.DefineSlot(n, s.parameters[n].lexeme, NewLoxList(rest))
+	}
+
+	// deferred calls run last, after return value is known, errors
+	// included
+	defer in.runDeferred(newEnv)
+	// handle function return
+	defer func() {
+		if err := recover(); err != nil {
+			switch err := err.(type) {
+			case *FunctionReturn:
+				result = err.value
+			case *TailCall:
+				// the call is part of returning, it's made before deferred calls
+				if len(newEnv.deferred) > 0 {
+					result = err.expr.call(in, err.function, err.arguments)
+				} else {
+					tail = err
+				}
+			default:
+				panic(err)
+			}
+		}
+	}()
+
+	in.executeBlock(s.body, newEnv)
+
+	return nil, nil
+}
+
+// the latest deferred call runs first, the rest still run if one raises an
+// error, like deferred functions in Go
+func (in *Interpreter) runDeferred(env *Env) {
+	last := len(env.deferred) - 1
+	if last < 0 {
+		return
+	}
+	call := env.deferred[last]
+	env.deferred = env.deferred[:last]
+	defer in.runDeferred(env)
+	call()
+}
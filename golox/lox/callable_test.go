@@ -0,0 +1,382 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLambda(t *testing.T) {
+	t.Run("assign to variable", func(t *testing.T) {
+		lox := evalSource(t, `
+var add = func(a, b) {
+  return a + b;
+};
+var result = add(1, 2);
+`)
+		assert.Equal(t, Number(3), getGlobal(lox, "result"))
+		assert.Equal(t, "<fn>", stringify(getGlobal(lox, "add")))
+	})
+
+	t.Run("pass as argument", func(t *testing.T) {
+		lox := evalSource(t, `
+func apply(f, x) {
+  return f(x);
+}
+var base = 10;
+var result = apply(func(x) { return x + base; }, 5);
+`)
+		assert.Equal(t, Number(15), getGlobal(lox, "result"))
+	})
+
+	t.Run("closure", func(t *testing.T) {
+		lox := evalSource(t, `
+func makeCounter() {
+  var count = 0;
+  return func() {
+    count = count + 1;
+    return count;
+  };
+}
+var a = makeCounter();
+var b = makeCounter();
+a();
+a();
+var ra = a();
+var rb = b();
+`)
+		assert.Equal(t, Number(3), getGlobal(lox, "ra"))
+		assert.Equal(t, Number(1), getGlobal(lox, "rb"))
+	})
+
+	t.Run("named", func(t *testing.T) {
+		lox := New(WarnUnused(true))
+		assert.Nil(t, lox.Run(`
+var factorial = func fact(n) {
+  if (n <= 1) return 1;
+  return n * fact(n - 1);
+};
+var result = factorial(5);
+var name = string(factorial);
+var local;
+{
+  var f = func fact(n) { return n; };
+  // a parameter shadows the name
+  var shadowed = func fact(fact) { return fact; };
+  local = shadowed(7) + f(1);
+}
+// reassigning the outer variable doesn't break recursion
+var count = func countdown(n) { return n == 0 ? "done" : countdown(n - 1); };
+var saved = count;
+count = nil;
+var counted = saved(3);
+`))
+		assert.Equal(t, Number(120), getGlobal(lox, "result"))
+		assert.Equal(t, "<fn fact>", getGlobal(lox, "name"))
+		assert.Equal(t, "done", getGlobal(lox, "counted"))
+		assert.Equal(t, Number(8), getGlobal(lox, "local"))
+		// the name is only visible inside the function
+		_, ok := lox.GetGlobal("fact")
+		assert.False(t, ok)
+		assert.Empty(t, lox.Warnings())
+
+		err := New().Run("var f = func g() {};\ng();")
+		assert.EqualError(t, err, "runtime error: line 2, col 1, undefined variable 'g'")
+	})
+}
+
+func TestVariadic(t *testing.T) {
+	lox := evalSource(t, `
+func count(first, rest...) {
+  return len(rest);
+}
+var zero = count(1);
+var one = count(1, 2);
+var many = count(1, 2, 3, 4);
+
+func sum(numbers...) {
+  var total = 0;
+  for (var i = 0; i < len(numbers); i += 1) total += numbers[i];
+  return total;
+}
+var total = sum(1, 2, 3);
+var empty = sum();
+var lambda = func(a, b...) { return b; }(1, "x", "y");
+`)
+	assert.Equal(t, Number(0), getGlobal(lox, "zero"))
+	assert.Equal(t, Number(1), getGlobal(lox, "one"))
+	assert.Equal(t, Number(3), getGlobal(lox, "many"))
+	assert.Equal(t, Number(6), getGlobal(lox, "total"))
+	assert.Equal(t, Number(0), getGlobal(lox, "empty"))
+	assert.Equal(t, "[x, y]", stringify(getGlobal(lox, "lambda")))
+
+	err := New().Run(`
+func f(a, b, rest...) {}
+f(1);
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 4, expect at least 2 arguments but got 1")
+
+	err = New().Run("func f(rest..., a) {}")
+	assert.EqualError(t, err, "parse error: line 1, col 12, at '...', variadic parameter must be the last one")
+}
+
+func TestDefaultParameters(t *testing.T) {
+	lox := evalSource(t, `
+func f(a, b = 2, c = 3) {
+  return [a, b, c];
+}
+var all = f(1, 20, 30);
+var trailing = f(1, 20);
+var both = f(1);
+
+func g(a, b = a * 2, c = a + b) {
+  return [a, b, c];
+}
+var earlier = g(1);
+
+var calls = 0;
+func next() {
+  calls += 1;
+  return calls;
+}
+func h(n = next()) {
+  return n;
+}
+var first = h();
+var second = h();
+var given = h(10);
+
+func rest(a = 1, more...) {
+  return [a, more];
+}
+var restEmpty = rest();
+var restMany = rest(5, 6, 7);
+`)
+	assert.Equal(t, "[1, 20, 30]", stringify(getGlobal(lox, "all")))
+	assert.Equal(t, "[1, 20, 3]", stringify(getGlobal(lox, "trailing")))
+	assert.Equal(t, "[1, 2, 3]", stringify(getGlobal(lox, "both")))
+	assert.Equal(t, "[1, 2, 3]", stringify(getGlobal(lox, "earlier")))
+	// defaults are evaluated per call
+	assert.Equal(t, Number(1), getGlobal(lox, "first"))
+	assert.Equal(t, Number(2), getGlobal(lox, "second"))
+	assert.Equal(t, Number(10), getGlobal(lox, "given"))
+	assert.Equal(t, Number(2), getGlobal(lox, "calls"))
+	assert.Equal(t, "[1, []]", stringify(getGlobal(lox, "restEmpty")))
+	assert.Equal(t, "[5, [6, 7]]", stringify(getGlobal(lox, "restMany")))
+
+	err := New().Run(`
+func f(a, b = 1) {}
+f();
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 3, expect 1 to 2 arguments but got 0")
+
+	err = New().Run("func f(a = 1, b) {}")
+	assert.EqualError(t, err, "parse error: line 1, col 15, at 'b', parameter without default value can't follow one with default value")
+}
+
+func TestCallDepth(t *testing.T) {
+	// calls are not in tail position, self tail calls run as a loop
+	t.Run("unbounded recursion", func(t *testing.T) {
+		lox := New()
+		err := lox.Run(`
+func f(n) {
+  return 1 + f(n + 1);
+}
+f(0);
+`)
+		assert.EqualError(t, err, "runtime error: line 3, col 21, stack overflow")
+		assert.Equal(t, 0, lox.depth)
+
+		// depth is restored after the error
+		assert.Nil(t, lox.Run(`func g() { return 1; } var x = g();`))
+	})
+
+	t.Run("configurable limit", func(t *testing.T) {
+		source := `
+func down(n) {
+  if (n == 0) return 0;
+  return 1 + down(n - 1);
+}
+down(50);
+`
+		assert.Nil(t, New().Run(source))
+		assert.EqualError(t, New(MaxCallDepth(10)).Run(source),
+			"runtime error: line 4, col 24, stack overflow")
+	})
+}
+
+func TestTailCall(t *testing.T) {
+	t.Run("self recursion runs in constant stack", func(t *testing.T) {
+		lox := evalSource(t, `
+func count(n, acc) {
+  if (n == 0) return acc;
+  return count(n - 1, acc + 1);
+}
+var result = count(100000, 0);
+
+class Counter {
+  loop(n) {
+    if (n == 0) return "done";
+    return this.loop(n - 1);
+  }
+}
+var method = Counter().loop(5000);
+
+var lambda = func(n, acc = 0) {
+  if (n == 0) return acc;
+  return count(n, acc);
+};
+var other = lambda(10);
+`)
+		assert.Equal(t, Number(100000), getGlobal(lox, "result"))
+		assert.Equal(t, "done", getGlobal(lox, "method"))
+		assert.Equal(t, Number(10), getGlobal(lox, "other"))
+	})
+
+	t.Run("closures are rebound", func(t *testing.T) {
+		lox := evalSource(t, `
+func make(n) {
+  func inner(m) {
+    if (m == 0) return n;
+    return inner(m - 1);
+  }
+  return inner;
+}
+var a = make("a")(3000);
+`)
+		assert.Equal(t, "a", getGlobal(lox, "a"))
+	})
+
+	t.Run("returns inside try are not tail calls", func(t *testing.T) {
+		lox := New()
+		err := lox.Run(`
+func f(n) {
+  try {
+    return f(n + 1);
+  } finally {}
+}
+f(0);
+`)
+		assert.EqualError(t, err, "runtime error: line 4, col 19, stack overflow")
+
+		lox = evalSource(t, `
+func fail() {
+  throw "boom";
+}
+func g() {
+  try {
+    return fail();
+  } catch (e) {
+    return "caught " + e;
+  }
+}
+var caught = g();
+`)
+		assert.Equal(t, "caught boom", getGlobal(lox, "caught"))
+	})
+
+	t.Run("mutual recursion is still bounded", func(t *testing.T) {
+		err := New().Run(`
+func even(n) {
+  if (n == 0) return true;
+  return odd(n - 1);
+}
+func odd(n) {
+  if (n == 0) return false;
+  return even(n - 1);
+}
+even(100000);
+`)
+		assert.EqualError(t, err, "runtime error: line 8, col 20, stack overflow")
+	})
+
+	t.Run("arity is checked", func(t *testing.T) {
+		err := New().Run(`
+func f(n) {
+  return f();
+}
+f(1);
+`)
+		assert.EqualError(t, err, "runtime error: line 3, col 12, expect 1 arguments but got 0")
+	})
+}
+
+func TestDefer(t *testing.T) {
+	t.Run("runs on return in reverse order", func(t *testing.T) {
+		lox := evalSource(t, `
+var log = [];
+func note(s) { push(log, s); }
+func f(early) {
+  defer note("first");
+  if (early) {
+    defer note("early");
+    return "early return";
+  }
+  for (var i = 0; i < 2; i++) defer note(i);
+  note("body");
+  return "normal return";
+}
+var a = f(false);
+push(log, "|");
+var b = f(true);
+push(log, "|");
+func implicit() { defer note("implicit"); }
+implicit();
+`)
+		assert.Equal(t, "normal return", getGlobal(lox, "a"))
+		assert.Equal(t, "early return", getGlobal(lox, "b"))
+		assert.Equal(t, "[body, 1, 0, first, |, early, first, |, implicit]", stringify(getGlobal(lox, "log")))
+	})
+
+	t.Run("arguments are evaluated at defer", func(t *testing.T) {
+		lox := evalSource(t, `
+var seen;
+func record(v) { seen = v; }
+func f() {
+  var x = 1;
+  defer record(x);
+  x = 2;
+  return x;
+}
+var result = f();
+`)
+		assert.Equal(t, Number(2), getGlobal(lox, "result"))
+		assert.Equal(t, Number(1), getGlobal(lox, "seen"))
+	})
+
+	t.Run("return value is computed before deferred calls", func(t *testing.T) {
+		lox := evalSource(t, `
+var log = [];
+func note(s) { push(log, s); return s; }
+func f() {
+  defer note("deferred");
+  return note("returned");
+}
+var result = f();
+`)
+		assert.Equal(t, "returned", getGlobal(lox, "result"))
+		assert.Equal(t, "[returned, deferred]", stringify(getGlobal(lox, "log")))
+	})
+
+	t.Run("runs when an error unwinds the function", func(t *testing.T) {
+		lox := evalSource(t, `
+var log = [];
+func f() {
+  defer push(log, "cleanup");
+  throw "oops";
+}
+try { f(); } catch (e) { push(log, e); }
+`)
+		assert.Equal(t, "[cleanup, oops]", stringify(getGlobal(lox, "log")))
+	})
+
+	errors := map[string]string{
+		"defer print(1);":                  "parse error: line 1, col 1, at 'defer', can't use 'defer' outside of a function",
+		"func f() { defer 1 + 2; }":        "parse error: line 1, col 22, at '2', expect function call after 'defer'",
+		"func f() { defer f() }":           "parse error: line 1, col 22, at '}', expect ';' after deferred call",
+		"func f() { defer len(1); }\nf();": "runtime error: line 1, col 23, len expects a string, list or map",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
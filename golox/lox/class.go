@@ -0,0 +1,155 @@
+package lox
+
+import "math"
+
+/*----------  Class  ----------*/
+
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	methods    map[string]*StmtFuncDecl
+	// methods declared with `class`, inherited by subclasses
+	classMethods map[string]*StmtFuncDecl
+	// results of findMethod, nil for missing methods
+	cache map[string]*StmtFuncDecl
+	// interpreter the class is declared in, which runs `toString` when an
+	// instance is stringified
+	in *Interpreter
+}
+
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*StmtFuncDecl) *LoxClass {
+	return &LoxClass{name, superclass, methods, map[string]*StmtFuncDecl{}, map[string]*StmtFuncDecl{}, nil}
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
+
+// look up method along the superclass chain, the hierarchy can't change
+// after declaration so results are cached permanently
+func (c *LoxClass) findMethod(name string) *StmtFuncDecl {
+	if method, ok := c.cache[name]; ok {
+		return method
+	}
+	method, ok := c.methods[name]
+	if !ok && c.superclass != nil {
+		method = c.superclass.findMethod(name)
+	}
+	c.cache[name] = method
+	return method
+}
+
+// look up class method along the superclass chain
+func (c *LoxClass) findClassMethod(name string) *StmtFuncDecl {
+	if method, ok := c.classMethods[name]; ok {
+		return method
+	}
+	if c.superclass != nil {
+		return c.superclass.findClassMethod(name)
+	}
+	return nil
+}
+
+func (c *LoxClass) Get(in *Interpreter, name *Token) Val {
+	if method := c.findClassMethod(name.lexeme); method != nil {
+		return in.access(name, method)
+	}
+	panic(NewRuntimeError(name, sprintf("undefined property '%s'", name.lexeme)))
+}
+
+// whether c is ancestor or one of its subclasses
+func (c *LoxClass) inherits(ancestor *LoxClass) bool {
+	for class := c; class != nil; class = class.superclass {
+		if class == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// arity of a class is the arity of its initializer
+func (c *LoxClass) Arity() (int, int) {
+	if initializer := c.findMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0, 0
+}
+
+func (c *LoxClass) Call(in *Interpreter, arguments []Val) Val {
+	instance := NewLoxInstance(c)
+	if initializer := c.findMethod("init"); initializer != nil {
+		initializer.bind(instance).Call(in, arguments)
+	}
+	return instance
+}
+
+/*----------  Instance  ----------*/
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]Val
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{class, map[string]Val{}}
+}
+
+func (i *LoxInstance) String() string {
+	return i.class.name + " instance"
+}
+
+// result of `toString` if the class defines it, which must be a string
+func (i *LoxInstance) format() string {
+	method := i.class.findMethod("toString")
+	if method == nil || i.class.in == nil {
+		return i.String()
+	}
+	if min, _ := method.Arity(); min > 0 {
+		panic(NewRuntimeError(method.name, "method 'toString' must take no arguments"))
+	}
+	s, ok := i.callMethod(method, nil).(string)
+	if !ok {
+		panic(NewRuntimeError(method.name, "'toString' must return a string"))
+	}
+	return s
+}
+
+// result of `hash`, used by maps to find instance keys, instances equal by
+// `equals` must have the same hash
+func (i *LoxInstance) hash() Number {
+	method := i.class.findMethod("hash")
+	n, ok := i.callMethod(method, nil).(Number)
+	if !ok || math.IsNaN(float64(n)) {
+		panic(NewRuntimeError(method.name, "'hash' must return a number other than NaN"))
+	}
+	return n
+}
+
+func (i *LoxInstance) equals(other Val) Val {
+	return i.callMethod(i.class.findMethod("equals"), []Val{other})
+}
+
+// method is called with the interpreter the class is declared in
+func (i *LoxInstance) callMethod(method *StmtFuncDecl, arguments []Val) Val {
+	in := i.class.in
+	in.enterCall(method.name)
+	defer func() { in.depth-- }()
+	return method.bind(i).Call(in, arguments)
+}
+
+// fields shadow methods, getters are called instead of returned
+func (i *LoxInstance) Get(in *Interpreter, name *Token) Val {
+	if val, ok := i.fields[name.lexeme]; ok {
+		return val
+	}
+
+	if method := i.class.findMethod(name.lexeme); method != nil {
+		return in.access(name, method.bind(i))
+	}
+
+	panic(NewRuntimeError(name, sprintf("undefined property '%s'", name.lexeme)))
+}
+
+func (i *LoxInstance) Set(name *Token, val Val) {
+	i.fields[name.lexeme] = val
+}
@@ -0,0 +1,491 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassInstantiation(t *testing.T) {
+	lox := evalSource(t, `
+class Foo {}
+var foo = Foo();
+`)
+	class, ok := getGlobal(lox, "Foo").(*LoxClass)
+	assert.True(t, ok)
+	assert.Equal(t, "Foo", class.String())
+	min, max := class.Arity()
+	assert.Equal(t, []int{0, 0}, []int{min, max})
+
+	instance, ok := getGlobal(lox, "foo").(*LoxInstance)
+	assert.True(t, ok)
+	assert.Equal(t, class, instance.class)
+	assert.Equal(t, "Foo instance", instance.String())
+}
+
+func TestClassArity(t *testing.T) {
+	lox := evalSource(t, `
+class Point {
+  init(x, y) {}
+}
+`)
+	min, max := getGlobal(lox, "Point").(*LoxClass).Arity()
+	assert.Equal(t, []int{2, 2}, []int{min, max})
+
+	err := New().Run(`
+class Point {
+  init(x, y) {}
+}
+Point(1);
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 8, expect 2 arguments but got 1")
+}
+
+func TestInstanceFields(t *testing.T) {
+	lox := evalSource(t, `
+class Foo {}
+var foo = Foo();
+foo.a = 1;
+foo.b = foo.a + 1;
+var result = foo.b;
+`)
+	assert.Equal(t, Number(2), getGlobal(lox, "result"))
+
+	err := New().Run(`
+class Foo {}
+Foo().bar;
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 7, undefined property 'bar'")
+
+	err = New().Run(`
+var a = 1;
+a.b = 2;
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 3, only instances have fields")
+}
+
+func TestInstanceMethodLookup(t *testing.T) {
+	lox := evalSource(t, `
+class Foo {
+  bar() {
+    return "method";
+  }
+}
+var foo = Foo();
+var method = foo.bar();
+foo.bar = "field";
+var field = foo.bar;
+var other = Foo().bar();
+`)
+	assert.Equal(t, "method", getGlobal(lox, "method"))
+	assert.Equal(t, "field", getGlobal(lox, "field"))
+	assert.Equal(t, "method", getGlobal(lox, "other"))
+}
+
+func TestMethodThis(t *testing.T) {
+	lox := evalSource(t, `
+class Counter {
+  init(x) {
+    this.x = x;
+  }
+
+  incr() {
+    this.x = this.x + 1;
+    return this.x;
+  }
+}
+
+var a = Counter(0);
+var b = Counter(10);
+a.incr();
+a.incr();
+b.incr();
+
+var incr = a.incr;
+incr();
+
+var ax = a.x;
+var bx = b.x;
+`)
+	assert.Equal(t, Number(3), getGlobal(lox, "ax"))
+	assert.Equal(t, Number(11), getGlobal(lox, "bx"))
+}
+
+func TestInheritance(t *testing.T) {
+	lox := evalSource(t, `
+class A {
+  name() {
+    return "A";
+  }
+
+  greet() {
+    return "hello " + this.name();
+  }
+}
+
+class B < A {
+  name() {
+    return "B";
+  }
+}
+
+class C < B {
+  greet() {
+    return super.greet() + "!";
+  }
+}
+
+var a = A().greet();
+var b = B().greet();
+var c = C().greet();
+`)
+	assert.Equal(t, "hello A", getGlobal(lox, "a"))
+	assert.Equal(t, "hello B", getGlobal(lox, "b"))
+	assert.Equal(t, "hello B!", getGlobal(lox, "c"))
+}
+
+func TestSuperInit(t *testing.T) {
+	lox := evalSource(t, `
+class A {
+  init(a) {
+    this.a = a;
+  }
+}
+
+class B < A {
+  init(a, b) {
+    super.init(a);
+    this.b = b;
+  }
+}
+
+class C < B {
+  init() {
+    super.init(1, 2);
+    this.c = 3;
+  }
+}
+
+var c = C();
+var sum = c.a + c.b + c.c;
+`)
+	assert.Equal(t, Number(6), getGlobal(lox, "sum"))
+}
+
+func TestSuperclassError(t *testing.T) {
+	err := New().Run(`
+var A = "not a class";
+class B < A {}
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 11, superclass must be a class")
+
+	err = New().Run(`
+class A {}
+class B < A {
+  foo() {
+    return super.foo();
+  }
+}
+B().foo();
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 18, undefined property 'foo'")
+}
+
+func TestGetter(t *testing.T) {
+	lox := evalSource(t, `
+class Rect {
+  init(w, h) {
+    this.w = w;
+    this.h = h;
+  }
+  area {
+    return this.w * this.h;
+  }
+  scaled(k) {
+    return this.w * this.h * k;
+  }
+}
+
+var r = Rect(2, 3);
+var area = r.area;
+var method = r.scaled;
+var scaled = r.scaled(2);
+r.w = 4;
+var updated = r.area;
+`)
+	assert.Equal(t, Number(6), getGlobal(lox, "area"))
+	_, ok := getGlobal(lox, "method").(*StmtFuncDecl)
+	assert.True(t, ok)
+	assert.Equal(t, Number(12), getGlobal(lox, "scaled"))
+	assert.Equal(t, Number(12), getGlobal(lox, "updated"))
+
+	lox = evalSource(t, `
+class A {
+  name { return "A"; }
+}
+class B < A {
+  name { return super.name + "B"; }
+}
+var name = B().name;
+`)
+	assert.Equal(t, "AB", getGlobal(lox, "name"))
+
+	err := New().Run(`
+class A {
+  area { return 1; }
+}
+A().area();
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 10, can only call functions and classes")
+
+	err = New().Run(`
+class A {
+  init { }
+}
+`)
+	assert.EqualError(t, err, "parse error: line 3, col 3, at 'init', initializer can't be a getter")
+
+	err = New(MaxCallDepth(10)).Run(`
+class A {
+  loop { return this.loop; }
+}
+A().loop;
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 22, stack overflow")
+}
+
+func TestClassMethods(t *testing.T) {
+	lox := evalSource(t, `
+class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  class origin() {
+    return Point(0, 0);
+  }
+  class max(a, b) {
+    return a > b ? a : b;
+  }
+}
+class Point3 < Point {}
+
+var origin = Point.origin();
+var max = Point3.max(1, 2);
+`)
+	origin := getGlobal(lox, "origin").(*LoxInstance)
+	assert.Equal(t, Number(0), origin.fields["x"])
+	assert.Equal(t, Number(2), getGlobal(lox, "max"))
+
+	err := New().Run(`
+class Point {
+  class origin() {}
+}
+Point().origin();
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 9, undefined property 'origin'")
+
+	err = New().Run(`
+class Point {
+  class origin() {
+    return this;
+  }
+}
+`)
+	assert.EqualError(t, err, "resolve error: line 4, col 12, at 'this', can't use 'this' outside of a method")
+
+	err = New().Run(`
+class A {}
+class B < A {
+  class make() {
+    return super.make();
+  }
+}
+`)
+	assert.EqualError(t, err, "resolve error: line 5, col 12, at 'super', can't use 'super' outside of a subclass method")
+}
+
+func TestMethodCache(t *testing.T) {
+	lox := evalSource(t, `
+class A {
+  foo() { return "A.foo"; }
+  bar() { return "A.bar"; }
+}
+class B < A {
+  bar() { return "B.bar"; }
+}
+var b = B();
+var results = [];
+for (var i = 0; i < 2; i++) {
+  push(results, b.foo());
+  push(results, b.bar());
+  push(results, A().bar());
+}
+`)
+	assert.Equal(t, "[A.foo, B.bar, A.bar, A.foo, B.bar, A.bar]", stringify(getGlobal(lox, "results")))
+
+	a, b := getGlobal(lox, "A").(*LoxClass), getGlobal(lox, "B").(*LoxClass)
+	assert.Equal(t, a.methods["foo"], b.cache["foo"])
+	assert.Equal(t, b.methods["bar"], b.cache["bar"])
+	assert.Equal(t, a.methods["bar"], a.cache["bar"])
+
+	err := New().Run(`
+class A {}
+var a = A();
+for (var i = 0; i < 2; i++) {
+  a.missing;
+}
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 5, undefined property 'missing'")
+}
+
+func TestIsa(t *testing.T) {
+	lox := evalSource(t, `
+class Animal {}
+class Dog < Animal {}
+class Puppy < Dog {}
+class Cat < Animal {}
+var d = Dog();
+
+var exact = d isa Dog;
+var parent = d isa Animal;
+var grandparent = Puppy() isa Animal;
+var sibling = d isa Cat;
+var child = d isa Puppy;
+var subclass = Dog isa Animal;
+var number = 1 isa Animal;
+var none = nil isa Animal;
+var negated = !(d isa Cat) and d isa Dog == true;
+`)
+	assert.Equal(t, true, getGlobal(lox, "exact"))
+	assert.Equal(t, true, getGlobal(lox, "parent"))
+	assert.Equal(t, true, getGlobal(lox, "grandparent"))
+	assert.Equal(t, false, getGlobal(lox, "sibling"))
+	assert.Equal(t, false, getGlobal(lox, "child"))
+	// classes themselves aren't instances
+	assert.Equal(t, false, getGlobal(lox, "subclass"))
+	assert.Equal(t, false, getGlobal(lox, "number"))
+	assert.Equal(t, false, getGlobal(lox, "none"))
+	assert.Equal(t, true, getGlobal(lox, "negated"))
+
+	err := New().Run("class A {}\nvar a = A();\nprint a isa a;")
+	assert.EqualError(t, err, "runtime error: line 3, col 9, right operand of 'isa' must be a class")
+	err = New().Run("class A {}\nprint A() isa A isa A;")
+	assert.EqualError(t, err, "parse error: line 2, col 17, at 'isa', comparisons can't be chained, use 'a < b and b < c' instead")
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	lox := evalSource(t, `
+class Vec {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  add(other) { return Vec(this.x + other.x, this.y + other.y); }
+  sub(other) { return Vec(this.x - other.x, this.y - other.y); }
+  mul(k) { return Vec(this.x * k, this.y * k); }
+  equals(other) { return other isa Vec and this.x == other.x and this.y == other.y; }
+  lessThan(other) { return this.x * this.x + this.y * this.y < other.x * other.x + other.y * other.y; }
+  toString() { return "(" + string(this.x) + ", " + string(this.y) + ")"; }
+}
+class Vec3 < Vec {}
+var sum = Vec(1, 2) + Vec(3, 4);
+var difference = (Vec(5, 5) - Vec(1, 2)).toString();
+var scaled = (Vec(1, 2) * 3).toString();
+var equal = sum == Vec(4, 6);
+var notEqual = sum != Vec(4, 6);
+var otherType = sum == 1;
+var less = Vec(1, 1) < Vec(2, 2);
+var inherited = Vec3(1, 1) + Vec(1, 1) == Vec(2, 2);
+var v = Vec(0, 1);
+v += Vec(1, 0);
+var compound = v.toString();
+
+class Plain {}
+var p = Plain();
+var identity = p == p and p != Plain();
+var mixed = 1 == Vec(1, 1);
+`)
+	sum := getGlobal(lox, "sum").(*LoxInstance)
+	assert.Equal(t, Number(4), sum.fields["x"])
+	assert.Equal(t, Number(6), sum.fields["y"])
+	assert.Equal(t, "(4, 3)", getGlobal(lox, "difference"))
+	assert.Equal(t, "(3, 6)", getGlobal(lox, "scaled"))
+	assert.Equal(t, true, getGlobal(lox, "equal"))
+	assert.Equal(t, false, getGlobal(lox, "notEqual"))
+	assert.Equal(t, false, getGlobal(lox, "otherType"))
+	assert.Equal(t, true, getGlobal(lox, "less"))
+	assert.Equal(t, true, getGlobal(lox, "inherited"))
+	assert.Equal(t, "(1, 1)", getGlobal(lox, "compound"))
+	// without `equals` instances are compared by identity
+	assert.Equal(t, true, getGlobal(lox, "identity"))
+	// only the left operand dispatches
+	assert.Equal(t, false, getGlobal(lox, "mixed"))
+
+	errors := map[string]string{
+		"class A {}\nprint A() + 1;":                       "runtime error: line 2, col 11, A instance must have method 'add' to use '+'",
+		"class A {}\nprint A() < A();":                     "runtime error: line 2, col 11, A instance must have method 'lessThan' to use '<'",
+		"class A { add() { return 1; } }\nA() + 1;":        "runtime error: line 2, col 5, method 'add' must take one argument",
+		"class A { add(a) { return 1; } }\nprint 1 + A();": "runtime error: line 2, col 9, operands must be two numbers or two strings",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestToString(t *testing.T) {
+	var buf bytes.Buffer
+	lox := New()
+	lox.SetOutput(&buf)
+	assert.Nil(t, lox.Run(`
+class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  toString() { return "Point(${this.x}, ${this.y})"; }
+}
+class Point3 < Point {}
+class Plain {}
+print Point(1, 2);
+print [Point(3, 4), {"p": Point3(5, 6)}];
+print Plain();
+var s = string(Point(0, 0)) + "!";
+var interpolated = "at ${Point(7, 8)}";
+println(Plain());
+`))
+	assert.Equal(t, "Point(1, 2)\n[Point(3, 4), {p: Point(5, 6)}]\nPlain instance\nPlain instance\n", buf.String())
+	assert.Equal(t, "Point(0, 0)!", getGlobal(lox, "s"))
+	assert.Equal(t, "at Point(7, 8)", getGlobal(lox, "interpolated"))
+
+	errors := map[string]string{
+		"class A { toString() { return 1; } }\nprint A();":            "runtime error: line 1, col 11, 'toString' must return a string",
+		"class A { toString() { return nil; } }\nthrow A();":          "runtime error: line 1, col 11, 'toString' must return a string",
+		"class A { toString(a) { return a; } }\nprint A();":           "runtime error: line 1, col 11, method 'toString' must take no arguments",
+		"class A { toString() { return string(this); } }\nprint A();": "runtime error: line 1, col 11, stack overflow",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func BenchmarkMethodCall(b *testing.B) {
+	source := `
+class A { foo() { return 1; } }
+class B < A {}
+class C < B {}
+class D < C {}
+var d = D();
+var sum = 0;
+for (var i = 0; i < 1000; i++) {
+  sum = sum + d.foo();
+}
+`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := New().Run(source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,637 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compiler translates a resolved program into bytecode run by VM, an
+// alternative backend to walking the AST. Only literals, operators, calls,
+// variables and control flow are supported for now, other nodes are
+// reported as `*CompileError`.
+type Compiler struct {
+	chunk *Chunk
+
+	// locals in declaration order, the index is the stack slot
+	locals     []local
+	scopeDepth int
+	// innermost loop is the last one
+	loops []*loop
+}
+
+type local struct {
+	name  string
+	depth int
+}
+
+// jumps out of a loop are patched when its end is compiled
+type loop struct {
+	label     string
+	breaks    []int
+	continues []int
+	// number of locals outside the loop, the others are popped on jump
+	locals int
+}
+
+func NewCompiler() *Compiler {
+	return &Compiler{}
+}
+
+func (c *Compiler) Compile(stmts []Stmt) (chunk *Chunk, err error) {
+	c.chunk = &Chunk{}
+	c.locals = nil
+	c.scopeDepth = 0
+	c.loops = nil
+	defer func() {
+		if e := recover(); e != nil {
+			if ce, ok := e.(*CompileError); ok {
+				err = ce
+			} else {
+				panic(e)
+			}
+		}
+	}()
+	for _, stmt := range stmts {
+		stmt.compile(c)
+	}
+	return c.chunk, nil
+}
+
+type CompileError struct {
+	pos Position
+	msg string
+}
+
+func NewCompileError(pos Position, msg string) *CompileError {
+	return &CompileError{pos, msg}
+}
+
+func (ce *CompileError) Error() string {
+	return fmt.Sprintf("line %d, col %d, %s", ce.pos.Line, ce.pos.Column, ce.msg)
+}
+
+func (ce *CompileError) Position() (line, column int) {
+	return ce.pos.Line, ce.pos.Column
+}
+
+func (ce *CompileError) message() string {
+	return ce.msg
+}
+
+/*----------  Bytecode  ----------*/
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpDup
+	OpDefineGlobal
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpNegate
+	OpNot
+	OpIncrement
+	OpDecrement
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+	OpPower
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpEqual
+	OpNotEqual
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpIsa
+	OpJump
+	OpJumpIfFalse
+	OpJumpIfNotNil
+	OpCall
+	OpInterpolate
+	OpPrint
+)
+
+var opNames = [...]string{
+	OpConstant:     "OpConstant",
+	OpNil:          "OpNil",
+	OpTrue:         "OpTrue",
+	OpFalse:        "OpFalse",
+	OpPop:          "OpPop",
+	OpDup:          "OpDup",
+	OpDefineGlobal: "OpDefineGlobal",
+	OpGetGlobal:    "OpGetGlobal",
+	OpSetGlobal:    "OpSetGlobal",
+	OpGetLocal:     "OpGetLocal",
+	OpSetLocal:     "OpSetLocal",
+	OpNegate:       "OpNegate",
+	OpNot:          "OpNot",
+	OpIncrement:    "OpIncrement",
+	OpDecrement:    "OpDecrement",
+	OpAdd:          "OpAdd",
+	OpSubtract:     "OpSubtract",
+	OpMultiply:     "OpMultiply",
+	OpDivide:       "OpDivide",
+	OpModulo:       "OpModulo",
+	OpPower:        "OpPower",
+	OpGreater:      "OpGreater",
+	OpGreaterEqual: "OpGreaterEqual",
+	OpLess:         "OpLess",
+	OpLessEqual:    "OpLessEqual",
+	OpEqual:        "OpEqual",
+	OpNotEqual:     "OpNotEqual",
+	OpBitAnd:       "OpBitAnd",
+	OpBitOr:        "OpBitOr",
+	OpBitXor:       "OpBitXor",
+	OpShiftLeft:    "OpShiftLeft",
+	OpShiftRight:   "OpShiftRight",
+	OpIsa:          "OpIsa",
+	OpJump:         "OpJump",
+	OpJumpIfFalse:  "OpJumpIfFalse",
+	OpJumpIfNotNil: "OpJumpIfNotNil",
+	OpCall:         "OpCall",
+	OpInterpolate:  "OpInterpolate",
+	OpPrint:        "OpPrint",
+}
+
+func (op OpCode) String() string {
+	return opNames[op]
+}
+
+// binary operators are evaluated by `binary`, the op code is informative
+var binaryOps = map[TokenType]OpCode{
+	PLUS:            OpAdd,
+	MINUS:           OpSubtract,
+	STAR:            OpMultiply,
+	SLASH:           OpDivide,
+	PERCENT:         OpModulo,
+	STAR_STAR:       OpPower,
+	GREATER:         OpGreater,
+	GREATER_EQUAL:   OpGreaterEqual,
+	LESS:            OpLess,
+	LESS_EQUAL:      OpLessEqual,
+	EQUAL_EQUAL:     OpEqual,
+	BANG_EQUAL:      OpNotEqual,
+	AMPERSAND:       OpBitAnd,
+	PIPE:            OpBitOr,
+	CARET:           OpBitXor,
+	LESS_LESS:       OpShiftLeft,
+	GREATER_GREATER: OpShiftRight,
+	ISA:             OpIsa,
+}
+
+// operand is an index into constants for OpConstant, a stack slot for
+// locals, a target index for jumps, an index into calls for OpCall and the
+// number of parts for OpInterpolate
+type Instruction struct {
+	op      OpCode
+	operand int
+	// locates runtime errors, names the variable of global access
+	token *Token
+}
+
+type Chunk struct {
+	code      []Instruction
+	constants []Val
+	calls     []*ExprCall
+}
+
+// one instruction per line, prefixed with its index
+func (ch *Chunk) Disassemble() string {
+	lines := make([]string, 0, len(ch.code))
+	for i, ins := range ch.code {
+		line := fmt.Sprintf("%04d %s", i, ins.op)
+		switch ins.op {
+		case OpConstant:
+			line += fmt.Sprintf(" %d (%s)", ins.operand, stringify(ch.constants[ins.operand]))
+		case OpDefineGlobal, OpGetGlobal, OpSetGlobal:
+			line += " " + ins.token.lexeme
+		case OpGetLocal, OpSetLocal, OpJump, OpJumpIfFalse, OpJumpIfNotNil, OpInterpolate:
+			line += fmt.Sprintf(" %d", ins.operand)
+		case OpCall:
+			line += fmt.Sprintf(" %d", len(ch.calls[ins.operand].arguments))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*----------  Stmt: Print  ----------*/
+
+func (s *StmtPrint) compile(c *Compiler) {
+	s.expr.compile(c)
+	c.emit(OpPrint, 0, nil)
+}
+
+/*----------  Stmt: Expression  ----------*/
+
+func (s *StmtExpression) compile(c *Compiler) {
+	s.expr.compile(c)
+	c.emit(OpPop, 0, nil)
+}
+
+/*----------  Stmt: Variable Declaration  ----------*/
+
+// a local lives in the stack slot its initial value is pushed to
+func (s *StmtVarDecl) compile(c *Compiler) {
+	if s.value != nil {
+		s.value.compile(c)
+	} else {
+		c.emit(OpNil, 0, nil)
+	}
+	if c.scopeDepth == 0 {
+		c.emit(OpDefineGlobal, 0, s.name)
+		return
+	}
+	// the resolver rejects redeclaring in the same scope
+	c.locals = append(c.locals, local{s.name.lexeme, c.scopeDepth})
+}
+
+/*----------  Stmt: Block  ----------*/
+
+func (s *StmtBlock) compile(c *Compiler) {
+	c.beginScope()
+	for _, stmt := range s.stmts {
+		stmt.compile(c)
+	}
+	c.endScope()
+}
+
+/*----------  Stmt: If  ----------*/
+
+func (s *StmtIf) compile(c *Compiler) {
+	s.condition.compile(c)
+	elseJump := c.emit(OpJumpIfFalse, 0, nil)
+	c.emit(OpPop, 0, nil)
+	s.trueBranch.compile(c)
+	endJump := c.emit(OpJump, 0, nil)
+	c.patch(elseJump)
+	c.emit(OpPop, 0, nil)
+	if s.falseBranch != nil {
+		s.falseBranch.compile(c)
+	}
+	c.patch(endJump)
+}
+
+/*----------  Stmt: While  ----------*/
+
+func (s *StmtWhile) compile(c *Compiler) {
+	start := len(c.chunk.code)
+	s.condition.compile(c)
+	exitJump := c.emit(OpJumpIfFalse, 0, nil)
+	c.emit(OpPop, 0, nil)
+
+	l := c.beginLoop(s.label)
+	s.body.compile(c)
+	c.patchAll(l.continues, start)
+	c.emit(OpJump, start, nil)
+
+	c.patch(exitJump)
+	c.emit(OpPop, 0, nil)
+	c.endLoop()
+}
+
+/*----------  Stmt: Do While  ----------*/
+
+func (s *StmtDoWhile) compile(c *Compiler) {
+	start := len(c.chunk.code)
+	l := c.beginLoop(s.label)
+	s.body.compile(c)
+	c.patchAll(l.continues, len(c.chunk.code))
+
+	s.condition.compile(c)
+	exitJump := c.emit(OpJumpIfFalse, 0, nil)
+	c.emit(OpPop, 0, nil)
+	c.emit(OpJump, start, nil)
+
+	c.patch(exitJump)
+	c.emit(OpPop, 0, nil)
+	c.endLoop()
+}
+
+/*----------  Stmt: For  ----------*/
+
+// initializer has its own scope, the same as `StmtFor.Run`
+func (s *StmtFor) compile(c *Compiler) {
+	c.beginScope()
+	if s.initializer != nil {
+		s.initializer.compile(c)
+	}
+
+	start := len(c.chunk.code)
+	exitJump := -1
+	if s.condition != nil {
+		s.condition.compile(c)
+		exitJump = c.emit(OpJumpIfFalse, 0, nil)
+		c.emit(OpPop, 0, nil)
+	}
+
+	l := c.beginLoop(s.label)
+	s.body.compile(c)
+	c.patchAll(l.continues, len(c.chunk.code))
+	if s.increment != nil {
+		s.increment.compile(c)
+		c.emit(OpPop, 0, nil)
+	}
+	c.emit(OpJump, start, nil)
+
+	if exitJump >= 0 {
+		c.patch(exitJump)
+		c.emit(OpPop, 0, nil)
+	}
+	c.endLoop()
+	c.endScope()
+}
+
+/*----------  Stmt: Break  ----------*/
+
+func (s *StmtBreak) compile(c *Compiler) {
+	l := c.findLoop(s.label)
+	l.breaks = append(l.breaks, c.jumpOut(l))
+}
+
+/*----------  Stmt: Continue  ----------*/
+
+func (s *StmtContinue) compile(c *Compiler) {
+	l := c.findLoop(s.label)
+	l.continues = append(l.continues, c.jumpOut(l))
+}
+
+/*----------  Stmt: Unsupported  ----------*/
+
+func (s *StmtAssert) compile(c *Compiler)    { c.unsupported(s, "assert") }
+func (s *StmtImport) compile(c *Compiler)    { c.unsupported(s, "import") }
+func (s *StmtConstDecl) compile(c *Compiler) { c.unsupported(s, "constants") }
+func (s *StmtVarUnpack) compile(c *Compiler) { c.unsupported(s, "destructuring") }
+func (s *StmtFuncDecl) compile(c *Compiler)  { c.unsupported(s, "functions") }
+func (s *StmtTry) compile(c *Compiler)       { c.unsupported(s, "exceptions") }
+func (s *StmtThrow) compile(c *Compiler)     { c.unsupported(s, "exceptions") }
+func (s *StmtClassDecl) compile(c *Compiler) { c.unsupported(s, "classes") }
+func (s *StmtReturn) compile(c *Compiler)    { c.unsupported(s, "return") }
+func (s *StmtDefer) compile(c *Compiler)     { c.unsupported(s, "defer") }
+func (s *StmtForEach) compile(c *Compiler)   { c.unsupported(s, "for-in loops") }
+
+/*----------  Expr: Literal  ----------*/
+
+func (expr *ExprLiteral) compile(c *Compiler) {
+	switch expr.value {
+	case nil:
+		c.emit(OpNil, 0, nil)
+	case true:
+		c.emit(OpTrue, 0, nil)
+	case false:
+		c.emit(OpFalse, 0, nil)
+	default:
+		c.chunk.constants = append(c.chunk.constants, expr.value)
+		c.emit(OpConstant, len(c.chunk.constants)-1, nil)
+	}
+}
+
+/*----------  Expr: Grouping  ----------*/
+
+func (expr *ExprGrouping) compile(c *Compiler) {
+	expr.operand.compile(c)
+}
+
+/*----------  Expr: Unary  ----------*/
+
+func (expr *ExprUnary) compile(c *Compiler) {
+	expr.operand.compile(c)
+	if expr.operator.typ == BANG {
+		c.emit(OpNot, 0, expr.operator)
+	} else {
+		c.emit(OpNegate, 0, expr.operator)
+	}
+}
+
+/*----------  Expr: Binary  ----------*/
+
+func (expr *ExprBinary) compile(c *Compiler) {
+	expr.left.compile(c)
+	expr.right.compile(c)
+	c.emit(binaryOps[expr.operator.typ], 0, expr.operator)
+}
+
+/*----------  Expr: Interpolation  ----------*/
+
+// operand is the number of parts
+func (expr *ExprInterpolation) compile(c *Compiler) {
+	for _, part := range expr.parts {
+		part.compile(c)
+	}
+	c.emit(OpInterpolate, len(expr.parts), nil)
+}
+
+/*----------  Expr: Logical  ----------*/
+
+// the left operand is kept as result if it decides the result
+func (expr *ExprLogical) compile(c *Compiler) {
+	expr.left.compile(c)
+	var endJump int
+	switch expr.operator.typ {
+	case OR:
+		rightJump := c.emit(OpJumpIfFalse, 0, nil)
+		endJump = c.emit(OpJump, 0, nil)
+		c.patch(rightJump)
+	case QUESTION_QUESTION:
+		endJump = c.emit(OpJumpIfNotNil, 0, nil)
+	default:
+		endJump = c.emit(OpJumpIfFalse, 0, nil)
+	}
+	c.emit(OpPop, 0, nil)
+	expr.right.compile(c)
+	c.patch(endJump)
+}
+
+/*----------  Expr: Ternary  ----------*/
+
+func (expr *ExprTernary) compile(c *Compiler) {
+	expr.condition.compile(c)
+	elseJump := c.emit(OpJumpIfFalse, 0, nil)
+	c.emit(OpPop, 0, nil)
+	expr.thenBranch.compile(c)
+	endJump := c.emit(OpJump, 0, nil)
+	c.patch(elseJump)
+	c.emit(OpPop, 0, nil)
+	expr.elseBranch.compile(c)
+	c.patch(endJump)
+}
+
+/*----------  Expr: Comma  ----------*/
+
+func (expr *ExprComma) compile(c *Compiler) {
+	for i, e := range expr.exprs {
+		if i > 0 {
+			c.emit(OpPop, 0, nil)
+		}
+		e.compile(c)
+	}
+}
+
+/*----------  Expr: Variable  ----------*/
+
+func (expr *ExprVariable) compile(c *Compiler) {
+	c.getVariable(expr.name)
+}
+
+/*----------  Expr: Assignment  ----------*/
+
+func (expr *ExprAssignment) compile(c *Compiler) {
+	expr.val.compile(c)
+	c.setVariable(expr.name)
+}
+
+/*----------  Expr: Update  ----------*/
+
+// only variables can be updated for now
+func (expr *ExprUpdate) compile(c *Compiler) {
+	target, ok := expr.target.(*ExprVariable)
+	if !ok {
+		c.unsupported(expr, "updating properties and elements")
+	}
+	c.getVariable(target.name)
+	if !expr.prefix {
+		c.emit(OpDup, 0, nil)
+	}
+	if expr.operator.typ == PLUS_PLUS {
+		c.emit(OpIncrement, 0, expr.operator)
+	} else {
+		c.emit(OpDecrement, 0, expr.operator)
+	}
+	c.setVariable(target.name)
+	if !expr.prefix {
+		c.emit(OpPop, 0, nil)
+	}
+}
+
+/*----------  Expr: Function Call  ----------*/
+
+func (expr *ExprCall) compile(c *Compiler) {
+	expr.callee.compile(c)
+	for _, arg := range expr.arguments {
+		arg.compile(c)
+	}
+	c.chunk.calls = append(c.chunk.calls, expr)
+	c.emit(OpCall, len(c.chunk.calls)-1, expr.paren)
+}
+
+/*----------  Expr: Unsupported  ----------*/
+
+func (expr *ExprGet) compile(c *Compiler)         { c.unsupported(expr, "properties") }
+func (expr *ExprSet) compile(c *Compiler)         { c.unsupported(expr, "properties") }
+func (expr *ExprThis) compile(c *Compiler)        { c.unsupported(expr, "classes") }
+func (expr *ExprSuper) compile(c *Compiler)       { c.unsupported(expr, "classes") }
+func (expr *ExprListLiteral) compile(c *Compiler) { c.unsupported(expr, "lists") }
+func (expr *ExprMapLiteral) compile(c *Compiler)  { c.unsupported(expr, "maps") }
+func (expr *ExprTuple) compile(c *Compiler)       { c.unsupported(expr, "tuples") }
+func (expr *ExprIndexGet) compile(c *Compiler)    { c.unsupported(expr, "indexing") }
+func (expr *ExprIndexSet) compile(c *Compiler)    { c.unsupported(expr, "indexing") }
+func (expr *ExprFunction) compile(c *Compiler)    { c.unsupported(expr, "functions") }
+func (expr *ExprBlock) compile(c *Compiler)       { c.unsupported(expr, "block expressions") }
+
+/*----------  Private Methods  ----------*/
+
+// return index of the instruction, so jumps can be patched
+func (c *Compiler) emit(op OpCode, operand int, token *Token) int {
+	c.chunk.code = append(c.chunk.code, Instruction{op, operand, token})
+	return len(c.chunk.code) - 1
+}
+
+// make the jump go to the next instruction
+func (c *Compiler) patch(jump int) {
+	c.chunk.code[jump].operand = len(c.chunk.code)
+}
+
+func (c *Compiler) patchAll(jumps []int, target int) {
+	for _, jump := range jumps {
+		c.chunk.code[jump].operand = target
+	}
+}
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+// locals of the scope are popped off the stack
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
+		c.emit(OpPop, 0, nil)
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+func (c *Compiler) beginLoop(label *Token) *loop {
+	l := &loop{label: labelName(label), locals: len(c.locals)}
+	c.loops = append(c.loops, l)
+	return l
+}
+
+// the innermost loop if label is nil, parser checked that the label exists
+func (c *Compiler) findLoop(label *Token) *loop {
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if label == nil || c.loops[i].label == label.lexeme {
+			return c.loops[i]
+		}
+	}
+	panic(sprintf("no loop labeled '%s'", label.lexeme))
+}
+
+// breaks go to the next instruction
+func (c *Compiler) endLoop() {
+	l := c.loops[len(c.loops)-1]
+	c.patchAll(l.breaks, len(c.chunk.code))
+	c.loops = c.loops[:len(c.loops)-1]
+}
+
+// pop locals declared inside the loop, return the jump to patch
+func (c *Compiler) jumpOut(l *loop) int {
+	for i := len(c.locals); i > l.locals; i-- {
+		c.emit(OpPop, 0, nil)
+	}
+	return c.emit(OpJump, 0, nil)
+}
+
+// return stack slot of the innermost local named name, -1 if it's a global
+func (c *Compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) getVariable(name *Token) {
+	if slot := c.resolveLocal(name.lexeme); slot >= 0 {
+		c.emit(OpGetLocal, slot, name)
+	} else {
+		c.emit(OpGetGlobal, 0, name)
+	}
+}
+
+// the value is left on the stack
+func (c *Compiler) setVariable(name *Token) {
+	if slot := c.resolveLocal(name.lexeme); slot >= 0 {
+		c.emit(OpSetLocal, slot, name)
+	} else {
+		c.emit(OpSetGlobal, 0, name)
+	}
+}
+
+func (c *Compiler) unsupported(node interface{ Pos() (start, end Position) }, feature string) {
+	start, _ := node.Pos()
+	panic(NewCompileError(start, sprintf("vm doesn't support %s yet", feature)))
+}
@@ -0,0 +1,168 @@
+package lox
+
+// Env keeps globals by name since they can be defined dynamically, locals
+// are kept in slots whose indexes are computed by resolver, so resolved
+// variables are accessed without hashing their names.
+type Env struct {
+	prev   *Env
+	m      map[string]Val
+	consts map[string]bool
+	slots  []binding
+	// calls made by `defer` in the function body this env belongs to, run
+	// in reverse order when the function exits
+	deferred []func()
+}
+
+// a local variable, the name is only used by `Undefine`
+type binding struct {
+	name     string
+	val      Val
+	constant bool
+	defined  bool
+}
+
+// maps are allocated on first definition by name, which is rare for
+// local envs
+func NewEnv(prev *Env) *Env {
+	return &Env{prev, nil, nil, nil, nil}
+}
+
+func (e *Env) Define(name string, val Val) {
+	if e.m == nil {
+		e.m = map[string]Val{}
+	}
+	e.m[name] = val
+	delete(e.consts, name)
+}
+
+// constants can't be reassigned, but can be redefined
+func (e *Env) DefineConst(name string, val Val) {
+	e.Define(name, val)
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.consts[name] = true
+}
+
+func (e *Env) DefineSlot(slot int, name string, val Val) {
+	e.bind(slot, binding{name, val, false, true})
+}
+
+func (e *Env) DefineConstSlot(slot int, name string, val Val) {
+	e.bind(slot, binding{name, val, true, true})
+}
+
+// slots are allocated on first definition
+func (e *Env) bind(slot int, b binding) {
+	for len(e.slots) <= slot {
+		e.slots = append(e.slots, binding{})
+	}
+	e.slots[slot] = b
+}
+
+// remove the binding from the innermost env defining it, constants included,
+// the error has no token and should be located by caller
+func (e *Env) Undefine(name string) {
+	for env := e; env != nil; env = env.prev {
+		for i, b := range env.slots {
+			if b.defined && b.name == name {
+				env.slots[i] = binding{}
+				return
+			}
+		}
+		if env.has(name) {
+			delete(env.m, name)
+			delete(env.consts, name)
+			return
+		}
+	}
+	panic(NewRuntimeError(nil, sprintf("undefined variable '%s'", name)))
+}
+
+func (e *Env) Get(name *Token) Val {
+	key := name.lexeme
+	if e.has(key) {
+		return e.m[key]
+	}
+
+	if e.prev != nil {
+		return e.prev.Get(name)
+	}
+
+	panic(NewRuntimeError(name, sprintf("undefined variable '%s'", key)))
+}
+
+func (e Env) Set(name *Token, val Val) {
+	key := name.lexeme
+
+	if e.has(key) {
+		e.assign(name, val)
+		return
+	}
+
+	if e.prev != nil {
+		e.prev.Set(name, val)
+		return
+	}
+
+	panic(NewRuntimeError(name, sprintf("undefined variable '%s'", key)))
+}
+
+// used by resolved variables, distance and slot are computed by resolver
+func (e *Env) GetAt(distance int, slot int, name *Token) Val {
+	env := e.Ancestor(distance)
+	if slot < len(env.slots) && env.slots[slot].defined {
+		return env.slots[slot].val
+	}
+	panic(NewRuntimeError(name, sprintf("undefined variable '%s'", name.lexeme)))
+}
+
+func (e *Env) SetAt(distance int, slot int, name *Token, val Val) {
+	env := e.Ancestor(distance)
+	if slot >= len(env.slots) || !env.slots[slot].defined {
+		panic(NewRuntimeError(name, sprintf("undefined variable '%s'", name.lexeme)))
+	}
+	if env.slots[slot].constant {
+		panic(NewRuntimeError(name, sprintf("cannot assign to constant '%s'", name.lexeme)))
+	}
+	env.slots[slot].val = val
+}
+
+// walk up the chain distance hops, 0 is the env itself, distance comes
+// from resolver so running out of envs is a bug in the interpreter
+func (e *Env) Ancestor(distance int) *Env {
+	env := e
+	for i := 0; i < distance; i++ {
+		if env.prev == nil {
+			panic(sprintf("env ancestor %d out of range", distance))
+		}
+		env = env.prev
+	}
+	return env
+}
+
+func (e Env) assign(name *Token, val Val) {
+	if e.consts[name.lexeme] {
+		panic(NewRuntimeError(name, sprintf("cannot assign to constant '%s'", name.lexeme)))
+	}
+	e.m[name.lexeme] = val
+}
+
+// shallow copy with the same parent
+func (e *Env) copy() *Env {
+	env := NewEnv(e.prev)
+	for key, val := range e.m {
+		if e.consts[key] {
+			env.DefineConst(key, val)
+		} else {
+			env.Define(key, val)
+		}
+	}
+	env.slots = append([]binding(nil), e.slots...)
+	return env
+}
+
+func (e Env) has(key string) bool {
+	_, ok := e.m[key]
+	return ok
+}
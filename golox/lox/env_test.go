@@ -0,0 +1,101 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvGetAtSetAt(t *testing.T) {
+	name := NewToken(IDENTIFIER, "a", nil, 1, 1)
+	outer := NewEnv(nil)
+	outer.DefineSlot(0, "a", "outer")
+	local := NewEnv(outer)
+	local.DefineSlot(1, "a", "local")
+	inner := NewEnv(local)
+
+	assert.Equal(t, "local", inner.GetAt(1, 1, name))
+	assert.Equal(t, "outer", inner.GetAt(2, 0, name))
+
+	inner.SetAt(2, 0, name, "changed")
+	assert.Equal(t, "changed", outer.GetAt(0, 0, name))
+	assert.Equal(t, "local", local.GetAt(0, 1, name))
+
+	// slot 0 of local is never defined
+	assert.Panics(t, func() { inner.GetAt(1, 0, name) })
+	assert.Panics(t, func() { inner.SetAt(1, 2, name, 1) })
+}
+
+func TestEnvConst(t *testing.T) {
+	name := NewToken(IDENTIFIER, "a", nil, 1, 1)
+	env := NewEnv(nil)
+	env.DefineConst("a", 1)
+
+	assert.Equal(t, 1, env.Get(name))
+	assert.Panics(t, func() { env.Set(name, 2) })
+
+	local := NewEnv(env)
+	local.DefineConstSlot(0, "a", 1)
+	assert.Panics(t, func() { NewEnv(local).SetAt(1, 0, name, 2) })
+
+	// redefining makes it a plain variable
+	env.Define("a", 3)
+	env.Set(name, 4)
+	assert.Equal(t, 4, env.Get(name))
+}
+
+func TestEnvUndefine(t *testing.T) {
+	name := NewToken(IDENTIFIER, "a", nil, 1, 1)
+	global := NewEnv(nil)
+	global.DefineConst("a", "global")
+	local := NewEnv(global)
+	local.DefineSlot(0, "a", "local")
+
+	local.Undefine("a")
+	assert.Panics(t, func() { local.GetAt(0, 0, name) })
+	assert.Equal(t, "global", local.Get(name))
+	local.Undefine("a")
+	assert.Panics(t, func() { local.Get(name) })
+	assert.Panics(t, func() { local.Undefine("a") })
+
+	// constant status is removed as well
+	global.Define("a", 1)
+	global.Set(name, 2)
+	assert.Equal(t, 2, global.Get(name))
+}
+
+func BenchmarkFib(b *testing.B) {
+	source := `
+func fib(n) {
+  var a = 0;
+  var b = 1;
+  for (var i = 0; i < n; i++) {
+    var t = a + b;
+    a = b;
+    b = t;
+  }
+  return a;
+}
+for (var i = 0; i < 100; i++) {
+  fib(30);
+}
+`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := New().Run(source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEnvAncestor(t *testing.T) {
+	global := NewEnv(nil)
+	block := NewEnv(global)
+	inner := NewEnv(block)
+
+	assert.Equal(t, inner, inner.Ancestor(0))
+	assert.Equal(t, block, inner.Ancestor(1))
+	assert.Equal(t, global, inner.Ancestor(2))
+	assert.Panics(t, func() { inner.Ancestor(3) })
+	assert.Panics(t, func() { global.Ancestor(1) })
+}
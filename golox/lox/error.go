@@ -0,0 +1,118 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// phases in which an error can occur
+const (
+	scanPhase     = "scan"
+	parsePhase    = "parse"
+	resolvePhase  = "resolve"
+	compilePhase  = "compile"
+	runtimePhase  = "runtime"
+	internalPhase = "internal"
+)
+
+// Error is returned from the public API, Err is a `*ScanError`,
+// `ParseErrors`, `*CompileError` or `*RuntimeError` depending on Phase,
+// use `errors.As`
+// to get the detail:
+//
+//	var re *lox.RuntimeError
+//	if errors.As(err, &re) {
+//		line, column := re.Position()
+//	}
+type Error struct {
+	Phase string // "scan", "parse", "resolve", "compile", "runtime" or "internal"
+	Err   error
+}
+
+func newError(phase string, err error) *Error {
+	return &Error{phase, err}
+}
+
+// each of multiple errors, e.g. `ParseErrors`, is on its own line
+func (e *Error) Error() string {
+	if errs, ok := e.Err.(interface{ Unwrap() []error }); ok {
+		lines := make([]string, 0, len(errs.Unwrap()))
+		for _, err := range errs.Unwrap() {
+			lines = append(lines, fmt.Sprintf("%s error: %v", e.Phase, err))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("%s error: %v", e.Phase, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// an error located in source, message is without the position
+type sourceError interface {
+	error
+	Position() (line, column int)
+	message() string
+}
+
+// ErrorSink reports errors returned from the public API, e.g. to show
+// them to the user
+type ErrorSink interface {
+	Report(err error)
+}
+
+type textSink struct {
+	w io.Writer
+}
+
+// NewTextSink returns a sink writing errors to w as `Error` formats them
+func NewTextSink(w io.Writer) ErrorSink {
+	return &textSink{w}
+}
+
+func (s *textSink) Report(err error) {
+	fmt.Fprintln(s.w, err)
+}
+
+type jsonSink struct {
+	encoder *json.Encoder
+}
+
+// NewJSONSink returns a sink writing errors to w as JSON objects, one per
+// line, e.g. `{"line":1,"col":5,"type":"runtime","message":"..."}`, type
+// is the phase, line and col are omitted for internal errors
+func NewJSONSink(w io.Writer) ErrorSink {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonSink{encoder}
+}
+
+type jsonError struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"col,omitempty"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// each of multiple errors, e.g. `ParseErrors`, is its own object
+func (s *jsonSink) Report(err error) {
+	phase := internalPhase
+	if e, ok := err.(*Error); ok {
+		phase, err = e.Phase, e.Err
+	}
+	errs := []error{err}
+	if multiple, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = multiple.Unwrap()
+	}
+	for _, err := range errs {
+		object := jsonError{Type: phase, Message: err.Error()}
+		if se, ok := err.(sourceError); ok {
+			object.Line, object.Column = se.Position()
+			object.Message = se.message()
+		}
+		s.encoder.Encode(object)
+	}
+}
@@ -0,0 +1,50 @@
+package lox_test
+
+import (
+	"fmt"
+
+	"github.com/fate-lovely/golox/lox"
+)
+
+func Example() {
+	in := lox.New()
+	err := in.Run(`
+func fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+var answer = fib(10);
+`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	answer, ok := in.GetGlobal("answer")
+	fmt.Println(answer, ok)
+
+	_, ok = in.GetGlobal("undefined")
+	fmt.Println(ok)
+	// Output:
+	// 55 true
+	// false
+}
+
+func ExampleScan() {
+	tokens, err := lox.Scan(`print 1 + x;`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, token := range tokens {
+		line, column := token.Position()
+		fmt.Printf("%d:%d %s %q\n", line, column, token.Type(), token.Lexeme())
+	}
+	// Output:
+	// 1:1 Print "print"
+	// 1:7 Number "1"
+	// 1:9 Plus "+"
+	// 1:11 Identifier "x"
+	// 1:12 Semicolon ";"
+	// 1:13 EOF ""
+}
@@ -0,0 +1,428 @@
+package lox
+
+import (
+	"bytes"
+)
+
+type Expr interface {
+	Print() string // for debug
+	Resolve(r *Resolver)
+	Eval(in *Interpreter) Val
+	compile(c *Compiler)
+	optimize(o *Optimizer) Expr
+	// source range of the node, end is right after its last character
+	Pos() (start, end Position)
+	setPos(start, end Position)
+}
+
+/*----------  Variable  ----------*/
+type ExprVariable struct {
+	span
+	name *Token
+	// scope distance and slot computed by resolver, -1 means global
+	depth int
+	slot  int
+}
+
+func NewExprVariable(name *Token) *ExprVariable {
+	return &ExprVariable{span{}, name, -1, -1}
+}
+
+func (expr *ExprVariable) Print() string {
+	return expr.name.lexeme
+}
+
+/*----------  Literal  ----------*/
+
+type ExprLiteral struct {
+	span
+	value interface{}
+}
+
+func NewExprLiteral(val interface{}) *ExprLiteral {
+	return &ExprLiteral{span{}, val}
+}
+
+func (expr *ExprLiteral) Print() string {
+	return sprintf("%#v", expr.value)
+}
+
+/*----------  Unary  ----------*/
+
+type ExprUnary struct {
+	span
+	operator *Token
+	operand  Expr
+}
+
+func NewExprUnary(operator *Token, operand Expr) *ExprUnary {
+	return &ExprUnary{span{}, operator, operand}
+}
+
+func (expr *ExprUnary) Print() string {
+	return parenthesize(expr.operator.lexeme, expr.operand)
+}
+
+/*----------  Binary  ----------*/
+
+type ExprBinary struct {
+	span
+	left     Expr
+	operator *Token
+	right    Expr
+}
+
+func NewExprBinary(left Expr, operator *Token, right Expr) *ExprBinary {
+	return &ExprBinary{span{}, left, operator, right}
+}
+
+func (expr *ExprBinary) Print() string {
+	return parenthesize(expr.operator.lexeme, expr.left, expr.right)
+}
+
+/*----------  Grouping  ----------*/
+
+type ExprGrouping struct {
+	span
+	operand Expr
+}
+
+func NewExprGrouping(operand Expr) *ExprGrouping {
+	return &ExprGrouping{span{}, operand}
+}
+
+func (expr *ExprGrouping) Print() string {
+	return parenthesize("group", expr.operand)
+}
+
+/*----------  Assignment  ----------*/
+type ExprAssignment struct {
+	span
+	name  *Token
+	val   Expr
+	depth int
+	slot  int
+}
+
+func NewExprAssignment(name *Token, val Expr) *ExprAssignment {
+	return &ExprAssignment{span{}, name, val, -1, -1}
+}
+
+func (expr *ExprAssignment) Print() string {
+	return parenthesize("assign "+expr.name.lexeme, expr.val)
+}
+
+/*----------  Logical  ----------*/
+type ExprLogical struct {
+	span
+	left     Expr
+	operator *Token
+	right    Expr
+}
+
+func NewExprLogical(left Expr, operator *Token, right Expr) *ExprLogical {
+	return &ExprLogical{span{}, left, operator, right}
+}
+
+func (expr *ExprLogical) Print() string {
+	return parenthesize(expr.operator.lexeme, expr.left, expr.right)
+}
+
+/*----------  Ternary  ----------*/
+type ExprTernary struct {
+	span
+	condition  Expr
+	thenBranch Expr
+	elseBranch Expr
+}
+
+func NewExprTernary(condition, thenBranch, elseBranch Expr) *ExprTernary {
+	return &ExprTernary{span{}, condition, thenBranch, elseBranch}
+}
+
+func (expr *ExprTernary) Print() string {
+	return parenthesize("?:", expr.condition, expr.thenBranch, expr.elseBranch)
+}
+
+/*----------  Function Call  ----------*/
+type ExprCall struct {
+	span
+	callee Expr
+	// close paren
+	paren     *Token
+	arguments []Expr
+}
+
+func NewExprCall(callee Expr, paren *Token, arguments []Expr) Expr {
+	return &ExprCall{span{}, callee, paren, arguments}
+}
+
+func (expr *ExprCall) Print() string {
+	return parenthesize(expr.callee.Print(), expr.arguments...)
+}
+
+/*----------  Property Get  ----------*/
+type ExprGet struct {
+	span
+	object Expr
+	name   *Token
+	// `?.`, a nil object skips the rest of the chain
+	optional bool
+}
+
+func NewExprGet(object Expr, name *Token, optional bool) *ExprGet {
+	return &ExprGet{span{}, object, name, optional}
+}
+
+func (expr *ExprGet) Print() string {
+	if expr.optional {
+		return parenthesize("get? "+expr.name.lexeme, expr.object)
+	}
+	return parenthesize("get "+expr.name.lexeme, expr.object)
+}
+
+/*----------  Property Set  ----------*/
+type ExprSet struct {
+	span
+	object Expr
+	name   *Token
+	val    Expr
+}
+
+func NewExprSet(object Expr, name *Token, val Expr) *ExprSet {
+	return &ExprSet{span{}, object, name, val}
+}
+
+func (expr *ExprSet) Print() string {
+	return parenthesize("set "+expr.name.lexeme, expr.object, expr.val)
+}
+
+/*----------  This  ----------*/
+type ExprThis struct {
+	span
+	keyword *Token
+	depth   int
+}
+
+func NewExprThis(keyword *Token) *ExprThis {
+	return &ExprThis{span{}, keyword, -1}
+}
+
+func (expr *ExprThis) Print() string {
+	return expr.keyword.lexeme
+}
+
+/*----------  Super  ----------*/
+type ExprSuper struct {
+	span
+	keyword *Token
+	method  *Token
+	// depth of `super`, `this` is always one env closer, both are the only
+	// variable of their env
+	depth int
+}
+
+func NewExprSuper(keyword *Token, method *Token) *ExprSuper {
+	return &ExprSuper{span{}, keyword, method, -1}
+}
+
+func (expr *ExprSuper) Print() string {
+	return sprintf("(super %s)", expr.method.lexeme)
+}
+
+/*----------  List Literal  ----------*/
+type ExprListLiteral struct {
+	span
+	elements []Expr
+}
+
+func NewExprListLiteral(elements []Expr) *ExprListLiteral {
+	return &ExprListLiteral{span{}, elements}
+}
+
+func (expr *ExprListLiteral) Print() string {
+	return parenthesize("list", expr.elements...)
+}
+
+/*----------  Interpolation  ----------*/
+// parts are string literals and embedded expressions in source order
+type ExprInterpolation struct {
+	span
+	parts []Expr
+}
+
+func NewExprInterpolation(parts []Expr) *ExprInterpolation {
+	return &ExprInterpolation{span{}, parts}
+}
+
+func (expr *ExprInterpolation) Print() string {
+	return parenthesize("interpolate", expr.parts...)
+}
+
+/*----------  Tuple  ----------*/
+type ExprTuple struct {
+	span
+	elements []Expr
+}
+
+func NewExprTuple(elements []Expr) *ExprTuple {
+	return &ExprTuple{span{}, elements}
+}
+
+func (expr *ExprTuple) Print() string {
+	return parenthesize("tuple", expr.elements...)
+}
+
+/*----------  Map Literal  ----------*/
+type ExprMapLiteral struct {
+	span
+	// open brace
+	brace  *Token
+	keys   []Expr
+	values []Expr
+}
+
+func NewExprMapLiteral(brace *Token, keys []Expr, values []Expr) *ExprMapLiteral {
+	return &ExprMapLiteral{span{}, brace, keys, values}
+}
+
+func (expr *ExprMapLiteral) Print() string {
+	var pairs []Expr
+	for i := range expr.keys {
+		pairs = append(pairs, expr.keys[i], expr.values[i])
+	}
+	return parenthesize("map", pairs...)
+}
+
+/*----------  Index Get  ----------*/
+type ExprIndexGet struct {
+	span
+	object Expr
+	// close bracket
+	bracket *Token
+	index   Expr
+}
+
+func NewExprIndexGet(object Expr, bracket *Token, index Expr) *ExprIndexGet {
+	return &ExprIndexGet{span{}, object, bracket, index}
+}
+
+func (expr *ExprIndexGet) Print() string {
+	return parenthesize("index", expr.object, expr.index)
+}
+
+/*----------  Index Set  ----------*/
+type ExprIndexSet struct {
+	span
+	object  Expr
+	bracket *Token
+	index   Expr
+	val     Expr
+}
+
+func NewExprIndexSet(object Expr, bracket *Token, index Expr, val Expr) *ExprIndexSet {
+	return &ExprIndexSet{span{}, object, bracket, index, val}
+}
+
+func (expr *ExprIndexSet) Print() string {
+	return parenthesize("index-set", expr.object, expr.index, expr.val)
+}
+
+/*----------  Comma  ----------*/
+
+// evaluates exprs from left to right, result is the last one
+type ExprComma struct {
+	span
+	exprs []Expr
+}
+
+func NewExprComma(exprs []Expr) *ExprComma {
+	return &ExprComma{span{}, exprs}
+}
+
+func (expr *ExprComma) Print() string {
+	return parenthesize(",", expr.exprs...)
+}
+
+/*----------  Update  ----------*/
+
+// `++` and `--`, target is a variable, property or index expression
+type ExprUpdate struct {
+	span
+	operator *Token
+	target   Expr
+	// prefix form evaluates to the new value, postfix to the old value
+	prefix bool
+}
+
+func NewExprUpdate(operator *Token, target Expr, prefix bool) *ExprUpdate {
+	return &ExprUpdate{span{}, operator, target, prefix}
+}
+
+func (expr *ExprUpdate) Print() string {
+	if expr.prefix {
+		return parenthesize(expr.operator.lexeme, expr.target)
+	}
+	return parenthesize("post"+expr.operator.lexeme, expr.target)
+}
+
+/*----------  Function  ----------*/
+// function expression, shares implementation with function declaration,
+// name is optional and only visible inside the function
+type ExprFunction struct {
+	span
+	decl *StmtFuncDecl
+}
+
+func NewExprFunction(decl *StmtFuncDecl) *ExprFunction {
+	return &ExprFunction{span{}, decl}
+}
+
+func (expr *ExprFunction) Print() string {
+	return expr.decl.Print()
+}
+
+/*----------  Block  ----------*/
+// `{ stmts; value }`, statements run in a new scope, then value is
+// evaluated in it as the result
+type ExprBlock struct {
+	span
+	stmts []Stmt
+	value Expr
+}
+
+func NewExprBlock(stmts []Stmt, value Expr) *ExprBlock {
+	return &ExprBlock{span{}, stmts, value}
+}
+
+func (expr *ExprBlock) Print() string {
+	parts := make([]string, 0, len(expr.stmts)+1)
+	for _, stmt := range expr.stmts {
+		parts = append(parts, stmt.Print())
+	}
+	return parenthesizeParts("block-expr", append(parts, expr.value.Print())...)
+}
+
+/*----------  Helper Methods  ----------*/
+func parenthesize(name string, exprs ...Expr) string {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		parts = append(parts, expr.Print())
+	}
+	return parenthesizeParts(name, parts...)
+}
+
+// parts are already printed
+func parenthesizeParts(name string, parts ...string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("(")
+	buf.WriteString(name)
+
+	for _, part := range parts {
+		buf.WriteString(" ")
+		buf.WriteString(part)
+	}
+	buf.WriteString(")")
+
+	return buf.String()
+}
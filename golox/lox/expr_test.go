@@ -1,4 +1,4 @@
-package main
+package lox
 
 import (
 	"testing"
@@ -9,15 +9,15 @@ import (
 func TestExprPrint(t *testing.T) {
 	expr := NewExprBinary(
 		NewExprUnary(
-			NewToken(MINUS, "-", nil, 1),
+			NewToken(MINUS, "-", nil, 1, 1),
 			NewExprLiteral(123),
 		),
-		NewToken(STAR, "*", nil, 1),
+		NewToken(STAR, "*", nil, 1, 1),
 		NewExprGrouping(
 			NewExprLiteral(45.67),
 		),
 	)
 
 	expected := "(* (- 123) (group 45.67))"
-	assert.Equal(t, expected, expr.print())
+	assert.Equal(t, expected, expr.Print())
 }
@@ -0,0 +1,597 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// define builtins in global env
+func defineGlobals(in *Interpreter) {
+	in.globals.Define("clock", &Clock{})
+	in.RegisterNative("len", 1, nativeLen)
+	in.RegisterNative("substr", 3, nativeSubstr)
+	in.RegisterNative("split", 2, nativeSplit)
+	in.RegisterNative("join", 2, nativeJoin)
+	in.RegisterNative("replace", 3, nativeReplace)
+	in.RegisterNative("trim", 1, nativeTrim)
+	in.RegisterNative("push", 2, nativePush)
+	in.RegisterNative("pop", 1, nativePop)
+	in.RegisterNative("freeze", 1, nativeFreeze)
+	in.RegisterNative("copy", 1, nativeCopy)
+	in.RegisterNative("deepcopy", 1, nativeDeepcopy)
+	in.globals.Define("range", &Function{"range", 1, 3, nativeRange})
+	in.globals.Define("map", NewFunction("map", 2, nativeMap))
+	in.globals.Define("filter", NewFunction("filter", 2, nativeFilter))
+	in.globals.Define("reduce", NewFunction("reduce", 3, nativeReduce))
+	in.RegisterNative("div", 2, nativeDiv)
+	in.globals.Define("env", NewFunction("env", 1, nativeEnv))
+	in.RegisterNative("typeof", 1, nativeTypeof)
+	in.RegisterNative("number", 1, nativeNumber)
+	in.RegisterNative("string", 1, nativeString)
+	in.globals.Define("println", NewFunction("println", 1, nativePrintln))
+	in.RegisterNative("identical", 2, nativeIdentical)
+	in.globals.Define("delete", NewFunction("delete", 1, nativeDelete))
+	in.globals.Define("readLine", NewFunction("readLine", 0, nativeReadLine))
+	in.globals.Define("readAll", NewFunction("readAll", 0, nativeReadAll))
+	in.globals.Define("readFile", NewFunction("readFile", 1, nativeReadFile))
+	in.globals.Define("writeFile", NewFunction("writeFile", 2, nativeWriteFile))
+	in.RegisterNative("exit", 1, nativeExit)
+	in.RegisterNative("panic", 1, nativePanic)
+	defineMath(in)
+}
+
+/*----------  clock  ----------*/
+
+// return current time in seconds
+type Clock struct{}
+
+func (c *Clock) Arity() (int, int) {
+	return 0, 0
+}
+
+func (c *Clock) Call(_ *Interpreter, _ []Val) Val {
+	return Number(float64(time.Now().UnixNano()) / float64(time.Second))
+}
+
+func (c *Clock) String() string {
+	return "<native fn clock>"
+}
+
+/*----------  len  ----------*/
+
+// length of string in runes, or number of list elements / map entries
+func nativeLen(args []Val) Val {
+	switch v := args[0].(type) {
+	case string:
+		return Number(utf8.RuneCountInString(v))
+	case *LoxList:
+		return Number(len(v.elements))
+	case *LoxMap:
+		return Number(len(v.keys))
+	}
+	panic(NewRuntimeError(nil, "len expects a string, list or map"))
+}
+
+/*----------  substr  ----------*/
+
+// substr(s, start, end) returns runes in [start, end)
+func nativeSubstr(args []Val) Val {
+	s, ok := args[0].(string)
+	if !ok {
+		panic(NewRuntimeError(nil, "substr expects a string"))
+	}
+	if !isInteger(args[1]) || !isInteger(args[2]) {
+		panic(NewRuntimeError(nil, "substr indices must be integers"))
+	}
+
+	runes := []rune(s)
+	start, end := int(toNumber(args[1])), int(toNumber(args[2]))
+	if start < 0 || end > len(runes) || start > end {
+		panic(NewRuntimeError(nil, sprintf("substr indices [%d, %d) out of range", start, end)))
+	}
+	return string(runes[start:end])
+}
+
+/*----------  split  ----------*/
+
+// split(s, sep) returns a list of substrings, an empty sep splits s into
+// characters
+func nativeSplit(args []Val) Val {
+	s, ok1 := args[0].(string)
+	sep, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		panic(NewRuntimeError(nil, "split expects two strings"))
+	}
+	parts := strings.Split(s, sep)
+	elements := make([]Val, len(parts))
+	for i, part := range parts {
+		elements[i] = part
+	}
+	return NewLoxList(elements)
+}
+
+/*----------  join  ----------*/
+
+// join(list, sep) concatenates elements, which are stringified like `print`
+func nativeJoin(args []Val) Val {
+	list, ok1 := args[0].(*LoxList)
+	sep, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		panic(NewRuntimeError(nil, "join expects a list and a string"))
+	}
+	strs := make([]string, len(list.elements))
+	for i, element := range list.elements {
+		strs[i] = stringify(element)
+	}
+	return strings.Join(strs, sep)
+}
+
+/*----------  replace  ----------*/
+
+// replace(s, old, replacement) replaces all occurrences of old
+func nativeReplace(args []Val) Val {
+	s, ok1 := args[0].(string)
+	old, ok2 := args[1].(string)
+	replacement, ok3 := args[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		panic(NewRuntimeError(nil, "replace expects three strings"))
+	}
+	return strings.Replace(s, old, replacement, -1)
+}
+
+/*----------  trim  ----------*/
+
+// remove leading and trailing whitespace
+func nativeTrim(args []Val) Val {
+	s, ok := args[0].(string)
+	if !ok {
+		panic(NewRuntimeError(nil, "trim expects a string"))
+	}
+	return strings.TrimSpace(s)
+}
+
+/*----------  push  ----------*/
+
+// append element to the end of list
+func nativePush(args []Val) Val {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		panic(NewRuntimeError(nil, "push expects a list"))
+	}
+	list.checkMutable(nil)
+	list.elements = append(list.elements, args[1])
+	return nil
+}
+
+/*----------  pop  ----------*/
+
+// remove and return the last element of list
+func nativePop(args []Val) Val {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		panic(NewRuntimeError(nil, "pop expects a list"))
+	}
+	list.checkMutable(nil)
+	n := len(list.elements)
+	if n == 0 {
+		panic(NewRuntimeError(nil, "pop from empty list"))
+	}
+	last := list.elements[n-1]
+	list.elements = list.elements[:n-1]
+	return last
+}
+
+/*----------  freeze  ----------*/
+
+// make list or map immutable and return it, elements themselves aren't
+// frozen
+func nativeFreeze(args []Val) Val {
+	switch v := args[0].(type) {
+	case *LoxList:
+		v.frozen = true
+	case *LoxMap:
+		v.frozen = true
+	default:
+		panic(NewRuntimeError(nil, "freeze expects a list or map"))
+	}
+	return args[0]
+}
+
+/*----------  copy  ----------*/
+
+// new list or map holding the same elements, the copy isn't frozen
+func nativeCopy(args []Val) Val {
+	switch v := args[0].(type) {
+	case *LoxList:
+		return NewLoxList(append([]Val(nil), v.elements...))
+	case *LoxMap:
+		m := NewLoxMap()
+		m.keys = append(m.keys, v.keys...)
+		for key, val := range v.m {
+			m.m[key] = val
+		}
+		m.buckets = v.copyBuckets()
+		return m
+	}
+	panic(NewRuntimeError(nil, "copy expects a list or map"))
+}
+
+/*----------  deepcopy  ----------*/
+
+// copy nested lists and maps too, other values are shared
+func nativeDeepcopy(args []Val) Val {
+	switch args[0].(type) {
+	case *LoxList, *LoxMap:
+		return deepcopy(args[0], map[Val]Val{})
+	}
+	panic(NewRuntimeError(nil, "deepcopy expects a list or map"))
+}
+
+// copies holds containers copied so far, a container reached again is
+// replaced by its copy, so cycles and sharing are kept
+func deepcopy(val Val, copies map[Val]Val) Val {
+	switch v := val.(type) {
+	case *LoxList:
+		if c, ok := copies[v]; ok {
+			return c
+		}
+		list := NewLoxList(make([]Val, len(v.elements)))
+		copies[v] = list
+		for i, element := range v.elements {
+			list.elements[i] = deepcopy(element, copies)
+		}
+		return list
+	case *LoxMap:
+		if c, ok := copies[v]; ok {
+			return c
+		}
+		m := NewLoxMap()
+		copies[v] = m
+		m.buckets = v.copyBuckets()
+		for _, key := range v.keys {
+			m.keys = append(m.keys, key)
+			m.m[key] = deepcopy(v.m[key], copies)
+		}
+		return m
+	}
+	return val
+}
+
+/*----------  range  ----------*/
+
+// ranges longer than this would exhaust memory rather than fail cleanly
+const maxRangeLength = 1 << 24
+
+// range(end), range(start, end) or range(start, end, step) returns a list
+// of numbers from start up to but not including end, start defaults to 0
+// and step to 1
+func nativeRange(_ *Interpreter, args []Val) Val {
+	for _, arg := range args {
+		n, ok := arg.(Number)
+		if !ok || math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+			panic(NewRuntimeError(nil, "range expects finite numbers"))
+		}
+	}
+	start, end, step := Number(0), args[0].(Number), Number(1)
+	if len(args) > 1 {
+		start, end = args[0].(Number), args[1].(Number)
+	}
+	if len(args) > 2 {
+		step = args[2].(Number)
+	}
+	if step == 0 {
+		panic(NewRuntimeError(nil, "range step can't be zero"))
+	}
+
+	count := math.Max(math.Ceil(float64((end-start)/step)), 0)
+	if count > maxRangeLength {
+		panic(NewRuntimeError(nil, sprintf("range of %.0f numbers is longer than the limit %d", count, maxRangeLength)))
+	}
+	elements := make([]Val, int(count))
+	// multiply rather than accumulate, so fractional steps don't drift
+	for i := range elements {
+		elements[i] = start + Number(i)*step
+	}
+	return NewLoxList(elements)
+}
+
+/*----------  map, filter and reduce  ----------*/
+
+// map(list, fn) returns a new list of fn(element) for each element
+func nativeMap(in *Interpreter, args []Val) Val {
+	list := listArgument("map", args[0])
+	elements := make([]Val, 0, len(list.elements))
+	for _, element := range list.elements {
+		elements = append(elements, in.callback("map", args[1], element))
+	}
+	return NewLoxList(elements)
+}
+
+// filter(list, pred) returns a new list of elements pred is truthy for
+func nativeFilter(in *Interpreter, args []Val) Val {
+	list := listArgument("filter", args[0])
+	var elements []Val
+	for _, element := range list.elements {
+		if getTruthy(in.callback("filter", args[1], element)) {
+			elements = append(elements, element)
+		}
+	}
+	return NewLoxList(elements)
+}
+
+// reduce(list, fn, init) folds elements from left, fn is called with the
+// accumulated value and an element
+func nativeReduce(in *Interpreter, args []Val) Val {
+	list := listArgument("reduce", args[0])
+	acc := args[2]
+	for _, element := range list.elements {
+		acc = in.callback("reduce", args[1], acc, element)
+	}
+	return acc
+}
+
+func listArgument(name string, arg Val) *LoxList {
+	list, ok := arg.(*LoxList)
+	if !ok {
+		panic(NewRuntimeError(nil, name+" expects a list"))
+	}
+	return list
+}
+
+// call a function passed to native name with the same depth limit as
+// calls, errors without token are reported at the call site of the native
+func (in *Interpreter) callback(name string, fn Val, arguments ...Val) Val {
+	function, ok := fn.(Callable)
+	if !ok {
+		panic(NewRuntimeError(nil, name+" expects a function"))
+	}
+	n := len(arguments)
+	if min, max := function.Arity(); n < min || (max >= 0 && n > max) {
+		panic(NewRuntimeError(nil, sprintf("%s expects a function taking %d arguments", name, n)))
+	}
+	in.enterCall(nil)
+	defer func() { in.depth-- }()
+	return function.Call(in, arguments)
+}
+
+/*----------  env  ----------*/
+
+// value of the OS environment variable, nil if unset
+func nativeEnv(in *Interpreter, args []Val) Val {
+	if !in.allowEnv {
+		panic(NewRuntimeError(nil, "env access is disabled"))
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		panic(NewRuntimeError(nil, "env expects a string"))
+	}
+	if val, ok := os.LookupEnv(name); ok {
+		return val
+	}
+	return nil
+}
+
+/*----------  typeof  ----------*/
+
+// name of the dynamic type
+func nativeTypeof(args []Val) Val {
+	switch args[0].(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case Number:
+		return "number"
+	case string:
+		return "string"
+	case *LoxClass:
+		return "class"
+	case *LoxInstance:
+		return "instance"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *LoxTuple:
+		return "tuple"
+	case Callable:
+		return "function"
+	}
+	panic(NewRuntimeError(nil, sprintf("unknown type %T", args[0])))
+}
+
+/*----------  number  ----------*/
+
+// parse string as number, a number is returned unchanged
+func nativeNumber(args []Val) Val {
+	switch v := args[0].(type) {
+	case Number:
+		return v
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || !isNumberLiteral(v) {
+			panic(NewRuntimeError(nil, sprintf("can't parse %q as number", v)))
+		}
+		return Number(n)
+	}
+	panic(NewRuntimeError(nil, "number expects a string or number"))
+}
+
+// decimal number literal of the scanner without `_`, with optional sign,
+// ParseFloat alone also accepts "NaN", "Inf" and hex floats
+func isNumberLiteral(s string) bool {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	i := 0
+	for i < len(s) && isDigit(rune(s[i])) {
+		i++
+	}
+	if i == 0 {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '-' || s[i] == '+') {
+			i++
+		}
+		start := i
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+/*----------  string  ----------*/
+
+// display string of any value, the same as `print`
+func nativeString(args []Val) Val {
+	return stringify(args[0])
+}
+
+/*----------  println  ----------*/
+
+// print value like `print` statement, then return it
+func nativePrintln(in *Interpreter, args []Val) Val {
+	fmt.Fprintln(in.stdout, stringify(args[0]))
+	return args[0]
+}
+
+/*----------  identical  ----------*/
+
+// identity comparison, unlike `==` lists and maps are equal only if they
+// are the same object
+func nativeIdentical(args []Val) Val {
+	return args[0] == args[1]
+}
+
+/*----------  div  ----------*/
+
+// floor division, the `//` operator of other languages, div(-7, 2) is -4
+func nativeDiv(args []Val) Val {
+	a, aok := args[0].(Number)
+	b, bok := args[1].(Number)
+	if !aok || !bok {
+		panic(NewRuntimeError(nil, "div expects numbers"))
+	}
+	if b == 0 {
+		panic(NewRuntimeError(nil, "divide by zero"))
+	}
+	return Number(math.Floor(float64(a / b)))
+}
+
+/*----------  delete  ----------*/
+
+// remove the variable named by the string from the scope of the call, its
+// value can be garbage collected if nothing else refers to it
+func nativeDelete(in *Interpreter, args []Val) Val {
+	name, ok := args[0].(string)
+	if !ok {
+		panic(NewRuntimeError(nil, "delete expects a string"))
+	}
+	in.env.Undefine(name)
+	return nil
+}
+
+/*----------  readLine  ----------*/
+
+// next line of input without line ending, nil at end of input
+func nativeReadLine(in *Interpreter, args []Val) Val {
+	line, err := in.stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		panic(NewRuntimeError(nil, sprintf("can't read input: %v", err)))
+	}
+	if err == io.EOF && line == "" {
+		return nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+/*----------  readAll  ----------*/
+
+// the rest of input, empty string at end of input
+func nativeReadAll(in *Interpreter, args []Val) Val {
+	buf, err := ioutil.ReadAll(in.stdin)
+	if err != nil {
+		panic(NewRuntimeError(nil, sprintf("can't read input: %v", err)))
+	}
+	return string(buf)
+}
+
+/*----------  readFile  ----------*/
+
+// contents of the file as a string
+func nativeReadFile(in *Interpreter, args []Val) Val {
+	if !in.allowFileIO {
+		panic(NewRuntimeError(nil, "file access is disabled"))
+	}
+	path, ok := args[0].(string)
+	if !ok {
+		panic(NewRuntimeError(nil, "readFile expects a string"))
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(NewRuntimeError(nil, err.Error()))
+	}
+	return string(buf)
+}
+
+/*----------  writeFile  ----------*/
+
+// writeFile(path, contents) creates or truncates the file
+func nativeWriteFile(in *Interpreter, args []Val) Val {
+	if !in.allowFileIO {
+		panic(NewRuntimeError(nil, "file access is disabled"))
+	}
+	path, ok1 := args[0].(string)
+	contents, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		panic(NewRuntimeError(nil, "writeFile expects two strings"))
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		panic(NewRuntimeError(nil, err.Error()))
+	}
+	return nil
+}
+
+/*----------  exit  ----------*/
+
+// stop the program with exit code, `finally` blocks of enclosing `try`
+// still run
+func nativeExit(args []Val) Val {
+	if !isInteger(args[0]) {
+		panic(NewRuntimeError(nil, "exit code must be an integer"))
+	}
+	panic(&ProgramExit{int(toNumber(args[0]))})
+}
+
+/*----------  panic  ----------*/
+
+// raise a runtime error `catch` can't stop, message is stringified
+func nativePanic(args []Val) Val {
+	err := NewRuntimeError(nil, "panic: "+stringify(args[0]))
+	err.fatal = true
+	panic(err)
+}
@@ -0,0 +1,496 @@
+package lox
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	lox := evalSource(t, `
+var first = clock();
+var second = clock();
+`)
+	first, ok := getGlobal(lox, "first").(Number)
+	assert.True(t, ok)
+	second, ok := getGlobal(lox, "second").(Number)
+	assert.True(t, ok)
+
+	assert.True(t, first > 0)
+	assert.True(t, second >= first)
+}
+
+func TestRegisterNative(t *testing.T) {
+	lox := New()
+	lox.RegisterNative("double", 1, func(args []Val) Val {
+		n, ok := args[0].(Number)
+		if !ok {
+			panic(NewRuntimeError(nil, "double expects a number"))
+		}
+		return n * 2
+	})
+
+	assert.Nil(t, lox.Run("var result = double(21);"))
+	assert.Equal(t, Number(42), getGlobal(lox, "result"))
+	assert.Equal(t, "<native fn double>", getGlobal(lox, "double").(*Function).String())
+
+	err := lox.Run("\n double(1, 2);")
+	assert.EqualError(t, err, "runtime error: line 2, col 13, expect 1 arguments but got 2")
+	err = lox.Run(`
+double("a");`)
+	assert.EqualError(t, err, "runtime error: line 2, col 11, double expects a number")
+}
+
+func TestLen(t *testing.T) {
+	assert.Equal(t, Number(0), evalExpr(t, `len("")`))
+	assert.Equal(t, Number(5), evalExpr(t, `len("hello")`))
+	assert.Equal(t, Number(4), evalExpr(t, `len("你好😀!")`))
+
+	assert.Equal(t, Number(3), evalExpr(t, `len([1, "a", nil])`))
+	assert.Equal(t, Number(2), evalExpr(t, `len({"a": 1, 2: nil})`))
+
+	err := New().Run("len(1);")
+	assert.EqualError(t, err, "runtime error: line 1, col 6, len expects a string, list or map")
+}
+
+func TestSubstr(t *testing.T) {
+	assert.Equal(t, "ell", evalExpr(t, `substr("hello", 1, 4)`))
+	assert.Equal(t, "", evalExpr(t, `substr("hello", 2, 2)`))
+	assert.Equal(t, "好😀", evalExpr(t, `substr("你好😀!", 1, 3)`))
+	assert.Equal(t, "😀!", evalExpr(t, `substr("你好😀!", 2, len("你好😀!"))`))
+
+	errors := map[string]string{
+		`substr(1, 0, 1);`:       "runtime error: line 1, col 15, substr expects a string",
+		`substr("abc", 0.5, 1);`: "runtime error: line 1, col 21, substr indices must be integers",
+		`substr("abc", 0, "1");`: "runtime error: line 1, col 21, substr indices must be integers",
+		`substr("abc", -1, 1);`:  "runtime error: line 1, col 20, substr indices [-1, 1) out of range",
+		`substr("abc", 0, 4);`:   "runtime error: line 1, col 19, substr indices [0, 4) out of range",
+		`substr("abc", 2, 1);`:   "runtime error: line 1, col 19, substr indices [2, 1) out of range",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg)
+	}
+}
+
+func TestPushPop(t *testing.T) {
+	lox := evalSource(t, `
+var list = [];
+push(list, 1);
+push(list, 2);
+push(list, 3);
+var last = pop(list);
+var length = len(list);
+`)
+	assert.Equal(t, Number(3), getGlobal(lox, "last"))
+	assert.Equal(t, Number(2), getGlobal(lox, "length"))
+	assert.Equal(t, []Val{Number(1), Number(2)}, getGlobal(lox, "list").(*LoxList).elements)
+
+	err := New().Run("pop([]);")
+	assert.EqualError(t, err, "runtime error: line 1, col 7, pop from empty list")
+	err = New().Run(`push("a", 1);`)
+	assert.EqualError(t, err, "runtime error: line 1, col 12, push expects a list")
+}
+
+func TestFreeze(t *testing.T) {
+	lox := evalSource(t, `
+var list = [1, [2]];
+var frozen = freeze(list);
+var same = identical(list, frozen);
+var first = list[0];
+var length = len(list);
+var inner = list[1];
+push(inner, 3);
+
+var m = freeze({"a": 1});
+var a = m["a"];
+var missing = m["b"];
+var keys = [];
+for (k in m) push(keys, k);
+`)
+	assert.Equal(t, true, getGlobal(lox, "same"))
+	assert.Equal(t, Number(1), getGlobal(lox, "first"))
+	assert.Equal(t, Number(2), getGlobal(lox, "length"))
+	// elements aren't frozen along with the list
+	assert.Equal(t, "[1, [2, 3]]", stringify(getGlobal(lox, "list")))
+	assert.Equal(t, Number(1), getGlobal(lox, "a"))
+	assert.Nil(t, getGlobal(lox, "missing"))
+	assert.Equal(t, "[a]", stringify(getGlobal(lox, "keys")))
+
+	errors := map[string]string{
+		"var l = freeze([1]);\nl[0] = 2;":          "line 2, col 4, cannot modify frozen collection",
+		"var l = freeze([1]);\nl[0]++;":            "line 2, col 4, cannot modify frozen collection",
+		"var l = freeze([1]);\npush(l, 2);":        "line 2, col 10, cannot modify frozen collection",
+		"var l = freeze([1]);\npop(l);":            "line 2, col 6, cannot modify frozen collection",
+		"var m = freeze({});\nm[\"a\"] = 1;":       "line 2, col 6, cannot modify frozen collection",
+		"var m = freeze({\"a\": 1});\nm[\"a\"]--;": "line 2, col 6, cannot modify frozen collection",
+		"freeze(\"a\");":                           "line 1, col 11, freeze expects a list or map",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), "runtime error: "+msg, source)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	lox := evalSource(t, `
+var inner = [1];
+var list = freeze([inner, {"k": inner}]);
+var shallow = copy(list);
+var deep = deepcopy(list);
+push(shallow, "only in copy");
+push(inner, 2);
+var shallowShares = identical(shallow[0], inner);
+var deepShares = identical(deep[0], inner);
+// sharing within the copied structure is kept
+var deepSharing = identical(deep[0], deep[1]["k"]);
+
+var m = {"a": [1]};
+var mCopy = copy(m);
+mCopy["b"] = 2;
+mCopy["a"][0] = 10;
+
+var cycle = [1];
+push(cycle, cycle);
+var cycleCopy = deepcopy(cycle);
+var closed = identical(cycleCopy[1], cycleCopy);
+var detached = !identical(cycleCopy, cycle);
+`)
+	assert.Equal(t, "[[1, 2], {k: [1, 2]}]", stringify(getGlobal(lox, "list")))
+	assert.Equal(t, "[[1, 2], {k: [1, 2]}, only in copy]", stringify(getGlobal(lox, "shallow")))
+	assert.Equal(t, "[[1], {k: [1]}]", stringify(getGlobal(lox, "deep")))
+	assert.Equal(t, true, getGlobal(lox, "shallowShares"))
+	assert.Equal(t, false, getGlobal(lox, "deepShares"))
+	assert.Equal(t, true, getGlobal(lox, "deepSharing"))
+	assert.Equal(t, "{a: [10]}", stringify(getGlobal(lox, "m")))
+	assert.Equal(t, "{a: [10], b: 2}", stringify(getGlobal(lox, "mCopy")))
+	assert.Equal(t, true, getGlobal(lox, "closed"))
+	assert.Equal(t, true, getGlobal(lox, "detached"))
+
+	err := New().Run("copy(1);")
+	assert.EqualError(t, err, "runtime error: line 1, col 7, copy expects a list or map")
+	err = New().Run("deepcopy(nil);")
+	assert.EqualError(t, err, "runtime error: line 1, col 13, deepcopy expects a list or map")
+}
+
+func TestRange(t *testing.T) {
+	tests := map[string]string{
+		"range(4)":           "[0, 1, 2, 3]",
+		"range(0)":           "[]",
+		"range(-2)":          "[]",
+		"range(2, 5)":        "[2, 3, 4]",
+		"range(5, 2)":        "[]",
+		"range(0, 10, 3)":    "[0, 3, 6, 9]",
+		"range(5, 0, -2)":    "[5, 3, 1]",
+		"range(0, 5, -1)":    "[]",
+		"range(0, 1, 0.25)":  "[0, 0.25, 0.5, 0.75]",
+		"range(-1.5, 1)":     "[-1.5, -0.5, 0.5]",
+		"range(0, 0.3, 0.1)": "[0, 0.1, 0.2]",
+	}
+	for source, expected := range tests {
+		assert.Equal(t, expected, stringify(evalExpr(t, source)), source)
+	}
+
+	lox := evalSource(t, `
+var sum = 0;
+for (i in range(1, 11)) sum += i;
+`)
+	assert.Equal(t, Number(55), getGlobal(lox, "sum"))
+
+	err := New().Run("range(0, 10, 0);")
+	assert.EqualError(t, err, "runtime error: line 1, col 15, range step can't be zero")
+	err = New().Run(`range("3");`)
+	assert.EqualError(t, err, "runtime error: line 1, col 10, range expects finite numbers")
+	err = New().Run("range(10 ** 400);")
+	assert.EqualError(t, err, "runtime error: line 1, col 16, range expects finite numbers")
+	err = New().Run("range(10 ** 12);")
+	assert.EqualError(t, err, "runtime error: line 1, col 15, range of 1000000000000 numbers is longer than the limit 16777216")
+	err = New().Run("range(0, 10 ** 9, 0.5);")
+	assert.EqualError(t, err, "runtime error: line 1, col 22, range of 2000000000 numbers is longer than the limit 16777216")
+	err = New().Run("range();")
+	assert.EqualError(t, err, "runtime error: line 1, col 7, expect 1 to 3 arguments but got 0")
+}
+
+func TestHigherOrderFunctions(t *testing.T) {
+	lox := evalSource(t, `
+func double(n) { return n * 2; }
+func isOdd(n) { return n % 2 == 1; }
+func add(a, b) { return a + b; }
+
+var numbers = [1, 2, 3, 4, 5];
+var doubled = map(numbers, double);
+var odds = filter(numbers, isOdd);
+var sum = reduce(numbers, add, 0);
+
+var squares = map(numbers, func(n) { return n * n; });
+var big = filter(numbers, func(n) { return n > 3; });
+var joined = reduce(numbers, func(acc, n) { return acc + string(n); }, "");
+var strings = map(numbers, string);
+
+var none = filter(numbers, func(n) { return nil; });
+var empty = reduce([], add, "init");
+`)
+	assert.Equal(t, "[2, 4, 6, 8, 10]", stringify(getGlobal(lox, "doubled")))
+	assert.Equal(t, "[1, 3, 5]", stringify(getGlobal(lox, "odds")))
+	assert.Equal(t, Number(15), getGlobal(lox, "sum"))
+	assert.Equal(t, "[1, 4, 9, 16, 25]", stringify(getGlobal(lox, "squares")))
+	assert.Equal(t, "[4, 5]", stringify(getGlobal(lox, "big")))
+	assert.Equal(t, "12345", getGlobal(lox, "joined"))
+	assert.Equal(t, []Val{"1", "2", "3", "4", "5"}, getGlobal(lox, "strings").(*LoxList).elements)
+	assert.Equal(t, "[]", stringify(getGlobal(lox, "none")))
+	assert.Equal(t, "init", getGlobal(lox, "empty"))
+	// the original list isn't mutated
+	assert.Equal(t, "[1, 2, 3, 4, 5]", stringify(getGlobal(lox, "numbers")))
+
+	errors := map[string]string{
+		`map("abc", string);`:                      "runtime error: line 1, col 18, map expects a list",
+		`filter([1], 1);`:                          "runtime error: line 1, col 14, filter expects a function",
+		`reduce([1], func(a) { return a; }, 0);`:   "runtime error: line 1, col 37, reduce expects a function taking 2 arguments",
+		`map([1], func(a) { return a.b; });`:       "runtime error: line 1, col 29, only instances and classes have properties",
+		`map([1], len);`:                           "runtime error: line 1, col 13, len expects a string, list or map",
+		"func f(n) { return map([n], f); }\nf(1);": "runtime error: line 1, col 30, stack overflow",
+	}
+	for source, expected := range errors {
+		assert.EqualError(t, New().Run(source), expected, source)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	os.Setenv("GOLOX_TEST_ENV", "hello")
+	defer os.Unsetenv("GOLOX_TEST_ENV")
+	os.Unsetenv("GOLOX_TEST_UNSET")
+
+	lox := evalSource(t, `
+var value = env("GOLOX_TEST_ENV");
+var unset = env("GOLOX_TEST_UNSET");
+`)
+	assert.Equal(t, "hello", getGlobal(lox, "value"))
+	assert.Nil(t, getGlobal(lox, "unset"))
+
+	err := New().Run("env(1);")
+	assert.EqualError(t, err, "runtime error: line 1, col 6, env expects a string")
+
+	err = New(AllowEnv(false)).Run(`env("GOLOX_TEST_ENV");`)
+	assert.EqualError(t, err, "runtime error: line 1, col 21, env access is disabled")
+}
+
+func TestTypeof(t *testing.T) {
+	cases := map[string]string{
+		"1.5":        "number",
+		`"a"`:        "string",
+		"true":       "bool",
+		"nil":        "nil",
+		"clock":      "function",
+		"len":        "function",
+		"func () {}": "function",
+		"Foo":        "class",
+		"Foo()":      "instance",
+		"Foo().bar":  "function",
+		"[1]":        "list",
+		`{"a": 1}`:   "map",
+		"typeof(1)":  "string",
+	}
+	for source, expected := range cases {
+		lox := evalSource(t, "class Foo { bar() {} }\nvar result = typeof("+source+");")
+		assert.Equal(t, expected, getGlobal(lox, "result"), source)
+	}
+}
+
+func TestNumberString(t *testing.T) {
+	lox := evalSource(t, `
+var parsed = number("3.25");
+var negative = number("-10");
+var exponent = number("-2.5E-3");
+var unchanged = number(42);
+var roundTrip = number(string(1.5)) == 1.5;
+var s = string(2.5) + string(10) + string(nil) + string(true) + string("x");
+var list = string([1, "a"]);
+`)
+	assert.Equal(t, Number(3.25), getGlobal(lox, "parsed"))
+	assert.Equal(t, Number(-10), getGlobal(lox, "negative"))
+	assert.Equal(t, Number(-0.0025), getGlobal(lox, "exponent"))
+	assert.Equal(t, Number(42), getGlobal(lox, "unchanged"))
+	assert.Equal(t, true, getGlobal(lox, "roundTrip"))
+	assert.Equal(t, "2.510niltruex", getGlobal(lox, "s"))
+	assert.Equal(t, "[1, a]", getGlobal(lox, "list"))
+
+	err := New().Run(`number("12abc");`)
+	assert.EqualError(t, err, `runtime error: line 1, col 15, can't parse "12abc" as number`)
+	err = New().Run(`number("");`)
+	assert.EqualError(t, err, `runtime error: line 1, col 10, can't parse "" as number`)
+	for _, s := range []string{"NaN", "nan", "inf", "+Inf", "-Infinity", "infinity", "0x1p-2", "1e", "1e+", "1_000", ".5", "5.", "--1"} {
+		err = New().Run(sprintf("number(%q);", s))
+		assert.EqualError(t, err, sprintf("runtime error: line 1, col %d, can't parse %q as number", len(s)+10, s), s)
+	}
+	err = New().Run("number(nil);")
+	assert.EqualError(t, err, "runtime error: line 1, col 11, number expects a string or number")
+}
+
+func TestPrintln(t *testing.T) {
+	output := &bytes.Buffer{}
+	lox := New()
+	lox.SetOutput(output)
+	assert.Nil(t, lox.Run(`
+func compute() { return 1.5 * 2; }
+var x = println(compute());
+var y = println(println("twice"));
+println([nil, true]);
+`))
+	assert.Equal(t, "3\ntwice\ntwice\n[nil, true]\n", output.String())
+	assert.Equal(t, Number(3), getGlobal(lox, "x"))
+	assert.Equal(t, "twice", getGlobal(lox, "y"))
+}
+
+func TestDiv(t *testing.T) {
+	cases := map[string]Number{
+		"div(7, 2)":   3,
+		"div(-7, 2)":  -4,
+		"div(7, -2)":  -4,
+		"div(-7, -2)": 3,
+		"div(6, 3)":   2,
+		"div(7.5, 2)": 3,
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+
+	errors := map[string]string{
+		"div(1, 0);":   "runtime error: line 1, col 9, divide by zero",
+		`div("7", 2);`: "runtime error: line 1, col 11, div expects numbers",
+		"div(1, nil);": "runtime error: line 1, col 11, div expects numbers",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	lox := evalSource(t, `
+var big = [1, 2, 3];
+var kept = 1;
+delete("big");
+var shadowed = "global";
+{
+  var shadowed = "local";
+  delete("shadowed");
+}
+`)
+	_, ok := lox.GetGlobal("big")
+	assert.False(t, ok)
+	assert.Equal(t, Number(1), getGlobal(lox, "kept"))
+	// only the innermost binding is removed
+	assert.Equal(t, "global", getGlobal(lox, "shadowed"))
+
+	errors := map[string]string{
+		`var a = 1; delete("a"); print a;`: "runtime error: line 1, col 31, undefined variable 'a'",
+		`delete("missing");`:               "runtime error: line 1, col 17, undefined variable 'missing'",
+		`delete(1);`:                       "runtime error: line 1, col 9, delete expects a string",
+		`{ var a = 1; delete("a"); a; }`:   "runtime error: line 1, col 27, undefined variable 'a'",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestStringFunctions(t *testing.T) {
+	lox := evalSource(t, `
+var words = split("a,b,,c", ",");
+var chars = split("héllo", "");
+var whole = split("abc", ";");
+var joined = join([1, "two", nil, [3]], "-");
+var empty = join([], ",");
+var roundTrip = join(split("x y z", " "), " ");
+var replaced = replace("a-b-c", "-", "+");
+var noMatch = replace("abc", "x", "y");
+var removed = replace("aXbX", "X", "");
+var trimmed = trim("  \t hi there \n");
+var blank = trim("   ");
+`)
+	assert.Equal(t, "[a, b, , c]", stringify(getGlobal(lox, "words")))
+	assert.Equal(t, "[h, é, l, l, o]", stringify(getGlobal(lox, "chars")))
+	assert.Equal(t, "[abc]", stringify(getGlobal(lox, "whole")))
+	assert.Equal(t, "1-two-nil-[3]", getGlobal(lox, "joined"))
+	assert.Equal(t, "", getGlobal(lox, "empty"))
+	assert.Equal(t, "x y z", getGlobal(lox, "roundTrip"))
+	assert.Equal(t, "a+b+c", getGlobal(lox, "replaced"))
+	assert.Equal(t, "abc", getGlobal(lox, "noMatch"))
+	assert.Equal(t, "ab", getGlobal(lox, "removed"))
+	assert.Equal(t, "hi there", getGlobal(lox, "trimmed"))
+	assert.Equal(t, "", getGlobal(lox, "blank"))
+
+	errors := map[string]string{
+		`split(1, ",");`:        "runtime error: line 1, col 13, split expects two strings",
+		`split("a", nil);`:      "runtime error: line 1, col 15, split expects two strings",
+		`join("abc", ",");`:     "runtime error: line 1, col 16, join expects a list and a string",
+		`join([], 1);`:          "runtime error: line 1, col 11, join expects a list and a string",
+		`replace("a", "b", 1);`: "runtime error: line 1, col 20, replace expects three strings",
+		"trim(1);":              "runtime error: line 1, col 7, trim expects a string",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	lox := New()
+	lox.SetInput(strings.NewReader("first\r\nsecond\n\nrest\nof input"))
+	assert.Nil(t, lox.Run(`
+var first = readLine();
+var second = readLine();
+var empty = readLine();
+var rest = readAll();
+var afterAll = readLine();
+var allAgain = readAll();
+`))
+	assert.Equal(t, "first", getGlobal(lox, "first"))
+	assert.Equal(t, "second", getGlobal(lox, "second"))
+	assert.Equal(t, "", getGlobal(lox, "empty"))
+	assert.Equal(t, "rest\nof input", getGlobal(lox, "rest"))
+	assert.Nil(t, getGlobal(lox, "afterAll"))
+	assert.Equal(t, "", getGlobal(lox, "allAgain"))
+
+	// last line without line ending
+	lox.SetInput(strings.NewReader("a\nb"))
+	assert.Nil(t, lox.Run(`var lines = [readLine(), readLine(), readLine()];`))
+	assert.Equal(t, "[a, b, nil]", stringify(getGlobal(lox, "lines")))
+}
+
+func TestFileIO(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golox")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.txt")
+
+	lox := New()
+	lox.globals.Define("path", path)
+	assert.Nil(t, lox.Run(`
+writeFile(path, "line 1\nline 2");
+var contents = readFile(path);
+writeFile(path, "short");
+var overwritten = readFile(path);
+`))
+	assert.Equal(t, "line 1\nline 2", getGlobal(lox, "contents"))
+	assert.Equal(t, "short", getGlobal(lox, "overwritten"))
+
+	missing := filepath.Join(dir, "missing.txt")
+	lox.globals.Define("missing", missing)
+	err = lox.Run(`readFile(missing);`)
+	assert.EqualError(t, err, "runtime error: line 1, col 17, open "+missing+": no such file or directory")
+	lox.globals.Define("noDir", filepath.Join(missing, "x.txt"))
+	err = lox.Run(`writeFile(noDir, "");`)
+	assert.Contains(t, err.Error(), "runtime error: line 1, col 20, open ")
+	assert.EqualError(t, lox.Run(`readFile(1);`), "runtime error: line 1, col 11, readFile expects a string")
+	assert.EqualError(t, lox.Run(`writeFile(path, 1);`), "runtime error: line 1, col 18, writeFile expects two strings")
+
+	disabled := New(AllowFileIO(false))
+	disabled.globals.Define("path", path)
+	assert.EqualError(t, disabled.Run(`readFile(path);`), "runtime error: line 1, col 14, file access is disabled")
+	assert.EqualError(t, disabled.Run(`writeFile(path, "x");`), "runtime error: line 1, col 20, file access is disabled")
+	buf, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "short", string(buf))
+}
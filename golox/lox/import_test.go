@@ -0,0 +1,81 @@
+package lox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// write files into a temp directory, return the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "golox")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib/math.lox": `
+import "consts.lox";
+func double(x) { return x * two; }
+`,
+		"lib/consts.lox": `const two = 2;`,
+	})
+	defer os.RemoveAll(dir)
+
+	lox := New(ImportDir(dir))
+	assert.Nil(t, lox.Run(`
+{
+  import "lib/math.lox";
+}
+var result = double(21);
+`))
+	assert.Equal(t, Number(42), getGlobal(lox, "result"))
+	assert.Equal(t, Number(2), getGlobal(lox, "two"))
+}
+
+func TestImportError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.lox":   `import "b.lox";`,
+		"b.lox":   `import "a.lox";`,
+		"bad.lox": `var = 1;`,
+	})
+	defer os.RemoveAll(dir)
+
+	err := New(ImportDir(dir)).Run(`import "a.lox";`)
+	assert.EqualError(t, err, "runtime error: line 1, col 1, cyclic import of 'a.lox'")
+
+	err = New(ImportDir(dir)).Run(`import "bad.lox";`)
+	assert.EqualError(t, err, "runtime error: line 1, col 1, can't import 'bad.lox': parse error: line 1, col 5, at '=', expect variable name")
+
+	err = New(ImportDir(dir)).Run(`import "missing.lox";`)
+	assert.Contains(t, err.Error(), "runtime error: line 1, col 1, can't import 'missing.lox': ")
+
+	err = New().Run("import foo;")
+	assert.EqualError(t, err, "parse error: line 1, col 8, at 'foo', expect path string after 'import'")
+}
+
+func TestImportRootScript(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.lox":     `var a = 1; import "lib/b.lox";`,
+		"lib/b.lox": `var b = 2; import "../a.lox";`,
+	})
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.lox")
+	source, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+
+	err = New(Script(path)).Run(string(source))
+	assert.EqualError(t, err, "runtime error: line 1, col 12, cyclic import of '../a.lox'")
+}
@@ -0,0 +1,1392 @@
+package lox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Val interface{}
+type Number float64
+
+// Interpreter is the execution context threaded through every `Run`, `Eval`
+// and `Call`, `env` is the innermost env of the code being executed
+type Interpreter struct {
+	scanner  *Scanner
+	parser   *Parser
+	resolver *Resolver
+
+	globals *Env
+	env     *Env
+	// exit code of the process, set when execution fails or by `exit`
+	exitCode int
+	// whether last run was stopped by `exit`
+	exited bool
+
+	// host access granted to builtins
+	allowEnv    bool
+	allowFileIO bool
+
+	// relative imports are resolved against dir, which is the directory of
+	// the file being run
+	dir string
+	// absolute paths of files being imported, used to detect cycles
+	importing map[string]bool
+
+	// static warnings reported by resolver
+	warnings []string
+
+	// where `print` writes to
+	stdout io.Writer
+	// where `readLine` and `readAll` read from
+	stdin *bufio.Reader
+	// where REPL reports errors, nil writes them to its output as text
+	errorSink ErrorSink
+	// source of `random` and `randomInt`, reseeded by `seed`
+	random *rand.Rand
+
+	// number of active calls, exceeding maxDepth is a runtime error rather
+	// than overflowing the Go stack
+	depth    int
+	maxDepth int
+
+	// print statements before running them and expressions with their
+	// values, indented by nesting of statements
+	trace      bool
+	traceLevel int
+
+	// Run compiles the program to bytecode for VM
+	useVM bool
+	// fold constant expressions after resolving
+	optimize bool
+}
+
+const defaultMaxDepth = 1000
+
+// exit codes follow sysexits.h
+const (
+	exitCodeStaticError  = 65
+	exitCodeRuntimeError = 70
+)
+
+// RegisterNative defines a global native function, arguments are checked
+// against arity before fn is called.
+//
+// To report an error, fn should panic with `NewRuntimeError(nil, msg)`, the
+// error is reported at the call site.
+func (in *Interpreter) RegisterNative(name string, arity int, fn func(args []Val) Val) {
+	in.globals.Define(name, NewFunction(name, arity, func(_ *Interpreter, args []Val) Val {
+		return fn(args)
+	}))
+}
+
+// Interpret runs the top level program, execution halts at the first
+// runtime error, which is returned
+func (in *Interpreter) Interpret(stmts []Stmt) (err error) {
+	defer in.recoverRuntime(&err)
+
+	for _, stmt := range stmts {
+		in.execute(stmt)
+	}
+	return
+}
+
+// convert what escapes top level code to error, shared by the interpreter
+// and VM, `exit` isn't an error, it only sets the exit code
+func (in *Interpreter) recoverRuntime(err *error) {
+	if e := recover(); e != nil {
+		switch e := e.(type) {
+		case *ProgramExit:
+			in.exitCode = e.code
+			in.exited = true
+			return
+		case *RuntimeError:
+			*err = e
+		case *Exception:
+			*err = e.uncaught()
+		case *FunctionReturn:
+			// rejected by parser, only reachable by running unparsed stmts
+			*err = NewRuntimeError(e.token, "can't return from top-level code")
+		default:
+			panic(e)
+		}
+		in.exitCode = exitCodeRuntimeError
+	}
+}
+
+// run stmts in env, restore previous env when done(even if panic)
+func (in *Interpreter) executeBlock(stmts []Stmt, env *Env) {
+	prev := in.env
+	in.env = env
+	defer func() {
+		in.env = prev
+	}()
+
+	for _, stmt := range stmts {
+		in.execute(stmt)
+	}
+}
+
+// all statements are run through execute, so they can be traced
+func (in *Interpreter) execute(stmt Stmt) {
+	if in.trace {
+		in.traceLine(stmt.Print())
+		in.traceLevel++
+		defer func() { in.traceLevel-- }()
+	}
+	stmt.Run(in)
+}
+
+// all expressions are evaluated through eval, so they can be traced
+func (in *Interpreter) eval(expr Expr) Val {
+	val := expr.Eval(in)
+	if in.trace {
+		in.traceLine(expr.Print() + " => " + stringify(val))
+	}
+	return val
+}
+
+// calls, property and index accesses form a chain, each link evaluates
+// the one before it with evalLink, skipped is true if a `?.` found a nil
+// object and the rest of the chain evaluates to nil without running
+type chainLink interface {
+	evalLink(in *Interpreter) (val Val, skipped bool)
+}
+
+// evaluate the object of a chain link
+func (in *Interpreter) evalObject(expr Expr) (val Val, skipped bool) {
+	link, ok := expr.(chainLink)
+	if !ok {
+		return in.eval(expr), false
+	}
+	val, skipped = link.evalLink(in)
+	if in.trace && !skipped {
+		in.traceLine(expr.Print() + " => " + stringify(val))
+	}
+	return val, skipped
+}
+
+func (in *Interpreter) traceLine(line string) {
+	fmt.Fprintln(in.stdout, strings.Repeat("  ", in.traceLevel)+line)
+}
+
+// evaluate expr in env, restore previous env when done(even if panic)
+func (in *Interpreter) evaluateIn(expr Expr, env *Env) Val {
+	prev := in.env
+	in.env = env
+	defer func() {
+		in.env = prev
+	}()
+
+	return in.eval(expr)
+}
+
+type RuntimeError struct {
+	token *Token
+	msg   string
+	fatal bool // raised by `panic`, `catch` doesn't stop it
+}
+
+func NewRuntimeError(token *Token, msg string) *RuntimeError {
+	return &RuntimeError{token, msg, false}
+}
+
+// raised by `exit`, unwinds the program like an error, so `finally`
+// blocks still run
+type ProgramExit struct {
+	code int
+}
+
+// we use exception as control flow, token is the `return` keyword
+type FunctionReturn struct {
+	token *Token
+	value Val
+}
+
+func NewFunctionReturn(token *Token, value Val) *FunctionReturn {
+	return &FunctionReturn{token, value}
+}
+
+// raised by a return in tail position calling a Lox function, the caller
+// runs it in place of the current call
+type TailCall struct {
+	expr      *ExprCall
+	function  *StmtFuncDecl
+	arguments []Val
+}
+
+func NewTailCall(expr *ExprCall, function *StmtFuncDecl, arguments []Val) *TailCall {
+	return &TailCall{expr, function, arguments}
+}
+
+// raised by `throw`, value can be anything
+type Exception struct {
+	keyword *Token
+	value   Val
+}
+
+func NewException(keyword *Token, value Val) *Exception {
+	return &Exception{keyword, value}
+}
+
+// reported when the exception reaches top level, an error stringifying the
+// value, e.g. raised by `toString`, is reported instead
+func (e *Exception) uncaught() (err *RuntimeError) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *RuntimeError:
+			err = r
+		case *Exception:
+			err = r.uncaught()
+		default:
+			panic(r)
+		}
+	}()
+	return NewRuntimeError(e.keyword, "uncaught exception: "+stringify(e.value))
+}
+
+// label is the target loop, "" for the innermost one
+type LoopBreak struct {
+	label string
+}
+type LoopContinue struct {
+	label string
+}
+
+func (re *RuntimeError) Error() string {
+	return fmt.Sprintf("line %d, col %d, %s", re.token.line, re.token.column, re.msg)
+}
+
+func (re *RuntimeError) Position() (line, column int) {
+	return re.token.line, re.token.column
+}
+
+func (re *RuntimeError) message() string {
+	return re.msg
+}
+
+// depth and slot are computed by resolver, -1 means global variable
+func (in *Interpreter) getVariable(name *Token, depth int, slot int) Val {
+	if depth < 0 {
+		return in.globals.Get(name)
+	}
+	return in.env.GetAt(depth, slot, name)
+}
+
+func (in *Interpreter) setVariable(name *Token, depth int, slot int, val Val) {
+	if depth < 0 {
+		in.globals.Set(name, val)
+	} else {
+		in.env.SetAt(depth, slot, name, val)
+	}
+}
+
+// slot is -1 for globals, the current env is the global one then
+func (in *Interpreter) define(name *Token, slot int, val Val) {
+	if slot < 0 {
+		in.env.Define(name.lexeme, val)
+	} else {
+		in.env.DefineSlot(slot, name.lexeme, val)
+	}
+}
+
+/*----------  Stmt: Print  ----------*/
+
+func (s *StmtPrint) Run(in *Interpreter) {
+	val := in.eval(s.expr)
+	fmt.Fprintln(in.stdout, stringify(val))
+}
+
+/*----------  Stmt: Assert  ----------*/
+
+func (s *StmtAssert) Run(in *Interpreter) {
+	if getTruthy(in.eval(s.condition)) {
+		return
+	}
+	msg := "assertion failed"
+	if s.message != nil {
+		msg = stringify(in.eval(s.message))
+	}
+	panic(NewRuntimeError(s.keyword, msg))
+}
+
+/*----------  Stmt: Import  ----------*/
+
+// run the imported file in global env
+func (s *StmtImport) Run(in *Interpreter) {
+	path := s.path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(in.dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		panic(NewRuntimeError(s.keyword, sprintf("can't import '%s': %v", s.path, err)))
+	}
+	if in.importing[path] {
+		panic(NewRuntimeError(s.keyword, sprintf("cyclic import of '%s'", s.path)))
+	}
+	if err := in.importFile(path); err != nil {
+		panic(NewRuntimeError(s.keyword, sprintf("can't import '%s': %v", s.path, err)))
+	}
+}
+
+/*----------  Stmt: Try  ----------*/
+
+// finally block runs no matter how the try statement exits, including
+// break, continue and return
+func (s *StmtTry) Run(in *Interpreter) {
+	if s.finallyBlock != nil {
+		defer in.executeBlock(s.finallyBlock, NewEnv(in.env))
+	}
+
+	if s.name == nil {
+		in.executeBlock(s.tryBlock, NewEnv(in.env))
+		return
+	}
+
+	if caught, ok := in.runCatching(s.tryBlock); ok {
+		env := NewEnv(in.env)
+		env.DefineSlot(0, s.name.lexeme, caught)
+		in.executeBlock(s.catchBlock, env)
+	}
+}
+
+// run stmts in a new block, thrown value or runtime error is recovered
+// and returned, runtime error is converted to a map with `message`, `line`
+// and `column` keys
+func (in *Interpreter) runCatching(stmts []Stmt) (caught Val, ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			switch e := e.(type) {
+			case *Exception:
+				caught, ok = e.value, true
+			case *RuntimeError:
+				if e.fatal {
+					panic(e)
+				}
+				m := NewLoxMap()
+				m.Set(nil, "message", e.msg)
+				if e.token != nil {
+					m.Set(nil, "line", Number(e.token.line))
+					m.Set(nil, "column", Number(e.token.column))
+				}
+				caught, ok = m, true
+			default:
+				panic(e)
+			}
+		}
+	}()
+
+	in.executeBlock(stmts, NewEnv(in.env))
+	return nil, false
+}
+
+/*----------  Stmt: Throw  ----------*/
+
+func (s *StmtThrow) Run(in *Interpreter) {
+	panic(NewException(s.keyword, in.eval(s.value)))
+}
+
+/*----------  Stmt: Defer  ----------*/
+
+// callee and arguments are evaluated now, the call is made when the
+// function exits
+func (s *StmtDefer) Run(in *Interpreter) {
+	callee, skipped := in.evalObject(s.call.callee)
+	if skipped {
+		return
+	}
+	arguments := s.call.evalArguments(in)
+	env := in.env.Ancestor(s.depth)
+	env.deferred = append(env.deferred, func() {
+		s.call.call(in, callee, arguments)
+	})
+}
+
+/*----------  Stmt: Expression  ----------*/
+
+func (s *StmtExpression) Run(in *Interpreter) {
+	in.eval(s.expr)
+}
+
+/*----------  Stmt: Variable Declaration  ----------*/
+
+func (s *StmtVarDecl) Run(in *Interpreter) {
+	var val Val
+	if s.value != nil {
+		val = in.eval(s.value)
+	}
+	in.define(s.name, s.slot, val)
+}
+
+/*----------  Stmt: Variable Unpacking  ----------*/
+
+func (s *StmtVarUnpack) Run(in *Interpreter) {
+	tuple, ok := in.eval(s.value).(*LoxTuple)
+	if !ok {
+		panic(NewRuntimeError(s.paren, "can only unpack a tuple"))
+	}
+	if len(tuple.elements) != len(s.names) {
+		values := "values"
+		if len(s.names) == 1 {
+			values = "value"
+		}
+		panic(NewRuntimeError(s.paren, sprintf("expect %d %s to unpack but got %d", len(s.names), values, len(tuple.elements))))
+	}
+	for i, name := range s.names {
+		in.define(name, s.slots[i], tuple.elements[i])
+	}
+}
+
+/*----------  Stmt: Constant Declaration  ----------*/
+
+func (s *StmtConstDecl) Run(in *Interpreter) {
+	val := in.eval(s.value)
+	if s.slot < 0 {
+		in.env.DefineConst(s.name.lexeme, val)
+	} else {
+		in.env.DefineConstSlot(s.slot, s.name.lexeme, val)
+	}
+}
+
+/*----------  Stmt: Block  ----------*/
+
+func (s *StmtBlock) Run(in *Interpreter) {
+	in.executeBlock(s.stmts, NewEnv(in.env))
+}
+
+/*----------  Stmt: If  ----------*/
+
+func (s *StmtIf) Run(in *Interpreter) {
+	val := in.eval(s.condition)
+	if getTruthy(val) {
+		in.execute(s.trueBranch)
+	} else {
+		if s.falseBranch != nil {
+			in.execute(s.falseBranch)
+		}
+	}
+}
+
+/*----------  Stmt: While  ----------*/
+
+func (s *StmtWhile) Run(in *Interpreter) {
+	for getTruthy(in.eval(s.condition)) {
+		if !runLoopBody(in, labelName(s.label), s.body) {
+			break
+		}
+	}
+}
+
+/*----------  Stmt: Do While  ----------*/
+
+// `continue` skips to the condition test
+func (s *StmtDoWhile) Run(in *Interpreter) {
+	for {
+		if !runLoopBody(in, labelName(s.label), s.body) || !getTruthy(in.eval(s.condition)) {
+			break
+		}
+	}
+}
+
+/*----------  Stmt: For  ----------*/
+
+func (s *StmtFor) Run(in *Interpreter) {
+	prev := in.env
+	defer func() {
+		in.env = prev
+	}()
+
+	in.env = NewEnv(prev)
+	if s.initializer != nil {
+		in.execute(s.initializer)
+	}
+
+	for s.condition == nil || getTruthy(in.eval(s.condition)) {
+		if !runLoopBody(in, labelName(s.label), s.body) {
+			break
+		}
+		// every iteration has its own copy of loop variables, so closures
+		// created in body capture the value of that iteration
+		in.env = in.env.copy()
+		if s.increment != nil {
+			in.eval(s.increment)
+		}
+	}
+}
+
+/*----------  Stmt: For Each  ----------*/
+
+// a list is iterated by index, elements appended in body are visited too,
+// a map is iterated over the keys it has when the loop starts, in
+// insertion order. An instance with method `iter` is iterated over the
+// iterator it returns, which is asked `hasNext()` before every `next()`.
+func (s *StmtForEach) Run(in *Interpreter) {
+	switch iterable := in.eval(s.iterable).(type) {
+	case *LoxList:
+		for i := 0; i < len(iterable.elements); i++ {
+			if !s.runIteration(in, iterable.elements[i]) {
+				break
+			}
+		}
+	case *LoxMap:
+		keys := append([]Val(nil), iterable.keys...)
+		for _, key := range keys {
+			if !s.runIteration(in, key) {
+				break
+			}
+		}
+	case *LoxInstance:
+		if iterable.class.findMethod("iter") == nil {
+			panic(NewRuntimeError(s.keyword, "can only iterate over lists, maps and instances with 'iter' method"))
+		}
+		iterator, ok := s.callMethod(in, iterable, "iter").(*LoxInstance)
+		if !ok {
+			panic(NewRuntimeError(s.keyword, "'iter' must return an instance"))
+		}
+		for getTruthy(s.callMethod(in, iterator, "hasNext")) {
+			if !s.runIteration(in, s.callMethod(in, iterator, "next")) {
+				break
+			}
+		}
+	default:
+		panic(NewRuntimeError(s.keyword, "can only iterate over lists, maps and instances with 'iter' method"))
+	}
+}
+
+// call a method of the iterator protocol, which takes no arguments, with
+// the same depth limit as calls
+func (s *StmtForEach) callMethod(in *Interpreter, instance *LoxInstance, name string) Val {
+	method := instance.class.findMethod(name)
+	if method == nil {
+		panic(NewRuntimeError(s.keyword, sprintf("iterator must have method '%s'", name)))
+	}
+	if min, _ := method.Arity(); min > 0 {
+		panic(NewRuntimeError(s.keyword, sprintf("method '%s' of iterator must take no arguments", name)))
+	}
+	in.enterCall(s.keyword)
+	defer func() { in.depth-- }()
+	return method.bind(instance).Call(in, nil)
+}
+
+// every iteration has its own env, so closures created in body capture
+// the value of that iteration
+func (s *StmtForEach) runIteration(in *Interpreter, val Val) bool {
+	prev := in.env
+	defer func() {
+		in.env = prev
+	}()
+
+	in.env = NewEnv(prev)
+	in.env.DefineSlot(0, s.name.lexeme, val)
+	return runLoopBody(in, labelName(s.label), s.body)
+}
+
+// return false if loop should be terminated, `break` and `continue`
+// targeting another label are passed on to enclosing loops
+func runLoopBody(in *Interpreter, label string, body Stmt) (next bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			switch e := e.(type) {
+			case *LoopBreak:
+				if e.label != "" && e.label != label {
+					panic(e)
+				}
+				next = false
+			case *LoopContinue:
+				if e.label != "" && e.label != label {
+					panic(e)
+				}
+				next = true
+			default:
+				panic(e)
+			}
+		}
+	}()
+	in.execute(body)
+	return true
+}
+
+/*----------  Stmt: Break  ----------*/
+
+func (s *StmtBreak) Run(in *Interpreter) {
+	panic(&LoopBreak{labelName(s.label)})
+}
+
+/*----------  Stmt: Continue  ----------*/
+
+func (s *StmtContinue) Run(in *Interpreter) {
+	panic(&LoopContinue{labelName(s.label)})
+}
+
+/*----------  Stmt: Function Declaration  ----------*/
+
+func (s *StmtFuncDecl) Run(in *Interpreter) {
+	in.define(s.name, s.slot, s.withClosure(in.env))
+}
+
+/*----------  Stmt: Class Declaration  ----------*/
+
+func (s *StmtClassDecl) Run(in *Interpreter) {
+	var superclass *LoxClass
+	env := in.env
+	if s.superclass != nil {
+		class, ok := in.eval(s.superclass).(*LoxClass)
+		if !ok {
+			panic(NewRuntimeError(s.superclass.name, "superclass must be a class"))
+		}
+		superclass = class
+
+		// methods of subclass see `super` in their closure
+		env = NewEnv(in.env)
+		env.DefineSlot(0, "super", superclass)
+	}
+
+	methods := map[string]*StmtFuncDecl{}
+	for _, method := range s.methods {
+		methods[method.name.lexeme] = method.withClosure(env)
+	}
+	class := NewLoxClass(s.name.lexeme, superclass, methods)
+	class.in = in
+	for _, method := range s.classMethods {
+		class.classMethods[method.name.lexeme] = method.withClosure(in.env)
+	}
+	in.define(s.name, s.slot, class)
+}
+
+/*----------  Stmt: Return  ----------*/
+
+func (s *StmtReturn) Run(in *Interpreter) {
+	var value Val
+	if s.tail {
+		call := s.value.(*ExprCall)
+		callee, skipped := in.evalObject(call.callee)
+		if skipped {
+			panic(NewFunctionReturn(s.token, nil))
+		}
+		arguments := call.evalArguments(in)
+		if function, ok := callee.(*StmtFuncDecl); ok {
+			call.checkArity(function, len(arguments))
+			panic(NewTailCall(call, function, arguments))
+		}
+		value = call.call(in, callee, arguments)
+	} else if s.value != nil {
+		value = in.eval(s.value)
+	}
+	panic(NewFunctionReturn(s.token, value))
+}
+
+/*----------  Expr: Assignment  ----------*/
+
+func (expr *ExprAssignment) Eval(in *Interpreter) Val {
+	val := in.eval(expr.val)
+	in.setVariable(expr.name, expr.depth, expr.slot, val)
+	return val
+}
+
+/*----------  Expr: Literal  ----------*/
+
+func (expr *ExprLiteral) Eval(in *Interpreter) Val {
+	return expr.value
+}
+
+/*----------  Expr: Unary  ----------*/
+
+func (expr *ExprUnary) Eval(in *Interpreter) Val {
+	return unary(expr.operator, in.eval(expr.operand))
+}
+
+// shared by the interpreter and VM, operator locates errors
+func unary(operator *Token, value Val) Val {
+	switch operator.typ {
+	case BANG:
+		return !getTruthy(value)
+	case MINUS:
+		if !isNumber(value) {
+			panic(NewRuntimeError(operator, "operand must be a number"))
+		}
+		return -toNumber(value)
+	}
+
+	// unreachable
+	panic("should neven reach here")
+}
+
+/*----------  Expr: Binary  ----------*/
+func (expr *ExprBinary) Eval(in *Interpreter) Val {
+	return in.binary(expr.operator, in.eval(expr.left), in.eval(expr.right))
+}
+
+// methods overloading operators, `a + b` calls `a.add(b)` when a is an
+// instance, `a != b` is `!a.equals(b)`
+var operatorMethods = map[TokenType]string{
+	PLUS:          "add",
+	MINUS:         "sub",
+	STAR:          "mul",
+	SLASH:         "div",
+	PERCENT:       "mod",
+	EQUAL_EQUAL:   "equals",
+	BANG_EQUAL:    "equals",
+	LESS:          "lessThan",
+	LESS_EQUAL:    "lessEqual",
+	GREATER:       "greaterThan",
+	GREATER_EQUAL: "greaterEqual",
+}
+
+// an instance on the left dispatches to its operator method, other operands
+// follow the rules of binary. Instances without `equals` are compared by
+// identity.
+func (in *Interpreter) binary(operator *Token, left, right Val) Val {
+	instance, ok := left.(*LoxInstance)
+	name, overloadable := operatorMethods[operator.typ]
+	if !ok || !overloadable {
+		return binary(operator, left, right)
+	}
+	method := instance.class.findMethod(name)
+	isEquality := operator.typ == EQUAL_EQUAL || operator.typ == BANG_EQUAL
+	if method == nil {
+		if isEquality {
+			return binary(operator, left, right)
+		}
+		panic(NewRuntimeError(operator, sprintf("%s must have method '%s' to use '%s'", instance, name, operator.lexeme)))
+	}
+	if min, max := method.Arity(); min > 1 || max == 0 {
+		panic(NewRuntimeError(operator, sprintf("method '%s' must take one argument", name)))
+	}
+
+	in.enterCall(operator)
+	defer func() { in.depth-- }()
+	result := method.bind(instance).Call(in, []Val{right})
+	if isEquality {
+		return getTruthy(result) == (operator.typ == EQUAL_EQUAL)
+	}
+	return result
+}
+
+// shared by the interpreter and VM, operator locates errors
+func binary(operator *Token, left, right Val) Val {
+	checkNumberOperands := func() {
+		if isNumber(left) && isNumber(right) {
+			return
+		}
+		panic(NewRuntimeError(operator, "operands must be numbers"))
+	}
+
+	// bitwise operators work on integral numbers
+	integerOperands := func() (int64, int64) {
+		if isInteger(left) && isInteger(right) {
+			return int64(toNumber(left)), int64(toNumber(right))
+		}
+		panic(NewRuntimeError(operator, "operands must be integers"))
+	}
+
+	// strings are compared lexicographically, return true if operands are
+	// strings
+	checkComparableOperands := func() bool {
+		if isNumber(left) && isNumber(right) {
+			return false
+		}
+		if isString(left) && isString(right) {
+			return true
+		}
+		panic(NewRuntimeError(operator, "operands must be two numbers or two strings"))
+	}
+
+	switch operator.typ {
+	case PLUS:
+		if isNumber(left) && isNumber(right) {
+			return toNumber(left) + toNumber(right)
+		}
+		if isString(left) && isString(right) {
+			return toString(left) + toString(right)
+		}
+		panic(NewRuntimeError(operator, "operands must be two numbers or two strings"))
+	case MINUS:
+		checkNumberOperands()
+		return toNumber(left) - toNumber(right)
+	case SLASH:
+		checkNumberOperands()
+		// catch divide by zero
+		r := toNumber(right)
+		if r == 0 {
+			panic(NewRuntimeError(operator, "divide by zero"))
+		}
+		return toNumber(left) / r
+	case PERCENT:
+		checkNumberOperands()
+		r := toNumber(right)
+		if r == 0 {
+			panic(NewRuntimeError(operator, "modulo by zero"))
+		}
+		return Number(math.Mod(float64(toNumber(left)), float64(r)))
+	case STAR:
+		checkNumberOperands()
+		return toNumber(left) * toNumber(right)
+	case STAR_STAR:
+		checkNumberOperands()
+		return Number(math.Pow(float64(toNumber(left)), float64(toNumber(right))))
+	case GREATER:
+		if checkComparableOperands() {
+			return toString(left) > toString(right)
+		}
+		return toNumber(left) > toNumber(right)
+	case GREATER_EQUAL:
+		if checkComparableOperands() {
+			return toString(left) >= toString(right)
+		}
+		return toNumber(left) >= toNumber(right)
+	case LESS:
+		if checkComparableOperands() {
+			return toString(left) < toString(right)
+		}
+		return toNumber(left) < toNumber(right)
+	case LESS_EQUAL:
+		if checkComparableOperands() {
+			return toString(left) <= toString(right)
+		}
+		return toNumber(left) <= toNumber(right)
+	case AMPERSAND:
+		l, r := integerOperands()
+		return Number(l & r)
+	case PIPE:
+		l, r := integerOperands()
+		return Number(l | r)
+	case CARET:
+		// logical xor for booleans
+		if isBool(left) && isBool(right) {
+			return left != right
+		}
+		l, r := integerOperands()
+		return Number(l ^ r)
+	case LESS_LESS, GREATER_GREATER:
+		l, r := integerOperands()
+		if r < 0 {
+			panic(NewRuntimeError(operator, "negative shift count"))
+		}
+		if operator.typ == LESS_LESS {
+			return Number(l << uint64(r))
+		}
+		return Number(l >> uint64(r))
+	case EQUAL_EQUAL:
+		return isEqual(left, right)
+	case BANG_EQUAL:
+		return !isEqual(left, right)
+	case ISA:
+		class, ok := right.(*LoxClass)
+		if !ok {
+			panic(NewRuntimeError(operator, "right operand of 'isa' must be a class"))
+		}
+		instance, ok := left.(*LoxInstance)
+		return ok && instance.class.inherits(class)
+	}
+
+	// unreachable
+	panic("should neven reach here")
+}
+
+/*----------  Expr: Grouping  ----------*/
+
+func (expr *ExprGrouping) Eval(in *Interpreter) Val {
+	return in.eval(expr.operand)
+}
+
+/*----------  Expr: Variable  ----------*/
+
+func (expr *ExprVariable) Eval(in *Interpreter) Val {
+	return in.getVariable(expr.name, expr.depth, expr.slot)
+}
+
+/*----------  Expr: Logical  ----------*/
+
+// returns an operand rather than a bool, the right operand is evaluated only
+// if the left one doesn't decide the result, e.g. `nil or 5` is 5 and
+// `0 and "x"` is "x" since 0 is truthy
+func (expr *ExprLogical) Eval(in *Interpreter) Val {
+	val := in.eval(expr.left)
+	if shortCircuits(expr.operator, val) {
+		return val
+	}
+	return in.eval(expr.right)
+}
+
+// whether left operand of a logical operator is its result, `??` only
+// evaluates right operand if left one is nil, false included
+func shortCircuits(operator *Token, left Val) bool {
+	switch operator.typ {
+	case OR:
+		return getTruthy(left)
+	case QUESTION_QUESTION:
+		return left != nil
+	default:
+		return !getTruthy(left)
+	}
+}
+
+/*----------  Expr: Ternary  ----------*/
+
+// only the taken branch is evaluated
+func (expr *ExprTernary) Eval(in *Interpreter) Val {
+	if getTruthy(in.eval(expr.condition)) {
+		return in.eval(expr.thenBranch)
+	}
+	return in.eval(expr.elseBranch)
+}
+
+/*----------  Expr: Function Call  ----------*/
+
+func (expr *ExprCall) Eval(in *Interpreter) Val {
+	val, _ := expr.evalLink(in)
+	return val
+}
+
+func (expr *ExprCall) evalLink(in *Interpreter) (Val, bool) {
+	callee, skipped := in.evalObject(expr.callee)
+	if skipped {
+		return nil, true
+	}
+	return expr.call(in, callee, expr.evalArguments(in)), false
+}
+
+func (expr *ExprCall) evalArguments(in *Interpreter) []Val {
+	var arguments []Val
+	for _, arg := range expr.arguments {
+		arguments = append(arguments, in.eval(arg))
+	}
+	return arguments
+}
+
+func (expr *ExprCall) call(in *Interpreter, callee Val, arguments []Val) Val {
+	if function, ok := callee.(Callable); ok {
+		expr.checkArity(function, len(arguments))
+		in.enterCall(expr.paren)
+		defer func() { in.depth-- }()
+		if _, ok := function.(*Function); ok {
+			defer expr.locateNativeError()
+		}
+		return function.Call(in, arguments)
+	} else {
+		panic(NewRuntimeError(expr.paren, "can only call functions and classes"))
+	}
+}
+
+// the caller decrements depth when the call returns
+func (in *Interpreter) enterCall(token *Token) {
+	if in.depth >= in.maxDepth {
+		panic(NewRuntimeError(token, "stack overflow"))
+	}
+	in.depth++
+}
+
+/*----------  Expr: Property Get  ----------*/
+
+func (expr *ExprGet) Eval(in *Interpreter) Val {
+	val, _ := expr.evalLink(in)
+	return val
+}
+
+func (expr *ExprGet) evalLink(in *Interpreter) (Val, bool) {
+	object, skipped := in.evalObject(expr.object)
+	if skipped || (object == nil && expr.optional) {
+		return nil, true
+	}
+	if instance, ok := object.(*LoxInstance); ok {
+		return instance.Get(in, expr.name), false
+	}
+	if class, ok := object.(*LoxClass); ok {
+		return class.Get(in, expr.name), false
+	}
+	panic(NewRuntimeError(expr.name, "only instances and classes have properties"))
+}
+
+/*----------  Expr: Property Set  ----------*/
+
+func (expr *ExprSet) Eval(in *Interpreter) Val {
+	object := in.eval(expr.object)
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(NewRuntimeError(expr.name, "only instances have fields"))
+	}
+	val := in.eval(expr.val)
+	instance.Set(expr.name, val)
+	return val
+}
+
+/*----------  Expr: This  ----------*/
+
+func (expr *ExprThis) Eval(in *Interpreter) Val {
+	return in.env.GetAt(expr.depth, 0, expr.keyword)
+}
+
+/*----------  Expr: Super  ----------*/
+
+func (expr *ExprSuper) Eval(in *Interpreter) Val {
+	superclass := in.env.GetAt(expr.depth, 0, expr.keyword).(*LoxClass)
+	this := NewToken(THIS, "this", nil, expr.keyword.line, expr.keyword.column)
+	instance := in.env.GetAt(expr.depth-1, 0, this).(*LoxInstance)
+
+	method := superclass.findMethod(expr.method.lexeme)
+	if method == nil {
+		panic(NewRuntimeError(expr.method, sprintf("undefined property '%s'", expr.method.lexeme)))
+	}
+	return in.access(expr.method, method.bind(instance))
+}
+
+// a getter is called with the same depth limit as calls, other methods are returned as is
+func (in *Interpreter) access(name *Token, method *StmtFuncDecl) Val {
+	if !method.getter {
+		return method
+	}
+	in.enterCall(name)
+	defer func() { in.depth-- }()
+	return method.Call(in, nil)
+}
+
+/*----------  Expr: List Literal  ----------*/
+
+func (expr *ExprListLiteral) Eval(in *Interpreter) Val {
+	elements := make([]Val, 0, len(expr.elements))
+	for _, element := range expr.elements {
+		elements = append(elements, in.eval(element))
+	}
+	return NewLoxList(elements)
+}
+
+/*----------  Expr: Interpolation  ----------*/
+
+// every part is stringified the same as `print` does
+func (expr *ExprInterpolation) Eval(in *Interpreter) Val {
+	vals := make([]Val, len(expr.parts))
+	for i, part := range expr.parts {
+		vals[i] = in.eval(part)
+	}
+	return interpolate(vals)
+}
+
+// shared by the interpreter, VM and optimizer
+func interpolate(vals []Val) string {
+	var b strings.Builder
+	for _, val := range vals {
+		b.WriteString(stringify(val))
+	}
+	return b.String()
+}
+
+/*----------  Expr: Tuple  ----------*/
+
+func (expr *ExprTuple) Eval(in *Interpreter) Val {
+	elements := make([]Val, 0, len(expr.elements))
+	for _, element := range expr.elements {
+		elements = append(elements, in.eval(element))
+	}
+	return NewLoxTuple(elements)
+}
+
+/*----------  Expr: Map Literal  ----------*/
+
+func (expr *ExprMapLiteral) Eval(in *Interpreter) Val {
+	m := NewLoxMap()
+	for i := range expr.keys {
+		key := in.eval(expr.keys[i])
+		m.Set(expr.brace, key, in.eval(expr.values[i]))
+	}
+	return m
+}
+
+/*----------  Expr: Index Get  ----------*/
+
+func (expr *ExprIndexGet) Eval(in *Interpreter) Val {
+	val, _ := expr.evalLink(in)
+	return val
+}
+
+func (expr *ExprIndexGet) evalLink(in *Interpreter) (Val, bool) {
+	object, skipped := in.evalObject(expr.object)
+	if skipped {
+		return nil, true
+	}
+	index := in.eval(expr.index)
+	switch object := object.(type) {
+	case *LoxList:
+		return object.Get(expr.bracket, index), false
+	case *LoxMap:
+		return object.Get(expr.bracket, index), false
+	}
+	panic(NewRuntimeError(expr.bracket, "can only index lists and maps"))
+}
+
+/*----------  Expr: Index Set  ----------*/
+
+func (expr *ExprIndexSet) Eval(in *Interpreter) Val {
+	object := in.eval(expr.object)
+	index := in.eval(expr.index)
+	val := in.eval(expr.val)
+	switch object := object.(type) {
+	case *LoxList:
+		object.Set(expr.bracket, index, val)
+		return val
+	case *LoxMap:
+		object.Set(expr.bracket, index, val)
+		return val
+	}
+	panic(NewRuntimeError(expr.bracket, "can only index lists and maps"))
+}
+
+/*----------  Expr: Comma  ----------*/
+
+func (expr *ExprComma) Eval(in *Interpreter) Val {
+	var val Val
+	for _, e := range expr.exprs {
+		val = in.eval(e)
+	}
+	return val
+}
+
+/*----------  Expr: Update  ----------*/
+
+// read-modify-write, sub-expressions of target are evaluated only once
+func (expr *ExprUpdate) Eval(in *Interpreter) Val {
+	var old, val Val
+	switch target := expr.target.(type) {
+	case *ExprVariable:
+		old = in.eval(target)
+		val = expr.apply(old)
+		in.setVariable(target.name, target.depth, target.slot, val)
+	case *ExprGet:
+		instance, ok := in.eval(target.object).(*LoxInstance)
+		if !ok {
+			panic(NewRuntimeError(target.name, "only instances have fields"))
+		}
+		old = instance.Get(in, target.name)
+		val = expr.apply(old)
+		instance.Set(target.name, val)
+	case *ExprIndexGet:
+		object := in.eval(target.object)
+		index := in.eval(target.index)
+		switch object := object.(type) {
+		case *LoxList:
+			old = object.Get(target.bracket, index)
+			val = expr.apply(old)
+			object.Set(target.bracket, index, val)
+		case *LoxMap:
+			old = object.Get(target.bracket, index)
+			val = expr.apply(old)
+			object.Set(target.bracket, index, val)
+		default:
+			panic(NewRuntimeError(target.bracket, "can only index lists and maps"))
+		}
+	}
+
+	if expr.prefix {
+		return val
+	}
+	return old
+}
+
+func (expr *ExprUpdate) apply(old Val) Val {
+	return increment(expr.operator, old)
+}
+
+// `++` or `--` applied to old, shared by the interpreter and VM
+func increment(operator *Token, old Val) Val {
+	if !isNumber(old) {
+		panic(NewRuntimeError(operator, "operand must be a number"))
+	}
+	if operator.typ == PLUS_PLUS {
+		return toNumber(old) + 1
+	}
+	return toNumber(old) - 1
+}
+
+/*----------  Expr: Block  ----------*/
+
+func (expr *ExprBlock) Eval(in *Interpreter) Val {
+	env := NewEnv(in.env)
+	in.executeBlock(expr.stmts, env)
+	return in.evaluateIn(expr.value, env)
+}
+
+/*----------  Expr: Function  ----------*/
+
+// a named function is bound to its name in a new env enclosing the
+// closure, so it can call itself whatever it's assigned to
+func (expr *ExprFunction) Eval(in *Interpreter) Val {
+	if expr.decl.name == nil {
+		return expr.decl.withClosure(in.env)
+	}
+	env := NewEnv(in.env)
+	fn := expr.decl.withClosure(env)
+	env.DefineSlot(0, expr.decl.name.lexeme, fn)
+	return fn
+}
+
+// arity is the range [min, max] of argument counts, max is -1 if unlimited
+func (expr *ExprCall) checkArity(function Callable, got int) {
+	min, max := function.Arity()
+	if got >= min && (max < 0 || got <= max) {
+		return
+	}
+	var expected string
+	switch {
+	case min == max:
+		expected = fmt.Sprintf("%d", min)
+	case max < 0:
+		expected = fmt.Sprintf("at least %d", min)
+	default:
+		expected = fmt.Sprintf("%d to %d", min, max)
+	}
+	panic(NewRuntimeError(expr.paren, fmt.Sprintf("expect %s arguments but got %d", expected, got)))
+}
+
+// native functions raise errors without token, report them at the call site
+func (expr *ExprCall) locateNativeError() {
+	if e := recover(); e != nil {
+		if re, ok := e.(*RuntimeError); ok && re.token == nil {
+			re.token = expr.paren
+		}
+		panic(e)
+	}
+}
+
+/*----------  Helper Methods  ----------*/
+
+// lists and maps are compared structurally, other values by identity
+func isEqual(a, b Val) bool {
+	return deepEqual(a, b, map[[2]Val]bool{})
+}
+
+// visited holds pairs being compared, a pair met again is part of a cycle
+// and considered equal, the rest of the comparison decides the result
+func deepEqual(a, b Val, visited map[[2]Val]bool) bool {
+	if a == b {
+		return true
+	}
+	switch x := a.(type) {
+	case *LoxList:
+		y, ok := b.(*LoxList)
+		if !ok || len(x.elements) != len(y.elements) {
+			return false
+		}
+		pair := [2]Val{x, y}
+		if visited[pair] {
+			return true
+		}
+		visited[pair] = true
+		for i := range x.elements {
+			if !deepEqual(x.elements[i], y.elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *LoxTuple:
+		y, ok := b.(*LoxTuple)
+		if !ok || len(x.elements) != len(y.elements) {
+			return false
+		}
+		for i := range x.elements {
+			if !deepEqual(x.elements[i], y.elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *LoxMap:
+		y, ok := b.(*LoxMap)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		pair := [2]Val{x, y}
+		if visited[pair] {
+			return true
+		}
+		visited[pair] = true
+		for _, key := range x.keys {
+			val, ok := y.lookup(key)
+			if !ok || !deepEqual(x.m[key], val, visited) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// `false` and `nil` is false
+// everything else is true
+func getTruthy(val Val) bool {
+	if val == nil {
+		return false
+	}
+	if b, ok := val.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// display string of a value, integral numbers are printed without decimal
+// point
+func stringify(val Val) string {
+	return stringifyVisiting(val, map[Val]bool{})
+}
+
+// visiting holds containers being printed, so self-referential ones
+// terminate
+func stringifyVisiting(val Val, visiting map[Val]bool) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case Number:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	case string:
+		return v
+	case *LoxList:
+		return v.format(visiting)
+	case *LoxMap:
+		return v.format(visiting)
+	case *LoxTuple:
+		return v.format(visiting)
+	case *LoxInstance:
+		return v.format()
+	}
+	return fmt.Sprint(val)
+}
+
+func isNumber(val Val) bool {
+	_, ok := val.(Number)
+	return ok
+}
+
+func isBool(val Val) bool {
+	_, ok := val.(bool)
+	return ok
+}
+
+func isInteger(val Val) bool {
+	n, ok := val.(Number)
+	return ok && n == Number(math.Trunc(float64(n)))
+}
+
+func isString(val Val) bool {
+	_, ok := val.(string)
+	return ok
+}
+
+func toNumber(val Val) Number {
+	if n, ok := val.(Number); ok {
+		return n
+	}
+	// should never happen
+	panic("toNumber should always be called with a number")
+}
+
+func toString(val Val) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	// should never happen
+	panic("toString should always be called with a string")
+}
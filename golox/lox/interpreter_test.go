@@ -0,0 +1,1133 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStmtRun(t *testing.T) {
+	in := New()
+	name := NewToken(IDENTIFIER, "a", nil, 1, 1)
+
+	var stmts = []Stmt{
+		NewStmtVarDecl(name, NewExprLiteral(Number(1))),
+		NewStmtExpression(
+			NewExprAssignment(name, NewExprBinary(
+				NewExprVariable(name),
+				NewToken(PLUS, "+", nil, 1, 1),
+				NewExprLiteral(Number(2)),
+			)),
+		),
+	}
+	for _, stmt := range stmts {
+		stmt.Run(in)
+	}
+
+	assert.Equal(t, Number(3), in.globals.Get(name))
+}
+
+func TestUnaryMinus(t *testing.T) {
+	assert.Equal(t, Number(-1), evalExpr(t, "-1"))
+	assert.Equal(t, Number(1), evalExpr(t, "-(-1)"))
+
+	err := New().Run("\n\nprint -nil;")
+	assert.EqualError(t, err, "runtime error: line 3, col 7, operand must be a number")
+	err = New().Run("-true;")
+	assert.EqualError(t, err, "runtime error: line 1, col 1, operand must be a number")
+	err = New().Run(`-"x";`)
+	assert.EqualError(t, err, "runtime error: line 1, col 1, operand must be a number")
+}
+
+func TestComparison(t *testing.T) {
+	cases := map[string]bool{
+		"1 < 2":              true,
+		"2 <= 2":             true,
+		"1 > 2":              false,
+		"2 >= 3":             false,
+		`"a" < "b"`:          true,
+		`"b" > "abc"`:        true,
+		`"abc" < "abd"`:      true,
+		`"ab" < "abc"`:       true,
+		`"abc" <= "abc"`:     true,
+		`"abc" >= "ab"`:      true,
+		`"abc" > "abc"`:      false,
+		`"" < "a"`:           true,
+		`"Z" < "a"`:          true,
+		`"10" < "9"`:         true,
+		`"abc" < "ab" + "c"`: false,
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+
+	errors := map[string]int{`1 < "2";`: 3, `"a" >= nil;`: 5, "true > false;": 6}
+	for source, column := range errors {
+		err := New().Run(source)
+		assert.EqualError(t, err, sprintf("runtime error: line 1, col %d, operands must be two numbers or two strings", column))
+	}
+}
+
+func TestModulo(t *testing.T) {
+	assert.Equal(t, Number(1), evalExpr(t, "7 % 3"))
+	assert.Equal(t, Number(-1), evalExpr(t, "-7 % 3"))
+	assert.Equal(t, Number(1), evalExpr(t, "7 % -3"))
+	assert.Equal(t, Number(1.5), evalExpr(t, "5.5 % 2"))
+	assert.Equal(t, Number(7), evalExpr(t, "1 + 2 * 9 % 4 * 3"))
+
+	err := New().Run("1 % 0;")
+	assert.EqualError(t, err, "runtime error: line 1, col 3, modulo by zero")
+	err = New().Run(`1 % "a";`)
+	assert.EqualError(t, err, "runtime error: line 1, col 3, operands must be numbers")
+}
+
+func TestDivisionByZero(t *testing.T) {
+	cases := []struct {
+		source       string
+		line, column int
+		msg          string
+	}{
+		{"1 / 0;", 1, 3, "divide by zero"},
+		{"1 / (2 - 2);", 1, 3, "divide by zero"},
+		{"1 % 0;", 1, 3, "modulo by zero"},
+		{"5 % (1 - 1);", 1, 3, "modulo by zero"},
+		{"print (4 + 6 / 0);", 1, 14, "divide by zero"},
+		{"print 1 +\n  2 / 0;", 2, 5, "divide by zero"},
+		{"var a = 1\n/\n(0);", 2, 1, "divide by zero"},
+		{"var a = 3;\na /= 0;", 2, 3, "divide by zero"},
+		{"var b = [2];\nb[0] = b[0] % 0;", 2, 13, "modulo by zero"},
+	}
+	for _, c := range cases {
+		err := New().Run(c.source)
+		assert.EqualError(t, err, sprintf("runtime error: line %d, col %d, %s", c.line, c.column, c.msg), c.source)
+		var runtimeErr *RuntimeError
+		if assert.True(t, errors.As(err, &runtimeErr), c.source) {
+			line, column := runtimeErr.Position()
+			assert.Equal(t, []int{c.line, c.column}, []int{line, column}, c.source)
+		}
+
+		// the VM points at the same operator
+		if !strings.Contains(c.source, "[") {
+			_, errs := runBoth(c.source)
+			assert.EqualError(t, errs[1], errs[0].Error(), c.source)
+		}
+	}
+}
+
+func TestExponent(t *testing.T) {
+	assert.Equal(t, Number(8), evalExpr(t, "2 ** 3"))
+	// right associative
+	assert.Equal(t, Number(512), evalExpr(t, "2 ** 3 ** 2"))
+	assert.Equal(t, Number(64), evalExpr(t, "(2 ** 3) ** 2"))
+	assert.Equal(t, Number(0.25), evalExpr(t, "2 ** -2"))
+	assert.Equal(t, Number(0.5), evalExpr(t, "0.25 ** 0.5"))
+	// binds tighter than unary minus and multiplication
+	assert.Equal(t, Number(-4), evalExpr(t, "-2 ** 2"))
+	assert.Equal(t, Number(18), evalExpr(t, "2 * 3 ** 2"))
+
+	err := New().Run(`2 ** "a";`)
+	assert.EqualError(t, err, "runtime error: line 1, col 3, operands must be numbers")
+	err = New().Run(`nil ** 2;`)
+	assert.EqualError(t, err, "runtime error: line 1, col 5, operands must be numbers")
+}
+
+func TestBitwise(t *testing.T) {
+	cases := map[string]Val{
+		"12 & 10":            Number(8),
+		"12 | 10":            Number(14),
+		"12 ^ 10":            Number(6),
+		"255 & (1 << 4)":     Number(16),
+		"1 << 10":            Number(1024),
+		"1024 >> 3":          Number(128),
+		"-16 >> 2":           Number(-4),
+		"1 | 2 ^ 3 & 4 << 1": Number(3),
+		"1 + 1 << 2":         Number(8),
+		"6 & 3 == 2":         true,
+		"true ^ false":       true,
+		"true ^ true":        false,
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+
+	errors := map[string]string{
+		"1.5 & 1;":  "runtime error: line 1, col 5, operands must be integers",
+		"1 << 0.5;": "runtime error: line 1, col 3, operands must be integers",
+		`"a" | 1;`:  "runtime error: line 1, col 5, operands must be integers",
+		"true ^ 1;": "runtime error: line 1, col 6, operands must be integers",
+		"1 << -1;":  "runtime error: line 1, col 3, negative shift count",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg)
+	}
+}
+
+func TestTernary(t *testing.T) {
+	assert.Equal(t, Number(1), evalExpr(t, "true ? 1 : 2"))
+	assert.Equal(t, Number(2), evalExpr(t, "nil ? 1 : 2"))
+	assert.Equal(t, Number(2), evalExpr(t, "false ? 1 : true ? 2 : 3"))
+	assert.Equal(t, Number(3), evalExpr(t, "1 > 2 or false ? 1 : 1 == 2 ? 2 : 3"))
+
+	// untaken branch is never evaluated
+	assert.Equal(t, "then", evalExpr(t, `0 ? "then" : 1 / 0`))
+	assert.Equal(t, "else", evalExpr(t, `false ? 1 / 0 : "else"`))
+
+	lox := evalSource(t, `
+var a;
+var b = a = true ? "yes" : "no";
+`)
+	assert.Equal(t, "yes", getGlobal(lox, "a"))
+	assert.Equal(t, "yes", getGlobal(lox, "b"))
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	lox := evalSource(t, `
+var a = 10;
+a += 5;
+a -= 3;
+a *= 2;
+a /= 8;
+
+var s = "foo";
+s += "bar";
+
+var b;
+{
+  var c = 1;
+  b = c += 1;
+}
+`)
+	assert.Equal(t, Number(3), getGlobal(lox, "a"))
+	assert.Equal(t, "foobar", getGlobal(lox, "s"))
+	assert.Equal(t, Number(2), getGlobal(lox, "b"))
+
+	err := New().Run("x += 1;")
+	assert.EqualError(t, err, "runtime error: line 1, col 1, undefined variable 'x'")
+	err = New().Run(`var a = "a"; a -= 1;`)
+	assert.EqualError(t, err, "runtime error: line 1, col 16, operands must be numbers")
+	err = New().Run("1 += 1;")
+	assert.EqualError(t, err, "parse error: line 1, col 3, at '+=', invalid assignment target")
+}
+
+func TestStringify(t *testing.T) {
+	cases := []struct {
+		val      Val
+		expected string
+	}{
+		{Number(3), "3"},
+		{Number(-3), "-3"},
+		{Number(0), "0"},
+		{Number(1e21), "1000000000000000000000"},
+		{Number(3.5), "3.5"},
+		{Number(0.1), "0.1"},
+		{Number(1) / Number(3), "0.3333333333333333"},
+		{true, "true"},
+		{false, "false"},
+		{nil, "nil"},
+		{"", ""},
+		{"hello", "hello"},
+		{NewLoxList([]Val{Number(1), nil, "a"}), "[1, nil, a]"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, stringify(c.val))
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	lox := evalSource(t, `
+var name = "lox";
+var age = 3;
+var greeting = "hello ${name}, you are ${age} years old";
+var expressions = "${1 + 2} ${[1, nil]} ${age > 2 ? "old" : "new"}${nil}";
+var nested = "a ${"b ${name + "!"} c"} d";
+var braces = "${ {"k": name}["k"] }";
+var escaped = "costs \${age}, $age and $";
+var only = "${age}";
+var literal = "${"x"}${"y" + "z"}";
+`)
+	assert.Equal(t, "hello lox, you are 3 years old", getGlobal(lox, "greeting"))
+	assert.Equal(t, "3 [1, nil] oldnil", getGlobal(lox, "expressions"))
+	assert.Equal(t, "a b lox! c d", getGlobal(lox, "nested"))
+	assert.Equal(t, "lox", getGlobal(lox, "braces"))
+	assert.Equal(t, "costs ${age}, $age and $", getGlobal(lox, "escaped"))
+	// always a string, even without text around the expression
+	assert.Equal(t, "3", getGlobal(lox, "only"))
+	assert.Equal(t, "xyz", getGlobal(lox, "literal"))
+
+	// doesn't depend on the `string` builtin
+	lox = evalSource(t, `
+var string = "shadowed";
+var global = "v=${1}";
+func f(string) { return "got ${string}"; }
+var parameter = f(2);
+`)
+	assert.Equal(t, "v=1", getGlobal(lox, "global"))
+	assert.Equal(t, "got 2", getGlobal(lox, "parameter"))
+
+	err := New().Run(`print "a ${}";`)
+	assert.EqualError(t, err, "parse error: line 1, col 12, at '}\"', expect expression inside '${}'")
+	err = New().Run(`print "a ${b c}";`)
+	assert.EqualError(t, err, "parse error: line 1, col 14, at 'c', expect '}' after interpolated expression")
+}
+
+func TestBlockExpression(t *testing.T) {
+	lox := evalSource(t, `
+var a = "outer";
+var area = {
+  var width = 3;
+  var height = 4;
+  width * height
+};
+var shadowed = { var a = "inner"; a };
+var nested = { var x = 1; { var x = 2; } x + { var y = 10; y } };
+var statements = {
+  var sum = 0;
+  for (var i = 1; i <= 3; i++) sum += i;
+  sum++, sum
+};
+var value = { a };
+var emptyMap = {};
+var m = {"a": 1};
+var key = {a: 2};
+func sign(n) {
+  return {
+    if (n < 0) return -1;
+    n == 0 ? 0 : 1
+  };
+}
+var signs = [sign(-5), sign(0), sign(5)];
+var loops = 0;
+while (true) {
+  loops = { if (loops == 2) break; loops + 1 };
+}
+`)
+	assert.Equal(t, Number(12), getGlobal(lox, "area"))
+	assert.Equal(t, "inner", getGlobal(lox, "shadowed"))
+	assert.Equal(t, "outer", getGlobal(lox, "a"))
+	assert.Equal(t, Number(11), getGlobal(lox, "nested"))
+	assert.Equal(t, Number(7), getGlobal(lox, "statements"))
+	assert.Equal(t, "outer", getGlobal(lox, "value"))
+	// maps are told apart by `:` after the first key
+	assert.Equal(t, "{}", stringify(getGlobal(lox, "emptyMap")))
+	assert.Equal(t, "{a: 1}", stringify(getGlobal(lox, "m")))
+	assert.Equal(t, "{outer: 2}", stringify(getGlobal(lox, "key")))
+	assert.Equal(t, "[-1, 0, 1]", stringify(getGlobal(lox, "signs")))
+	assert.Equal(t, Number(2), getGlobal(lox, "loops"))
+	_, ok := lox.GetGlobal("width")
+	assert.False(t, ok)
+
+	// a statement starting with `{` is still a block
+	var buf bytes.Buffer
+	lox = New()
+	lox.SetOutput(&buf)
+	assert.Nil(t, lox.Run(`{ var b = 1; print b; }`))
+	assert.Equal(t, "1\n", buf.String())
+
+	errors := map[string]string{
+		"var a = { var b = 1; };":   "parse error: line 1, col 22, at '}', expect expression at end of block expression",
+		"var a = { 1 2 };":          "parse error: line 1, col 13, at '2', expect ':' after map key",
+		"var a = { print 1; 1 2 };": "parse error: line 1, col 22, at '2', expect ';' after value",
+		"var a = { var b = 1; b":    "parse error: line 1, col 23, at end, expect ';' after value",
+		"var a = { var b = b; b };": "resolve error: line 1, col 19, at 'b', can't read local variable in its own initializer",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+/*----------  Helper Methods  ----------*/
+
+func evalSource(t *testing.T, source string) *Interpreter {
+	lox := New()
+	assert.Nil(t, lox.Run(source))
+	return lox
+}
+
+func getGlobal(lox *Interpreter, name string) Val {
+	return lox.globals.Get(NewToken(IDENTIFIER, name, nil, 0, 0))
+}
+
+func TestBreakContinue(t *testing.T) {
+	t.Run("break only exits innermost loop", func(t *testing.T) {
+		lox := evalSource(t, `
+var outer = 0;
+var inner = 0;
+while (outer < 3) {
+  outer = outer + 1;
+  while (true) {
+    inner = inner + 1;
+    break;
+  }
+}
+`)
+		assert.Equal(t, Number(3), getGlobal(lox, "outer"))
+		assert.Equal(t, Number(3), getGlobal(lox, "inner"))
+	})
+
+	t.Run("continue runs increment of for loop", func(t *testing.T) {
+		lox := evalSource(t, `
+var sum = 0;
+var count = 0;
+for (var i = 0; i < 10; i = i + 1) {
+  count = count + 1;
+  if (i / 2 == 2) continue;
+  if (i == 7) break;
+  sum = sum + i;
+}
+`)
+		// 0 + 1 + 2 + 3 + 5 + 6
+		assert.Equal(t, Number(17), getGlobal(lox, "sum"))
+		assert.Equal(t, Number(8), getGlobal(lox, "count"))
+	})
+
+	t.Run("nested loops", func(t *testing.T) {
+		lox := evalSource(t, `
+var pairs = 0;
+for (var i = 0; i < 4; i = i + 1) {
+  for (var j = 0; j < 4; j = j + 1) {
+    if (j == i) break;
+    if (j == 1) continue;
+    pairs = pairs + 1;
+  }
+}
+`)
+		// (1, 0), (2, 0), (3, 0), (3, 2)
+		assert.Equal(t, Number(4), getGlobal(lox, "pairs"))
+	})
+
+	t.Run("labeled loops", func(t *testing.T) {
+		lox := evalSource(t, `
+var found;
+outer: for (var i = 0; i < 5; i++) {
+  for (var j = 0; j < 5; j++) {
+    if (i * j == 6) {
+      found = [i, j];
+      break outer;
+    }
+  }
+}
+
+var skipped = [];
+rows: for (row in [1, 2, 3]) {
+  var col = 0;
+  while (true) {
+    col++;
+    if (row == 2) continue rows;
+    if (col > 1) break;
+  }
+  push(skipped, row);
+}
+
+// the innermost loop is still the default target
+var n = 0;
+a: do {
+  b: while (true) {
+    n++;
+    break;
+  }
+  try {
+    if (n < 3) continue a;
+  } finally {
+    n += 10;
+  }
+} while (n < 3);
+
+var shadowing = 0;
+x: while (shadowing < 2) {
+  shadowing++;
+  var f = func() {
+    x: while (true) break x;
+    return 1;
+  };
+  f();
+}
+`)
+		assert.Equal(t, "[2, 3]", stringify(getGlobal(lox, "found")))
+		assert.Equal(t, "[1, 3]", stringify(getGlobal(lox, "skipped")))
+		assert.Equal(t, Number(11), getGlobal(lox, "n"))
+		assert.Equal(t, Number(2), getGlobal(lox, "shadowing"))
+	})
+}
+
+func TestForLoop(t *testing.T) {
+	t.Run("closure captures loop variable per iteration", func(t *testing.T) {
+		lox := evalSource(t, `
+var f0;
+var f1;
+var f2;
+for (var i = 0; i < 3; i = i + 1) {
+  func f() {
+    return i;
+  }
+  if (i == 0) f0 = f;
+  if (i == 1) f1 = f;
+  if (i == 2) f2 = f;
+}
+var r0 = f0();
+var r1 = f1();
+var r2 = f2();
+`)
+		assert.Equal(t, Number(0), getGlobal(lox, "r0"))
+		assert.Equal(t, Number(1), getGlobal(lox, "r1"))
+		assert.Equal(t, Number(2), getGlobal(lox, "r2"))
+	})
+
+	t.Run("loop variable is scoped to the loop", func(t *testing.T) {
+		lox := evalSource(t, `
+var i = "outer";
+var last;
+for (var i = 0; i < 3; i = i + 1) {
+  last = i;
+}
+`)
+		assert.Equal(t, "outer", getGlobal(lox, "i"))
+		assert.Equal(t, Number(2), getGlobal(lox, "last"))
+	})
+
+	t.Run("all clauses omitted", func(t *testing.T) {
+		lox := evalSource(t, `
+var count = 0;
+for (;;) {
+  count = count + 1;
+  if (count == 5) break;
+}
+`)
+		assert.Equal(t, Number(5), getGlobal(lox, "count"))
+	})
+}
+
+// source should be a whole expression
+func evalExpr(t *testing.T, source string) Val {
+	val, err := New().evalExpression(source)
+	assert.Nil(t, err)
+	return val
+}
+
+func TestInterpret(t *testing.T) {
+	t.Run("runtime error halts execution", func(t *testing.T) {
+		lox := New()
+		err := lox.Run(`
+var a = 1;
+a = -nil;
+a = 2;
+`)
+		assert.EqualError(t, err, "runtime error: line 3, col 5, operand must be a number")
+		assert.Equal(t, exitCodeRuntimeError, lox.exitCode)
+		assert.Equal(t, Number(1), getGlobal(lox, "a"))
+	})
+
+	t.Run("top level return", func(t *testing.T) {
+		lox := New()
+		err := lox.Run("var a = 1; return a; a = 2;")
+		assert.EqualError(t, err, "parse error: line 1, col 12, at 'return', can't return from top-level code")
+		assert.Equal(t, exitCodeStaticError, lox.exitCode)
+		_, ok := lox.GetGlobal("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("return carries its position", func(t *testing.T) {
+		lox := evalSource(t, `
+func f(n) {
+  if (n > 0) return n;
+  return -n;
+}
+var a = f(-2);
+`)
+		assert.Equal(t, Number(2), getGlobal(lox, "a"))
+
+		// only reachable with stmts that bypass the parser
+		token := NewToken(RETURN, "return", nil, 2, 3)
+		err := New().Interpret([]Stmt{NewStmtReturn(token, nil)})
+		assert.EqualError(t, err, "line 2, col 3, can't return from top-level code")
+	})
+
+	t.Run("exit code is reset", func(t *testing.T) {
+		lox := New()
+		assert.NotNil(t, lox.Run("nil();"))
+		assert.Equal(t, exitCodeRuntimeError, lox.ExitCode())
+		assert.Nil(t, lox.Run("var a = 1;"))
+		assert.Equal(t, 0, lox.ExitCode())
+
+		_, err := lox.PrintAst("var = 1;")
+		assert.NotNil(t, err)
+		assert.Equal(t, exitCodeStaticError, lox.ExitCode())
+		_, err = lox.PrintAst("var a = 1;")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, lox.ExitCode())
+	})
+
+	t.Run("static error", func(t *testing.T) {
+		lox := New()
+		assert.NotNil(t, lox.Run("var a = ;"))
+		assert.Equal(t, exitCodeStaticError, lox.exitCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		lox := New()
+		assert.Nil(t, lox.Interpret([]Stmt{NewStmtExpression(NewExprLiteral(nil))}))
+		assert.Equal(t, 0, lox.exitCode)
+	})
+}
+
+func TestAssert(t *testing.T) {
+	lox := evalSource(t, `
+assert true;
+assert 1 + 1 == 2, "math works";
+assert "non-empty";
+var reached = true;
+`)
+	assert.Equal(t, true, getGlobal(lox, "reached"))
+
+	err := New().Run(`
+var a = 1;
+assert a == 2;
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 1, assertion failed")
+
+	err = New().Run(`
+var a = 1;
+assert a > 1, "expect a > 1, got " + "1";
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 1, expect a > 1, got 1")
+
+	err = New().Run("assert nil, 42;")
+	assert.EqualError(t, err, "runtime error: line 1, col 1, 42")
+}
+
+func TestConst(t *testing.T) {
+	lox := evalSource(t, `
+const a = 1;
+var b;
+{
+  const c = a + 1;
+  b = c * 2;
+}
+`)
+	assert.Equal(t, Number(1), getGlobal(lox, "a"))
+	assert.Equal(t, Number(4), getGlobal(lox, "b"))
+
+	err := New().Run(`
+const a = 1;
+a = 2;
+`)
+	assert.EqualError(t, err, "runtime error: line 3, col 1, cannot assign to constant 'a'")
+
+	err = New().Run(`
+func f() {
+  const x = "x";
+  x += "y";
+}
+f();
+`)
+	assert.EqualError(t, err, "runtime error: line 4, col 3, cannot assign to constant 'x'")
+
+	err = New().Run("const a;")
+	assert.EqualError(t, err, "parse error: line 1, col 8, at ';', expect '=' after constant name, constant must be initialized")
+}
+
+func TestLogical(t *testing.T) {
+	cases := map[string]Val{
+		`0 or "x"`:       Number(0),
+		`"" or "x"`:      "",
+		"nil or 5":       Number(5),
+		"false or nil":   nil,
+		"nil or false":   false,
+		`0 and "x"`:      "x",
+		"nil and 5":      nil,
+		"false and 5":    false,
+		"1 and 2 or 3":   Number(2),
+		"nil and 2 or 3": Number(3),
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+
+	lox := evalSource(t, `
+var calls = 0;
+func foo() {
+  calls += 1;
+  return true;
+}
+var a = false and foo();
+var b = true or foo();
+var c = true and foo();
+var d = nil or foo();
+`)
+	assert.Equal(t, false, getGlobal(lox, "a"))
+	assert.Equal(t, true, getGlobal(lox, "b"))
+	assert.Equal(t, Number(2), getGlobal(lox, "calls"))
+}
+
+func TestIncrementDecrement(t *testing.T) {
+	lox := evalSource(t, `
+var a = 1;
+var post = a++;
+var afterPost = a;
+var pre = ++a;
+var afterPre = a;
+var postDec = a--;
+var preDec = --a;
+
+var list = [10, 20];
+var i = 0;
+var elem = list[i++]++;
+
+class Counter {}
+var c = Counter();
+c.n = 5;
+var field = ++c.n;
+
+var m = {"k": 1};
+m["k"]--;
+
+func f() {
+  var local = 0;
+  local++;
+  ++local;
+  return local;
+}
+var local = f();
+`)
+	assert.Equal(t, Number(1), getGlobal(lox, "post"))
+	assert.Equal(t, Number(2), getGlobal(lox, "afterPost"))
+	assert.Equal(t, Number(3), getGlobal(lox, "pre"))
+	assert.Equal(t, Number(3), getGlobal(lox, "afterPre"))
+	assert.Equal(t, Number(3), getGlobal(lox, "postDec"))
+	assert.Equal(t, Number(1), getGlobal(lox, "preDec"))
+	assert.Equal(t, Number(1), getGlobal(lox, "a"))
+	// index expression is evaluated only once
+	assert.Equal(t, Number(10), getGlobal(lox, "elem"))
+	assert.Equal(t, Number(1), getGlobal(lox, "i"))
+	assert.Equal(t, "[11, 20]", stringify(getGlobal(lox, "list")))
+	assert.Equal(t, Number(6), getGlobal(lox, "field"))
+	assert.Equal(t, "{k: 0}", stringify(getGlobal(lox, "m")))
+	assert.Equal(t, Number(2), getGlobal(lox, "local"))
+
+	errors := map[string]string{
+		"1++;":              "parse error: line 1, col 2, at '++', invalid ++ target",
+		"--(a);":            "parse error: line 1, col 1, at '--', invalid -- target",
+		`var s = "a"; s++;`: "runtime error: line 1, col 15, operand must be a number",
+		"const k = 1; k++;": "runtime error: line 1, col 14, cannot assign to constant 'k'",
+		"var n; ++n;":       "runtime error: line 1, col 8, operand must be a number",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestPrintOutput(t *testing.T) {
+	output := &bytes.Buffer{}
+	lox := New()
+	lox.SetOutput(output)
+	assert.Nil(t, lox.Run(`
+print 1 + 1;
+print "a" + "b";
+print nil;
+for (var i = 0; i < 2; i++) print [i];
+`))
+	assert.Equal(t, "2\nab\nnil\n[0]\n[1]\n", output.String())
+}
+
+func TestComma(t *testing.T) {
+	lox := evalSource(t, `
+var a = 0;
+var b = 0;
+a = 1, b = a + 1, a += b;
+
+var j = 10;
+for (var i = 0; i < 3; i++, j--) {}
+
+func add(x, y) { return x + y; }
+var sum = add(1, 2);
+var list = [1, 2];
+`)
+	assert.Equal(t, Number(3), getGlobal(lox, "a"))
+	assert.Equal(t, Number(2), getGlobal(lox, "b"))
+	assert.Equal(t, Number(7), getGlobal(lox, "j"))
+	// call arguments and list elements are not comma expressions
+	assert.Equal(t, Number(3), getGlobal(lox, "sum"))
+	assert.Equal(t, "[1, 2]", stringify(getGlobal(lox, "list")))
+
+	assert.Equal(t, Number(3), evalExpr(t, "1, 2, 3;"))
+}
+
+func TestStructuralEquality(t *testing.T) {
+	cases := map[string]bool{
+		"[1, 2] == [1, 2]":                                 true,
+		"[1, 2] == [2, 1]":                                 false,
+		"[1, 2] == [1, 2, 3]":                              false,
+		"[] == []":                                         true,
+		"[[1], [2]] == [[1], [2]]":                         true,
+		`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`:         true,
+		`{"a": 1} == {"a": 2}`:                             false,
+		`{"a": 1} == {"b": 1}`:                             false,
+		`{"a": {"b": {"c": 1}}} == {"a": {"b": {"c": 1}}}`: true,
+		"[1] != [1]":                                       false,
+		"[1] == {}":                                        false,
+		"[nil] == [nil]":                                   true,
+		"identical([1], [1])":                              false,
+		"1 == 1":                                           true,
+		`"a" == "a"`:                                       true,
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+
+	lox := evalSource(t, `
+var a = [1];
+var same = identical(a, a);
+
+var x = [1];
+push(x, x);
+var y = [1];
+push(y, y);
+var cyclic = x == y;
+
+var m = {};
+m["self"] = m;
+var n = {};
+n["self"] = n;
+var cyclicMap = m == n;
+var differentCycle = [2, x] == [2, y];
+`)
+	assert.Equal(t, true, getGlobal(lox, "same"))
+	assert.Equal(t, true, getGlobal(lox, "cyclic"))
+	assert.Equal(t, true, getGlobal(lox, "cyclicMap"))
+	assert.Equal(t, true, getGlobal(lox, "differentCycle"))
+}
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	lox := New(Trace(true))
+	lox.SetOutput(&out)
+	assert.Nil(t, lox.Run(`
+func double(n) { return n * 2; }
+{
+  print double(1 + 2);
+}
+`))
+	expected := []string{
+		"(func double (n) (return (* n 2)))",
+		"(block (print (double (+ 1 2))))",
+		"  (print (double (+ 1 2)))",
+		"    double => <fn double>",
+		"    (+ 1 2) => 3",
+		"    (return (* n 2))",
+		"      (* n 2) => 6",
+		"    (double (+ 1 2)) => 6",
+		"6",
+	}
+	lines := strings.Split(out.String(), "\n")
+	i := 0
+	for _, line := range lines {
+		if i < len(expected) && line == expected[i] {
+			i++
+		}
+	}
+	assert.Equal(t, len(expected), i, out.String())
+
+	// level is restored when a statement panics
+	out.Reset()
+	assert.NotNil(t, lox.Run(`{ nil(); }`))
+	assert.Nil(t, lox.Run(`print 1;`))
+	assert.Contains(t, out.String(), "\n(print 1)\n  1 => 1\n1\n")
+}
+
+func TestLocalSlots(t *testing.T) {
+	lox := evalSource(t, `
+var results = [];
+{
+  var a = 1;
+  func get() { return a; }
+  a = 2;
+  push(results, get());
+  push(results, a);
+  {
+    var a = 4;
+    push(results, a);
+  }
+  push(results, a);
+}
+
+var closures = [];
+for (var i = 0; i < 3; i++) {
+  push(closures, func() { return i; });
+}
+for (var i = 0; i < 3; i++) {
+  push(results, closures[i]());
+}
+
+func counter() {
+  var n = 0;
+  return func() { n = n + 1; return n; };
+}
+var next = counter();
+next();
+push(results, next());
+`)
+	assert.Equal(t, "[2, 2, 4, 2, 0, 1, 2, 2]", stringify(getGlobal(lox, "results")))
+
+	err := New().Run(`
+{
+  const a = 1;
+  a = 2;
+}
+`)
+	assert.EqualError(t, err, "runtime error: line 4, col 3, cannot assign to constant 'a'")
+
+	err = New().Run(`
+{
+  var a = 1;
+  delete("a");
+  print a;
+}
+`)
+	assert.EqualError(t, err, "runtime error: line 5, col 9, undefined variable 'a'")
+}
+
+func TestDoWhile(t *testing.T) {
+	lox := evalSource(t, `
+var once = 0;
+do {
+  once++;
+} while (false);
+
+var i = 0;
+var evens = [];
+do {
+  i++;
+  if (i % 2 == 1) continue;
+  if (i > 6) break;
+  push(evens, i);
+} while (i < 10);
+
+var single = 0;
+do single++; while (single < 3);
+`)
+	assert.Equal(t, Number(1), getGlobal(lox, "once"))
+	assert.Equal(t, "[2, 4, 6]", stringify(getGlobal(lox, "evens")))
+	assert.Equal(t, Number(8), getGlobal(lox, "i"))
+	assert.Equal(t, Number(3), getGlobal(lox, "single"))
+
+	ast, err := New().PrintAst("do { a; } while (b);")
+	assert.Nil(t, err)
+	assert.Equal(t, "(do (block (; a)) b)", ast)
+
+	err = New().Run("do {} while (true)")
+	assert.EqualError(t, err, "parse error: line 1, col 19, at end, expect ';' after do while statement")
+	err = New().Run("do {} (true);")
+	assert.EqualError(t, err, "parse error: line 1, col 7, at '(', expect 'while' after do body")
+}
+
+func TestForEach(t *testing.T) {
+	lox := evalSource(t, `
+var seen = [];
+for (x in [3, 1, 2]) push(seen, x);
+
+var sum = 0;
+for (x in [1, 2, 3, 4, 5, 6]) {
+  if (x % 2 == 0) continue;
+  if (x > 4) break;
+  sum += x;
+}
+
+// keys are visited in insertion order
+var keys = [];
+var values = [];
+var m = {"b": 1, "a": 2, 3: 3};
+m["b"] = 10;
+for (k in m) {
+  push(keys, k);
+  push(values, m[k]);
+}
+
+// appended elements are visited, keys added to a map are not
+var grown = [1];
+for (x in grown) if (x < 3) push(grown, x + 1);
+var counted = 0;
+for (k in m) {
+  counted++;
+  m[string(k) + "!"] = true;
+}
+
+// each iteration has its own loop variable
+var closures = [];
+for (x in ["a", "b"]) push(closures, func() { return x; });
+var captured = closures[0]() + closures[1]();
+`)
+	assert.Equal(t, "[3, 1, 2]", stringify(getGlobal(lox, "seen")))
+	assert.Equal(t, Number(4), getGlobal(lox, "sum"))
+	assert.Equal(t, "[b, a, 3]", stringify(getGlobal(lox, "keys")))
+	assert.Equal(t, "[10, 2, 3]", stringify(getGlobal(lox, "values")))
+	assert.Equal(t, "[1, 2, 3]", stringify(getGlobal(lox, "grown")))
+	assert.Equal(t, Number(3), getGlobal(lox, "counted"))
+	assert.Equal(t, "ab", getGlobal(lox, "captured"))
+
+	ast, err := New().PrintAst("for (x in l) print x;")
+	assert.Nil(t, err)
+	assert.Equal(t, "(for-in x l (print x))", ast)
+
+	err = New().Run("for (x in 1) print x;")
+	assert.EqualError(t, err, "runtime error: line 1, col 8, can only iterate over lists, maps and instances with 'iter' method")
+	err = New().Run("for (x in [1] print x;")
+	assert.EqualError(t, err, "parse error: line 1, col 15, at 'print', expect ')' after iterated value")
+	err = New().Run("print x;\nfor (x in [1]) {}")
+	assert.EqualError(t, err, "runtime error: line 1, col 7, undefined variable 'x'")
+}
+
+func TestForEachIterator(t *testing.T) {
+	lox := evalSource(t, `
+class RangeIterator {
+  init(from, to) {
+    this.current = from;
+    this.to = to;
+  }
+  hasNext() { return this.current < this.to; }
+  next() {
+    var value = this.current;
+    this.current++;
+    return value;
+  }
+}
+
+class Range {
+  init(from, to) {
+    this.from = from;
+    this.to = to;
+  }
+  iter() { return RangeIterator(this.from, this.to); }
+}
+
+var seen = [];
+var r = Range(1, 5);
+for (i in r) push(seen, i);
+// every loop gets a fresh iterator
+for (i in r) {
+  if (i == 3) break;
+  push(seen, i * 10);
+}
+
+// values are produced lazily, next isn't called past break
+class Naturals {
+  init() { this.produced = 0; }
+  iter() { return this; }
+  hasNext() { return true; }
+  next() { this.produced++; return this.produced; }
+}
+var naturals = Naturals();
+var sum = 0;
+for (n in naturals) {
+  if (n > 4) break;
+  sum += n;
+}
+var produced = naturals.produced;
+`)
+	assert.Equal(t, "[1, 2, 3, 4, 10, 20]", stringify(getGlobal(lox, "seen")))
+	assert.Equal(t, Number(10), getGlobal(lox, "sum"))
+	assert.Equal(t, Number(5), getGlobal(lox, "produced"))
+
+	errors := map[string]string{
+		"class A {}\nfor (x in A()) {}":                                                                "runtime error: line 2, col 8, can only iterate over lists, maps and instances with 'iter' method",
+		"class A { iter() { return 1; } }\nfor (x in A()) {}":                                          "runtime error: line 2, col 8, 'iter' must return an instance",
+		"class A { iter() { return this; } }\nfor (x in A()) {}":                                       "runtime error: line 2, col 8, iterator must have method 'hasNext'",
+		"class A { iter() { return this; } hasNext() { return true; } next(n) {} }\nfor (x in A()) {}": "runtime error: line 2, col 8, method 'next' of iterator must take no arguments",
+		"class A { iter() { return this; } hasNext() { return 1 / nil; } }\nfor (x in A()) {}":         "runtime error: line 1, col 56, operands must be numbers",
+	}
+	for source, expected := range errors {
+		assert.EqualError(t, New().Run(source), expected, source)
+	}
+}
+
+func TestNilCoalescing(t *testing.T) {
+	lox := evalSource(t, `
+var a = false ?? 1;
+var b = nil ?? 1;
+var c = 0 ?? 1;
+var d = nil ?? nil ?? "last";
+var e = nil or false ?? "default";
+
+// right operand is evaluated only if left one is nil
+var calls = 0;
+func effect() { calls++; return "effect"; }
+var f = "set" ?? effect();
+var g = nil ?? effect();
+var h = false ?? true ? "then" : "else";
+`)
+	assert.Equal(t, false, getGlobal(lox, "a"))
+	assert.Equal(t, Number(1), getGlobal(lox, "b"))
+	assert.Equal(t, Number(0), getGlobal(lox, "c"))
+	assert.Equal(t, "last", getGlobal(lox, "d"))
+	assert.Equal(t, false, getGlobal(lox, "e"))
+	assert.Equal(t, "set", getGlobal(lox, "f"))
+	assert.Equal(t, "effect", getGlobal(lox, "g"))
+	assert.Equal(t, Number(1), getGlobal(lox, "calls"))
+	assert.Equal(t, "else", getGlobal(lox, "h"))
+
+	ast, err := New().PrintAst("a ?? b or c ? d : e;")
+	assert.Nil(t, err)
+	assert.Equal(t, "(; (?: (?? a (or b c)) d e))", ast)
+}
+
+func TestOptionalChaining(t *testing.T) {
+	lox := evalSource(t, `
+class Node {
+  init(value, next) {
+    this.value = value;
+    this.next = next;
+  }
+  describe() { return "node " + string(this.value); }
+}
+
+var empty = nil;
+var list = Node(1, Node(2, nil));
+
+var a = empty?.value;
+var b = list?.value;
+var c = list?.next?.value;
+var d = list.next?.next?.value;
+
+// a nil base skips the rest of the chain, including calls and indexing
+var calls = 0;
+func effect() { calls++; return 0; }
+var e = empty?.next.value;
+var f = empty?.describe();
+var g = empty?.items[effect()];
+var h = list?.describe();
+var i = list.next.next?.describe(effect());
+
+// a skipped tail call returns nil
+func describe(node) { return node?.describe(); }
+var j = describe(nil);
+`)
+	assert.Nil(t, getGlobal(lox, "a"))
+	assert.Equal(t, Number(1), getGlobal(lox, "b"))
+	assert.Equal(t, Number(2), getGlobal(lox, "c"))
+	assert.Nil(t, getGlobal(lox, "d"))
+	assert.Nil(t, getGlobal(lox, "e"))
+	assert.Nil(t, getGlobal(lox, "f"))
+	assert.Nil(t, getGlobal(lox, "g"))
+	assert.Equal(t, "node 1", getGlobal(lox, "h"))
+	assert.Nil(t, getGlobal(lox, "i"))
+	assert.Nil(t, getGlobal(lox, "j"))
+	assert.Equal(t, Number(0), getGlobal(lox, "calls"))
+
+	ast, err := New().PrintAst("a?.b.c;")
+	assert.Nil(t, err)
+	assert.Equal(t, "(; (get c (get? b a)))", ast)
+
+	errors := map[string]string{
+		// only nil is skipped, a grouping ends the chain
+		"var a = 1;\na?.b;":   "runtime error: line 2, col 4, only instances and classes have properties",
+		"var a;\n(a?.b).c;":   "runtime error: line 2, col 8, only instances and classes have properties",
+		"class A {}\nA()?.b;": "runtime error: line 2, col 6, undefined property 'b'",
+		"var a;\na?.b = 1;":   "parse error: line 2, col 6, at '=', invalid assignment target",
+		"var a;\na?.b++;":     "parse error: line 2, col 5, at '++', invalid ++ target",
+		"var a;\na?.;":        "parse error: line 2, col 4, at ';', expect property name after '?.'",
+	}
+	for source, expected := range errors {
+		assert.EqualError(t, New().Run(source), expected, source)
+	}
+}
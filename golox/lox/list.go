@@ -0,0 +1,58 @@
+package lox
+
+import "strings"
+
+type LoxList struct {
+	elements []Val
+	frozen   bool // set by `freeze`, elements can't change any more
+}
+
+func NewLoxList(elements []Val) *LoxList {
+	return &LoxList{elements, false}
+}
+
+func (l *LoxList) String() string {
+	return l.format(map[Val]bool{})
+}
+
+// a list already being printed is part of a cycle and shown as `[...]`
+func (l *LoxList) format(visiting map[Val]bool) string {
+	if visiting[l] {
+		return "[...]"
+	}
+	visiting[l] = true
+	defer delete(visiting, l)
+
+	strs := make([]string, 0, len(l.elements))
+	for _, element := range l.elements {
+		strs = append(strs, stringifyVisiting(element, visiting))
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// token is used for error reporting
+func (l *LoxList) Get(token *Token, index Val) Val {
+	return l.elements[l.checkIndex(token, index)]
+}
+
+func (l *LoxList) Set(token *Token, index Val, val Val) {
+	l.checkMutable(token)
+	l.elements[l.checkIndex(token, index)] = val
+}
+
+func (l *LoxList) checkMutable(token *Token) {
+	if l.frozen {
+		panic(NewRuntimeError(token, "cannot modify frozen collection"))
+	}
+}
+
+func (l *LoxList) checkIndex(token *Token, index Val) int {
+	if !isInteger(index) {
+		panic(NewRuntimeError(token, "list index must be an integer"))
+	}
+	i := int(toNumber(index))
+	if i < 0 || i >= len(l.elements) {
+		panic(NewRuntimeError(token, sprintf("list index %d out of range", i)))
+	}
+	return i
+}
@@ -0,0 +1,62 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListLiteral(t *testing.T) {
+	lox := evalSource(t, `
+var empty = [];
+var list = [1, "two", [3, [4]]];
+var first = list[0];
+var four = list[2][1][0];
+`)
+	assert.Equal(t, []Val{}, getGlobal(lox, "empty").(*LoxList).elements)
+	assert.Equal(t, Number(1), getGlobal(lox, "first"))
+	assert.Equal(t, Number(4), getGlobal(lox, "four"))
+	assert.Equal(t, "[1, two, [3, [4]]]", getGlobal(lox, "list").(*LoxList).String())
+}
+
+func TestListIndexSet(t *testing.T) {
+	lox := evalSource(t, `
+var list = [1, 2, [3]];
+list[0] = "one";
+var result = list[1] = list[1] * 10;
+list[2][0] = list;
+var self = list[2][0][0];
+`)
+	list := getGlobal(lox, "list").(*LoxList)
+	assert.Equal(t, "one", list.elements[0])
+	assert.Equal(t, Number(20), list.elements[1])
+	assert.Equal(t, Number(20), getGlobal(lox, "result"))
+	assert.Equal(t, "one", getGlobal(lox, "self"))
+}
+
+func TestListIndexError(t *testing.T) {
+	errors := map[string]string{
+		"[1, 2][2];":           "runtime error: line 1, col 9, list index 2 out of range",
+		"[1, 2][-1] = 0;":      "runtime error: line 1, col 10, list index -1 out of range",
+		`[1, 2]["0"];`:         "runtime error: line 1, col 11, list index must be an integer",
+		"[1, 2][0.5];":         "runtime error: line 1, col 11, list index must be an integer",
+		`"abc"[0];`:            "runtime error: line 1, col 8, can only index lists and maps",
+		"var a = 1; a[0] = 1;": "runtime error: line 1, col 15, can only index lists and maps",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg)
+	}
+}
+
+func TestListCyclicString(t *testing.T) {
+	lox := evalSource(t, `
+var l = [1];
+push(l, l);
+
+var shared = [2];
+var twice = [shared, shared];
+`)
+	assert.Equal(t, "[1, [...]]", getGlobal(lox, "l").(*LoxList).String())
+	// only a list containing itself is a cycle
+	assert.Equal(t, "[[2], [2]]", getGlobal(lox, "twice").(*LoxList).String())
+}
@@ -0,0 +1,430 @@
+// Package lox implements a tree-walk interpreter for the Lox language,
+// it can be embedded into Go programs as a scripting layer:
+//
+//	in := lox.New()
+//	if err := in.Run(`var answer = 6 * 7;`); err != nil {
+//		// handle error
+//	}
+//	answer, _ := in.GetGlobal("answer")
+package lox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*----------  Public API  ----------*/
+
+// Option configures an interpreter created by New
+type Option func(in *Interpreter)
+
+// AllowEnv controls whether the `env` builtin can read OS environment
+// variables, enabled by default
+func AllowEnv(allow bool) Option {
+	return func(in *Interpreter) {
+		in.allowEnv = allow
+	}
+}
+
+// AllowFileIO controls whether the `readFile` and `writeFile` builtins can
+// access the file system, enabled by default
+func AllowFileIO(allow bool) Option {
+	return func(in *Interpreter) {
+		in.allowFileIO = allow
+	}
+}
+
+// ImportDir sets the directory relative imports of the source passed to Run
+// are resolved against, defaults to the working directory
+func ImportDir(dir string) Option {
+	return func(in *Interpreter) {
+		in.dir = dir
+	}
+}
+
+// Script tells that source passed to Run is read from path, relative
+// imports are resolved against its directory and importing it again is a
+// cycle
+func Script(path string) Option {
+	return func(in *Interpreter) {
+		in.dir = filepath.Dir(path)
+		if abs, err := filepath.Abs(path); err == nil {
+			in.importing[abs] = true
+		}
+	}
+}
+
+// WarnUnused makes the resolver report local variables that are never
+// read, see Warnings
+func WarnUnused(warn bool) Option {
+	return func(in *Interpreter) {
+		in.resolver.warnUnused = warn
+	}
+}
+
+// WarnDiscarded makes the resolver report expression statements which
+// compute a value without any effect, e.g. `a + b;`, see Warnings
+func WarnDiscarded(warn bool) Option {
+	return func(in *Interpreter) {
+		in.resolver.warnDiscarded = warn
+	}
+}
+
+// MaxCallDepth sets how deep calls can nest before a "stack overflow"
+// runtime error is raised, defaults to 1000
+func MaxCallDepth(depth int) Option {
+	return func(in *Interpreter) {
+		in.maxDepth = depth
+	}
+}
+
+// Scan tokenizes source without parsing it, the last token is always EOF,
+// the error is an `*Error` of scan phase
+func Scan(source string) ([]*Token, error) {
+	tokens, err := NewScanner().Scan(source)
+	if err != nil {
+		return nil, newError(scanPhase, err)
+	}
+	return tokens, nil
+}
+
+// Trace makes the interpreter write every statement before running it and
+// every expression with its value to the output, see SetOutput
+func Trace(trace bool) Option {
+	return func(in *Interpreter) {
+		in.trace = trace
+	}
+}
+
+// UseVM makes Run compile source to bytecode and run it on a stack-based VM
+// instead of walking the AST, only part of the language is supported, the
+// rest is reported as an error of compile phase
+func UseVM(use bool) Option {
+	return func(in *Interpreter) {
+		in.useVM = use
+	}
+}
+
+// Optimize folds constant subexpressions of a program, e.g. `2 + 3 * 4`,
+// before it runs, an operation that would raise an error is left to raise
+// it at runtime
+func Optimize(optimize bool) Option {
+	return func(in *Interpreter) {
+		in.optimize = optimize
+	}
+}
+
+// ReportErrors makes REPL report errors through sink instead of writing
+// them to its output as text
+func ReportErrors(sink ErrorSink) Option {
+	return func(in *Interpreter) {
+		in.errorSink = sink
+	}
+}
+
+// Seed seeds the source of `random` and `randomInt`, so a program gets the
+// same numbers every run, defaults to the current time
+func Seed(seed int64) Option {
+	return func(in *Interpreter) {
+		in.random.Seed(seed)
+	}
+}
+
+// New returns an interpreter with builtins defined, globals persist
+// across calls to Run
+func New(options ...Option) *Interpreter {
+	globals := NewEnv(nil)
+	in := &Interpreter{
+		scanner:  NewScanner(),
+		parser:   NewParser(),
+		resolver: NewResolver(),
+		globals:  globals,
+		env:      globals,
+		allowEnv: true,
+
+		allowFileIO: true,
+
+		dir:       ".",
+		importing: map[string]bool{},
+
+		stdout: os.Stdout,
+		stdin:  bufio.NewReader(os.Stdin),
+
+		maxDepth: defaultMaxDepth,
+
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, option := range options {
+		option(in)
+	}
+	defineGlobals(in)
+	return in
+}
+
+// Run scans, parses, resolves and executes source, the returned error is
+// always an `*Error`, panics never escape
+func (in *Interpreter) Run(source string) (err error) {
+	defer in.recoverInternal(&err)
+	in.exitCode = 0
+	in.exited = false
+
+	program, err := in.parse(source)
+	if err != nil {
+		in.exitCode = exitCodeStaticError
+		return err
+	}
+
+	program, err = in.resolve(program)
+	if err != nil {
+		in.exitCode = exitCodeStaticError
+		return newError(resolvePhase, err)
+	}
+
+	if in.useVM {
+		return in.runVM(program)
+	}
+
+	if err := in.Interpret(program); err != nil {
+		return newError(runtimePhase, err)
+	}
+
+	return nil
+}
+
+// GetGlobal returns value of the global variable, ok is false if it is
+// not defined
+func (in *Interpreter) GetGlobal(name string) (val Val, ok bool) {
+	val, ok = in.globals.m[name]
+	return
+}
+
+// SetOutput sets where `print` and `println` write to, defaults to
+// os.Stdout
+func (in *Interpreter) SetOutput(w io.Writer) {
+	in.stdout = w
+}
+
+// SetInput sets where `readLine` and `readAll` read from, defaults to
+// os.Stdin
+func (in *Interpreter) SetInput(r io.Reader) {
+	in.stdin = bufio.NewReader(r)
+}
+
+// Warnings returns static warnings collected so far
+func (in *Interpreter) Warnings() []string {
+	return in.warnings
+}
+
+// ExitCode is the suggested process exit code after the last Run or
+// PrintAst, 0 if no error occurred, the code passed to `exit` if the
+// program called it
+func (in *Interpreter) ExitCode() int {
+	return in.exitCode
+}
+
+// scan and parse source, return the printed AST without executing
+func (in *Interpreter) PrintAst(source string) (string, error) {
+	in.exitCode = 0
+	program, err := in.parse(source)
+	if err != nil {
+		in.exitCode = exitCodeStaticError
+		return "", err
+	}
+	return NewAstPrinter().PrintStmts(program), nil
+}
+
+// Check scans, parses and resolves source without executing it, the
+// returned error is an `*Error` holding every static error found, nil if
+// there is none
+func (in *Interpreter) Check(source string) error {
+	in.exitCode = 0
+	program, err := in.parse(source)
+	if err != nil {
+		in.exitCode = exitCodeStaticError
+		return err
+	}
+	if _, err := in.resolve(program); err != nil {
+		in.exitCode = exitCodeStaticError
+		return newError(resolvePhase, err)
+	}
+	return nil
+}
+
+// REPL reads source from r line by line, values of expression statements
+// and errors are written to w, errors go to ReportErrors sink if one is set.
+// `;` can be omitted after an expression.
+// It returns at end of input or when `exit` is called, see ExitCode.
+func (in *Interpreter) REPL(r io.Reader, w io.Writer) {
+	sink := in.errorSink
+	if sink == nil {
+		sink = NewTextSink(w)
+	}
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		val, isExpr, err := in.evalLine(scanner.Text())
+		if in.exited {
+			return
+		}
+		if err != nil {
+			sink.Report(err)
+		} else if isExpr {
+			fmt.Fprintln(w, stringify(val))
+		}
+		fmt.Fprint(w, "> ")
+	}
+}
+
+/*----------  Private Methods  ----------*/
+
+// convert unexpected panic to an internal error, must be deferred
+func (in *Interpreter) recoverInternal(err *error) {
+	if e := recover(); e != nil {
+		in.exitCode = exitCodeRuntimeError
+		*err = newError(internalPhase, fmt.Errorf("%v", e))
+	}
+}
+
+func (in *Interpreter) parse(source string) ([]Stmt, error) {
+	tokens, err := in.scanner.Scan(source)
+	if err != nil {
+		return nil, newError(scanPhase, err)
+	}
+
+	program, err := in.parser.Parse(tokens)
+	if err != nil {
+		return nil, newError(parsePhase, err)
+	}
+
+	return program, nil
+}
+
+func (in *Interpreter) runVM(program []Stmt) error {
+	chunk, err := NewCompiler().Compile(program)
+	if err != nil {
+		in.exitCode = exitCodeStaticError
+		return newError(compilePhase, err)
+	}
+	if err := NewVM(in).Run(chunk); err != nil {
+		return newError(runtimePhase, err)
+	}
+	return nil
+}
+
+// warnings are collected even if resolving fails, a resolved program is
+// optimized if enabled
+func (in *Interpreter) resolve(program []Stmt) ([]Stmt, error) {
+	err := in.resolver.Resolve(program)
+	in.warnings = append(in.warnings, in.resolver.warnings...)
+	if err != nil {
+		return nil, err
+	}
+	if in.optimize {
+		program = NewOptimizer().Optimize(program)
+	}
+	return program, nil
+}
+
+// scan, parse and resolve the file at path, then run it in global env,
+// static errors are returned, runtime errors are propagated as panic
+func (in *Interpreter) importFile(path string) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	program, err := in.parse(string(buf))
+	if err != nil {
+		return err
+	}
+	program, err = in.resolve(program)
+	if err != nil {
+		return newError(resolvePhase, err)
+	}
+
+	dir := in.dir
+	in.dir = filepath.Dir(path)
+	in.importing[path] = true
+	defer func() {
+		in.dir = dir
+		delete(in.importing, path)
+	}()
+
+	in.executeBlock(program, in.globals)
+	return nil
+}
+
+// used by REPL, a line consisting of a single expression statement is
+// evaluated as expression, isExpr reports whether val should be printed
+func (in *Interpreter) evalLine(line string) (val Val, isExpr bool, err error) {
+	defer in.recoverInternal(&err)
+	in.exited = false
+
+	tokens, err := in.scanner.Scan(line)
+	if err != nil {
+		return nil, false, newError(scanPhase, err)
+	}
+
+	program, err := in.parser.Parse(tokens)
+	if err != nil {
+		// maybe a bare expression without `;`
+		expr, e := in.parser.ParseExpression(tokens)
+		if e != nil {
+			return nil, false, newError(parsePhase, err)
+		}
+		program = []Stmt{NewStmtExpression(expr)}
+	}
+
+	program, err = in.resolve(program)
+	if err != nil {
+		return nil, false, newError(resolvePhase, err)
+	}
+
+	if len(program) == 1 {
+		if stmt, ok := program[0].(*StmtExpression); ok {
+			val, err := in.evaluate(stmt.expr)
+			if err != nil {
+				return nil, false, newError(runtimePhase, err)
+			}
+			return val, true, nil
+		}
+	}
+
+	if err := in.Interpret(program); err != nil {
+		return nil, false, newError(runtimePhase, err)
+	}
+	return nil, false, nil
+}
+
+// source should be a whole expression
+func (in *Interpreter) evalExpression(source string) (Val, error) {
+	val, isExpr, err := in.evalLine(source)
+	if err == nil && !isExpr {
+		err = fmt.Errorf("not an expression")
+	}
+	return val, err
+}
+
+func (in *Interpreter) evaluate(expr Expr) (val Val, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			switch e := e.(type) {
+			case *ProgramExit:
+				in.exitCode = e.code
+				in.exited = true
+			case *RuntimeError:
+				err = e
+			case *Exception:
+				err = e.uncaught()
+			default:
+				panic(e)
+			}
+		}
+	}()
+	return in.eval(expr), nil
+}
@@ -0,0 +1,233 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREPL(t *testing.T) {
+	input := strings.Join([]string{
+		"var a = 1;",
+		"a + 1",
+		"a;",
+		"a = a * 10;",
+		"if (a > 1) a = 0;",
+		"-nil;",
+		"a",
+		"var;",
+		`"unterminated`,
+		"func add(x, y) { return x + y; }",
+		"add(a, 2)",
+	}, "\n")
+	output := &bytes.Buffer{}
+	New().REPL(strings.NewReader(input), output)
+
+	assert.Equal(t, strings.Join([]string{
+		"> > 2",
+		"> 1",
+		"> 10",
+		"> > runtime error: line 1, col 1, operand must be a number",
+		"> 0",
+		"> parse error: line 1, col 4, at ';', expect variable name",
+		"> scan error: line 1, col 1, unterminated string",
+		"> > 2",
+		"> ",
+	}, "\n"), output.String())
+}
+
+func TestRunErrors(t *testing.T) {
+	// runtime error
+	err := New().Run("var a = 1;\nvar b = a / 0;")
+	assert.EqualError(t, err, "runtime error: line 2, col 11, divide by zero")
+	assert.Equal(t, runtimePhase, err.(*Error).Phase)
+	var re *RuntimeError
+	if assert.True(t, errors.As(err, &re)) {
+		line, column := re.Position()
+		assert.Equal(t, []int{2, 11}, []int{line, column})
+	}
+
+	// static errors
+	var se *ScanError
+	err = New().Run("var a = @;")
+	assert.Equal(t, scanPhase, err.(*Error).Phase)
+	if assert.True(t, errors.As(err, &se)) {
+		line, column := se.Position()
+		assert.Equal(t, []int{1, 9}, []int{line, column})
+	}
+
+	var pe *ParseError
+	err = New().Run("var a = ;")
+	assert.Equal(t, parsePhase, err.(*Error).Phase)
+	assert.True(t, errors.As(err, &pe))
+
+	err = New().Run("{ var a = a; }")
+	assert.Equal(t, resolvePhase, err.(*Error).Phase)
+	assert.True(t, errors.As(err, &pe))
+
+	// unexpected panic in a native doesn't escape
+	in := New()
+	in.RegisterNative("boom", 0, func(args []Val) Val {
+		panic("boom")
+	})
+	assert.NotPanics(t, func() {
+		err = in.Run("boom();")
+	})
+	assert.EqualError(t, err, "internal error: boom")
+	assert.Equal(t, exitCodeRuntimeError, in.ExitCode())
+}
+
+func TestErrorSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+	sink.Report(New().Run("var a = 1;\nprint a < \"b\";"))
+	var object map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &object))
+	assert.Equal(t, map[string]interface{}{
+		"line":    float64(2),
+		"col":     float64(9),
+		"type":    "runtime",
+		"message": "operands must be two numbers or two strings",
+	}, object)
+
+	// each parse error is an object on its own line
+	buf.Reset()
+	sink.Report(New().Run("var a = ;\nprint a\nbreak;"))
+	assert.Equal(t, `{"line":1,"col":9,"type":"parse","message":"expect expression"}
+{"line":3,"col":1,"type":"parse","message":"expect ';' after value"}
+`, buf.String())
+
+	buf.Reset()
+	sink.Report(newError(internalPhase, errors.New("boom")))
+	assert.Equal(t, `{"type":"internal","message":"boom"}`+"\n", buf.String())
+
+	// text is the format of Error
+	buf.Reset()
+	NewTextSink(&buf).Report(New().Run("var a = ;"))
+	assert.Equal(t, "parse error: line 1, col 9, at ';', expect expression\n", buf.String())
+
+	// REPL reports through the sink
+	var output, errs bytes.Buffer
+	New(ReportErrors(NewJSONSink(&errs))).REPL(strings.NewReader("-nil;\n1"), &output)
+	assert.Equal(t, "> > 1\n> ", output.String())
+	assert.Equal(t, `{"line":1,"col":1,"type":"runtime","message":"operand must be a number"}`+"\n", errs.String())
+}
+
+func TestExit(t *testing.T) {
+	var buf bytes.Buffer
+	lox := New()
+	lox.SetOutput(&buf)
+	err := lox.Run(`
+print "before";
+func stop() {
+  try {
+    exit(3);
+  } catch (e) {
+    print "not caught";
+  } finally {
+    print "finally";
+  }
+}
+stop();
+print "after";
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, lox.ExitCode())
+	assert.Equal(t, "before\nfinally\n", buf.String())
+
+	// the next run starts over
+	assert.Nil(t, lox.Run("var resumed = true;"))
+	assert.Equal(t, 0, lox.ExitCode())
+	assert.Equal(t, true, getGlobal(lox, "resumed"))
+
+	lox = New(UseVM(true))
+	assert.Nil(t, lox.Run("exit(0);\nvar a = 1;"))
+	assert.Equal(t, 0, lox.ExitCode())
+	_, ok := lox.GetGlobal("a")
+	assert.False(t, ok)
+
+	lox = New()
+	err = lox.Run("exit(1.5);")
+	assert.EqualError(t, err, "runtime error: line 1, col 9, exit code must be an integer")
+	assert.Equal(t, exitCodeRuntimeError, lox.ExitCode())
+	err = lox.Run(`exit("1");`)
+	assert.EqualError(t, err, "runtime error: line 1, col 9, exit code must be an integer")
+
+	// REPL stops reading
+	output := &bytes.Buffer{}
+	lox = New()
+	lox.SetOutput(output)
+	lox.REPL(strings.NewReader("print 1;\nexit(2)\nprint 3;"), output)
+	assert.Equal(t, "> 1\n> ", output.String())
+	assert.Equal(t, 2, lox.ExitCode())
+}
+
+func TestPanic(t *testing.T) {
+	var buf bytes.Buffer
+	lox := New()
+	lox.SetOutput(&buf)
+	err := lox.Run(`
+try {
+  try {
+    panic("broken " + string(1));
+  } catch (e) {
+    print "not caught";
+  } finally {
+    print "finally";
+  }
+} catch (e) {
+  print "not caught either";
+}
+print "after";
+`)
+	assert.EqualError(t, err, "runtime error: line 4, col 32, panic: broken 1")
+	assert.Equal(t, exitCodeRuntimeError, lox.ExitCode())
+	assert.Equal(t, "finally\n", buf.String())
+
+	err = New().Run("func f() { panic([1, nil]); }\nf();")
+	assert.EqualError(t, err, "runtime error: line 1, col 26, panic: [1, nil]")
+}
+
+func TestCheck(t *testing.T) {
+	var buf bytes.Buffer
+	lox := New(WarnUnused(true))
+	lox.SetOutput(&buf)
+	err := lox.Check(`
+print "not run";
+var undefined = missing;
+func f(a) { var b = a; }
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, lox.ExitCode())
+	// nothing is executed
+	assert.Empty(t, buf.String())
+	_, ok := lox.GetGlobal("undefined")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"line 4, col 17, local variable 'b' is never used"}, lox.Warnings())
+
+	// every static error is reported
+	err = New().Check("var a = ;\nprint a\nbreak;")
+	assert.EqualError(t, err, `parse error: line 1, col 9, at ';', expect expression
+parse error: line 3, col 1, at 'break', expect ';' after value`)
+	assert.Equal(t, parsePhase, err.(*Error).Phase)
+
+	lox = New()
+	err = lox.Check(`
+{ var a = a; }
+func f() { return this; }
+print "fine";
+{ var b = b; }
+`)
+	assert.EqualError(t, err, `resolve error: line 2, col 11, at 'a', can't read local variable in its own initializer
+resolve error: line 3, col 19, at 'this', can't use 'this' outside of a method
+resolve error: line 5, col 11, at 'b', can't read local variable in its own initializer`)
+	assert.Equal(t, exitCodeStaticError, lox.ExitCode())
+
+	err = New().Check("while (true) {}\ncontinue;\nreturn 1;")
+	assert.EqualError(t, err, `parse error: line 2, col 1, at 'continue', can't use 'continue' outside of a loop
+parse error: line 3, col 1, at 'return', can't return from top-level code`)
+}
@@ -0,0 +1,132 @@
+package lox
+
+import (
+	"math"
+	"strings"
+)
+
+// keys are restricted to strings, numbers and instances defining `hash` and
+// `equals`, entries keep insertion order
+type LoxMap struct {
+	m    map[Val]Val
+	keys []Val
+	// instance keys by their hash, an instance equal to one of its bucket
+	// is the same key
+	buckets map[Number][]*LoxInstance
+	frozen  bool // set by `freeze`, entries can't change any more
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{map[Val]Val{}, nil, nil, false}
+}
+
+func (m *LoxMap) String() string {
+	return m.format(map[Val]bool{})
+}
+
+// a map already being printed is part of a cycle and shown as `{...}`
+func (m *LoxMap) format(visiting map[Val]bool) string {
+	if visiting[m] {
+		return "{...}"
+	}
+	visiting[m] = true
+	defer delete(visiting, m)
+
+	strs := make([]string, 0, len(m.keys))
+	for _, key := range m.keys {
+		strs = append(strs, stringify(key)+": "+stringifyVisiting(m.m[key], visiting))
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
+
+// return nil for missing keys, token is used for error reporting
+func (m *LoxMap) Get(token *Token, key Val) Val {
+	checkHashable(token, key)
+	val, _ := m.lookup(key)
+	return val
+}
+
+func (m *LoxMap) Set(token *Token, key Val, val Val) {
+	if m.frozen {
+		panic(NewRuntimeError(token, "cannot modify frozen collection"))
+	}
+	checkHashable(token, key)
+	if instance, ok := key.(*LoxInstance); ok {
+		hash := instance.hash()
+		if other := m.findInstance(hash, instance); other != nil {
+			key = other
+		} else {
+			if m.buckets == nil {
+				m.buckets = map[Number][]*LoxInstance{}
+			}
+			m.buckets[hash] = append(m.buckets[hash], instance)
+		}
+	}
+	if _, ok := m.m[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.m[key] = val
+}
+
+// key must be hashable
+func (m *LoxMap) lookup(key Val) (Val, bool) {
+	if instance, ok := key.(*LoxInstance); ok {
+		other := m.findInstance(instance.hash(), instance)
+		if other == nil {
+			return nil, false
+		}
+		key = other
+	}
+	val, ok := m.m[key]
+	return val, ok
+}
+
+// the instance key equal to instance, nil if there is none
+func (m *LoxMap) findInstance(hash Number, instance *LoxInstance) Val {
+	for _, other := range m.buckets[hash] {
+		if other == instance || getTruthy(instance.equals(other)) {
+			return other
+		}
+	}
+	return nil
+}
+
+// buckets of a copy holding the same keys
+func (m *LoxMap) copyBuckets() map[Number][]*LoxInstance {
+	if m.buckets == nil {
+		return nil
+	}
+	buckets := make(map[Number][]*LoxInstance, len(m.buckets))
+	for hash, bucket := range m.buckets {
+		buckets[hash] = append([]*LoxInstance(nil), bucket...)
+	}
+	return buckets
+}
+
+// NaN is not equal to itself, an entry with NaN key could never be found
+func checkHashable(token *Token, key Val) {
+	if instance, ok := key.(*LoxInstance); ok {
+		checkHashableInstance(token, instance)
+		return
+	}
+	if !isNumber(key) && !isString(key) {
+		panic(NewRuntimeError(token, "map keys must be strings or numbers"))
+	}
+	if n, ok := key.(Number); ok && math.IsNaN(float64(n)) {
+		panic(NewRuntimeError(token, "map keys can't be NaN"))
+	}
+}
+
+func checkHashableInstance(token *Token, instance *LoxInstance) {
+	class := instance.class
+	hash, equals := class.findMethod("hash"), class.findMethod("equals")
+	if hash == nil || equals == nil || class.in == nil {
+		panic(NewRuntimeError(token, sprintf("type %s is not hashable, define 'hash' and 'equals' to use its instances as map keys", class.name)))
+	}
+	if min, _ := hash.Arity(); min > 0 {
+		panic(NewRuntimeError(token, "method 'hash' must take no arguments"))
+	}
+	if min, max := equals.Arity(); min > 1 || max == 0 {
+		panic(NewRuntimeError(token, "method 'equals' must take one argument"))
+	}
+}
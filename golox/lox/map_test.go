@@ -0,0 +1,130 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapLiteral(t *testing.T) {
+	lox := evalSource(t, `
+var empty = {};
+var m = {"a": 1, "b": {"c": [2]}, 3: "three"};
+var a = m["a"];
+var c = m["b"]["c"][0];
+var three = m[1 + 2];
+`)
+	assert.Equal(t, 0, len(getGlobal(lox, "empty").(*LoxMap).keys))
+	assert.Equal(t, Number(1), getGlobal(lox, "a"))
+	assert.Equal(t, Number(2), getGlobal(lox, "c"))
+	assert.Equal(t, "three", getGlobal(lox, "three"))
+	assert.Equal(t, "{a: 1, b: {c: [2]}, 3: three}", getGlobal(lox, "m").(*LoxMap).String())
+}
+
+func TestMapIndexSet(t *testing.T) {
+	lox := evalSource(t, `
+var m = {"a": 1};
+m["b"] = 2;
+m["a"] = "overwritten";
+var missing = m["missing"];
+var size = len(m);
+`)
+	m := getGlobal(lox, "m").(*LoxMap)
+	assert.Equal(t, "overwritten", m.m["a"])
+	assert.Equal(t, Number(2), m.m["b"])
+	assert.Equal(t, []Val{"a", "b"}, m.keys)
+	assert.Nil(t, getGlobal(lox, "missing"))
+	assert.Equal(t, Number(2), getGlobal(lox, "size"))
+}
+
+func TestMapKeyError(t *testing.T) {
+	errors := map[string]string{
+		"var m = {nil: 1};":      "runtime error: line 1, col 9, map keys must be strings or numbers",
+		"var m = {}; m[[]] = 1;": "runtime error: line 1, col 17, map keys must be strings or numbers",
+		"var m = {}; m[true];":   "runtime error: line 1, col 19, map keys must be strings or numbers",
+		`var m = {"a" 1};`:       "parse error: line 1, col 14, at '1', expect ':' after map key",
+		// NaN is produced by inf - inf
+		"var inf = 1; for (var i = 0; i < 400; i += 1) inf *= 10; var m = {}; m[inf - inf] = 1;": "runtime error: line 1, col 81, map keys can't be NaN",
+		"var inf = 1; for (var i = 0; i < 400; i += 1) inf *= 10; var n = {inf - inf: 1};":       "runtime error: line 1, col 66, map keys can't be NaN",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg)
+	}
+}
+
+func TestMapCyclicString(t *testing.T) {
+	lox := evalSource(t, `
+var m = {"a": 1};
+m["self"] = m;
+m["list"] = [m];
+`)
+	assert.Equal(t, "{a: 1, self: {...}, list: [{...}]}", getGlobal(lox, "m").(*LoxMap).String())
+
+	// a cycle going through both a list and a map
+	var out bytes.Buffer
+	lox.SetOutput(&out)
+	assert.Nil(t, lox.Run(`
+var l = [];
+var n = {"l": l};
+push(l, n);
+print l;
+print n;
+`))
+	assert.Equal(t, "[{l: [...]}]\n{l: [{...}]}\n", out.String())
+}
+
+func TestMapInstanceKeys(t *testing.T) {
+	lox := evalSource(t, `
+class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  hash() { return this.x * 31 + this.y; }
+  equals(other) { return this.x == other.x and this.y == other.y; }
+}
+// every instance collides, equals tells them apart
+class Collide {
+  init(id) { this.id = id; }
+  hash() { return 0; }
+  equals(other) { return this.id == other.id; }
+}
+var m = {Point(1, 2): "a"};
+m[Point(3, 4)] = "b";
+m[Point(1, 2)] = "overwritten";
+var found = m[Point(1, 2)];
+var other = m[Point(3, 4)];
+var missing = m[Point(2, 1)];
+var size = len(m);
+
+var c = {};
+for (var i = 0; i < 3; i++) c[Collide(i)] = i * 10;
+var collided = [c[Collide(0)], c[Collide(1)], c[Collide(2)], c[Collide(3)]];
+
+var copied = copy(m)[Point(3, 4)];
+var equal = m == {Point(1, 2): "overwritten", Point(3, 4): "b"};
+var keys = [];
+for (key in m) push(keys, key.x);
+`)
+	assert.Equal(t, "overwritten", getGlobal(lox, "found"))
+	assert.Equal(t, "b", getGlobal(lox, "other"))
+	assert.Nil(t, getGlobal(lox, "missing"))
+	assert.Equal(t, Number(2), getGlobal(lox, "size"))
+	assert.Equal(t, "[0, 10, 20, nil]", stringify(getGlobal(lox, "collided")))
+	assert.Equal(t, "b", getGlobal(lox, "copied"))
+	assert.Equal(t, true, getGlobal(lox, "equal"))
+	// the first instance inserted is kept as the key
+	assert.Equal(t, "[1, 3]", stringify(getGlobal(lox, "keys")))
+
+	errors := map[string]string{
+		"class A {}\nvar m = {A(): 1};":                                                    "runtime error: line 2, col 9, type A is not hashable, define 'hash' and 'equals' to use its instances as map keys",
+		"class A { hash() { return 1; } }\nvar m = {}; m[A()];":                            "runtime error: line 2, col 18, type A is not hashable, define 'hash' and 'equals' to use its instances as map keys",
+		"class A { hash() { return nil; } equals(o) { return true; } }\nvar m = {A(): 1};": "runtime error: line 1, col 11, 'hash' must return a number other than NaN",
+		"class A { hash(a) { return 1; } equals(o) { return true; } }\nvar m = {A(): 1};":  "runtime error: line 2, col 9, method 'hash' must take no arguments",
+		"class A { hash() { return 1; } equals() { return true; } }\nvar m = {A(): 1};":    "runtime error: line 2, col 9, method 'equals' must take one argument",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
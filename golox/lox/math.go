@@ -0,0 +1,84 @@
+package lox
+
+import "math"
+
+// math builtins, arguments must be numbers
+func defineMath(in *Interpreter) {
+	in.globals.DefineConst("pi", Number(math.Pi))
+	defineUnaryMath(in, "sqrt", math.Sqrt)
+	defineUnaryMath(in, "floor", math.Floor)
+	defineUnaryMath(in, "ceil", math.Ceil)
+	// halves are rounded away from zero
+	defineUnaryMath(in, "round", math.Round)
+	defineUnaryMath(in, "abs", math.Abs)
+	in.RegisterNative("pow", 2, nativePow)
+	in.globals.Define("min", NewVariadicFunction("min", 1, func(_ *Interpreter, args []Val) Val {
+		return extremum("min", args, math.Min)
+	}))
+	in.globals.Define("max", NewVariadicFunction("max", 1, func(_ *Interpreter, args []Val) Val {
+		return extremum("max", args, math.Max)
+	}))
+	in.globals.Define("random", NewFunction("random", 0, nativeRandom))
+	in.globals.Define("randomInt", NewFunction("randomInt", 2, nativeRandomInt))
+	in.globals.Define("seed", NewFunction("seed", 1, nativeSeed))
+}
+
+func defineUnaryMath(in *Interpreter, name string, fn func(float64) float64) {
+	in.RegisterNative(name, 1, func(args []Val) Val {
+		return Number(fn(numberArg(name, args[0])))
+	})
+}
+
+func nativePow(args []Val) Val {
+	return Number(math.Pow(numberArg("pow", args[0]), numberArg("pow", args[1])))
+}
+
+// fold args with pick, which is math.Min or math.Max
+func extremum(name string, args []Val, pick func(a, b float64) float64) Val {
+	result := numberArg(name, args[0])
+	for _, arg := range args[1:] {
+		result = pick(result, numberArg(name, arg))
+	}
+	return Number(result)
+}
+
+func numberArg(name string, val Val) float64 {
+	n, ok := val.(Number)
+	if !ok {
+		panic(NewRuntimeError(nil, name+" expects numbers"))
+	}
+	return float64(n)
+}
+
+// integers beyond this can't all be represented by Number
+const maxSafeInteger = 1 << 53
+
+// random() returns a number in [0, 1)
+func nativeRandom(in *Interpreter, _ []Val) Val {
+	return Number(in.random.Float64())
+}
+
+// randomInt(min, max) returns an integer in [min, max]
+func nativeRandomInt(in *Interpreter, args []Val) Val {
+	if !isSafeInteger(args[0]) || !isSafeInteger(args[1]) {
+		panic(NewRuntimeError(nil, "randomInt bounds must be integers"))
+	}
+	min, max := int64(toNumber(args[0])), int64(toNumber(args[1]))
+	if min > max {
+		panic(NewRuntimeError(nil, sprintf("randomInt min %d is greater than max %d", min, max)))
+	}
+	return Number(min + in.random.Int63n(max-min+1))
+}
+
+// seed(n) makes the following random numbers repeatable
+func nativeSeed(in *Interpreter, args []Val) Val {
+	if !isInteger(args[0]) {
+		panic(NewRuntimeError(nil, "seed expects an integer"))
+	}
+	in.random.Seed(int64(toNumber(args[0])))
+	return nil
+}
+
+func isSafeInteger(val Val) bool {
+	return isInteger(val) && math.Abs(float64(toNumber(val))) <= maxSafeInteger
+}
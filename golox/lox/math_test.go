@@ -0,0 +1,105 @@
+package lox
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMath(t *testing.T) {
+	cases := map[string]Number{
+		"sqrt(16)":            4,
+		"floor(2.7)":          2,
+		"floor(-2.5)":         -3,
+		"ceil(2.1)":           3,
+		"ceil(-2.5)":          -2,
+		"round(2.5)":          3,
+		"round(-2.5)":         -3,
+		"round(2.4)":          2,
+		"abs(-3)":             3,
+		"abs(3)":              3,
+		"pow(2, 10)":          1024,
+		"pow(4, 0.5)":         2,
+		"min(3)":              3,
+		"min(3, 1, 2)":        1,
+		"max(3, 1, 2)":        3,
+		"max(-1, -5)":         -1,
+		"floor(pi * 100)":     314,
+		"min(1, 2) + max(pi)": Number(1 + math.Pi),
+	}
+	for source, expected := range cases {
+		assert.Equal(t, expected, evalExpr(t, source), source)
+	}
+	assert.True(t, math.IsNaN(float64(evalExpr(t, "sqrt(-1)").(Number))))
+
+	errors := map[string]string{
+		`sqrt("4");`:   "runtime error: line 1, col 9, sqrt expects numbers",
+		"floor(nil);":  "runtime error: line 1, col 10, floor expects numbers",
+		"ceil(true);":  "runtime error: line 1, col 10, ceil expects numbers",
+		"round([]);":   "runtime error: line 1, col 9, round expects numbers",
+		`abs("x");`:    "runtime error: line 1, col 8, abs expects numbers",
+		`pow(2, "3");`: "runtime error: line 1, col 11, pow expects numbers",
+		`min(1, "2");`: "runtime error: line 1, col 11, min expects numbers",
+		`max("1");`:    "runtime error: line 1, col 8, max expects numbers",
+		"min();":       "runtime error: line 1, col 5, expect at least 1 arguments but got 0",
+		"sqrt(1, 2);":  "runtime error: line 1, col 10, expect 1 arguments but got 2",
+		"pi = 3;":      "runtime error: line 1, col 1, cannot assign to constant 'pi'",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
+
+func TestRandom(t *testing.T) {
+	source := `
+var numbers = [];
+for (var i = 0; i < 20; i++) push(numbers, random());
+var ints = [];
+for (var i = 0; i < 20; i++) push(ints, randomInt(-2, 2));
+`
+	run := func(options ...Option) (Val, Val) {
+		lox := New(options...)
+		assert.Nil(t, lox.Run(source))
+		return getGlobal(lox, "numbers"), getGlobal(lox, "ints")
+	}
+
+	numbers, ints := run(Seed(42))
+	for _, n := range numbers.(*LoxList).elements {
+		assert.True(t, n.(Number) >= 0 && n.(Number) < 1, n)
+	}
+	for _, n := range ints.(*LoxList).elements {
+		assert.True(t, isInteger(n) && n.(Number) >= -2 && n.(Number) <= 2, n)
+	}
+
+	// same seed, same sequence
+	again, againInts := run(Seed(42))
+	assert.Equal(t, numbers, again)
+	assert.Equal(t, ints, againInts)
+	other, _ := run(Seed(7))
+	assert.NotEqual(t, numbers, other)
+
+	// seed from the script
+	lox := evalSource(t, `
+seed(1);
+var a = [random(), randomInt(1, 100)];
+seed(1);
+var b = [random(), randomInt(1, 100)];
+var same = a == b;
+var single = randomInt(3, 3);
+`)
+	assert.Equal(t, true, getGlobal(lox, "same"))
+	assert.Equal(t, Number(3), getGlobal(lox, "single"))
+
+	errors := map[string]string{
+		"randomInt(1.5, 2);":     "runtime error: line 1, col 17, randomInt bounds must be integers",
+		`randomInt(1, "2");`:     "runtime error: line 1, col 17, randomInt bounds must be integers",
+		"randomInt(0, 2 ** 60);": "runtime error: line 1, col 21, randomInt bounds must be integers",
+		"randomInt(2, 1);":       "runtime error: line 1, col 15, randomInt min 2 is greater than max 1",
+		"seed(nil);":             "runtime error: line 1, col 9, seed expects an integer",
+		"random(1);":             "runtime error: line 1, col 9, expect 0 arguments but got 1",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
@@ -0,0 +1,462 @@
+package lox
+
+// Optimizer rewrites a resolved program before execution, subexpressions
+// whose operands are all literals are folded into a single literal using
+// the same rules as evaluation, and branches a literal condition never
+// takes are removed. Nodes are rewritten in place, so what the resolver
+// computed stays valid.
+type Optimizer struct{}
+
+func NewOptimizer() *Optimizer {
+	return &Optimizer{}
+}
+
+func (o *Optimizer) Optimize(stmts []Stmt) []Stmt {
+	return o.optimizeStmts(stmts)
+}
+
+/*----------  Stmt: Print  ----------*/
+
+func (s *StmtPrint) optimize(o *Optimizer) Stmt {
+	s.expr = s.expr.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Assert  ----------*/
+
+func (s *StmtAssert) optimize(o *Optimizer) Stmt {
+	s.condition = s.condition.optimize(o)
+	if s.message != nil {
+		s.message = s.message.optimize(o)
+	}
+	return s
+}
+
+/*----------  Stmt: Import  ----------*/
+
+// imported file is optimized when it is run
+func (s *StmtImport) optimize(o *Optimizer) Stmt {
+	return s
+}
+
+/*----------  Stmt: Try  ----------*/
+
+func (s *StmtTry) optimize(o *Optimizer) Stmt {
+	s.tryBlock = o.optimizeStmts(s.tryBlock)
+	s.catchBlock = o.optimizeStmts(s.catchBlock)
+	s.finallyBlock = o.optimizeStmts(s.finallyBlock)
+	return s
+}
+
+/*----------  Stmt: Throw  ----------*/
+
+func (s *StmtThrow) optimize(o *Optimizer) Stmt {
+	s.value = s.value.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Defer  ----------*/
+
+func (s *StmtDefer) optimize(o *Optimizer) Stmt {
+	s.call.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Expression  ----------*/
+
+func (s *StmtExpression) optimize(o *Optimizer) Stmt {
+	s.expr = s.expr.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Variable Declaration  ----------*/
+
+func (s *StmtVarDecl) optimize(o *Optimizer) Stmt {
+	if s.value != nil {
+		s.value = s.value.optimize(o)
+	}
+	return s
+}
+
+/*----------  Stmt: Variable Unpacking  ----------*/
+
+func (s *StmtVarUnpack) optimize(o *Optimizer) Stmt {
+	s.value = s.value.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Constant Declaration  ----------*/
+
+func (s *StmtConstDecl) optimize(o *Optimizer) Stmt {
+	s.value = s.value.optimize(o)
+	return s
+}
+
+/*----------  Stmt: Block  ----------*/
+
+func (s *StmtBlock) optimize(o *Optimizer) Stmt {
+	s.stmts = o.optimizeStmts(s.stmts)
+	return s
+}
+
+/*----------  Stmt: If  ----------*/
+
+// a literal condition is replaced by the taken branch, nil if there is none
+func (s *StmtIf) optimize(o *Optimizer) Stmt {
+	s.condition = s.condition.optimize(o)
+	s.trueBranch = o.optimizeStmt(s.trueBranch)
+	if s.falseBranch != nil {
+		s.falseBranch = o.optimizeStmt(s.falseBranch)
+	}
+	if condition, ok := s.condition.(*ExprLiteral); ok {
+		if getTruthy(condition.value) {
+			return s.trueBranch
+		}
+		return s.falseBranch
+	}
+	return s
+}
+
+/*----------  Stmt: While  ----------*/
+
+// a loop that never runs is dropped
+func (s *StmtWhile) optimize(o *Optimizer) Stmt {
+	s.condition = s.condition.optimize(o)
+	if condition, ok := s.condition.(*ExprLiteral); ok && !getTruthy(condition.value) {
+		return nil
+	}
+	s.body = o.optimizeStmt(s.body)
+	return s
+}
+
+/*----------  Stmt: Do While  ----------*/
+
+func (s *StmtDoWhile) optimize(o *Optimizer) Stmt {
+	s.body = o.optimizeStmt(s.body)
+	s.condition = s.condition.optimize(o)
+	return s
+}
+
+/*----------  Stmt: For  ----------*/
+
+func (s *StmtFor) optimize(o *Optimizer) Stmt {
+	if s.initializer != nil {
+		s.initializer = o.optimizeStmt(s.initializer)
+	}
+	if s.condition != nil {
+		s.condition = s.condition.optimize(o)
+	}
+	if s.increment != nil {
+		s.increment = s.increment.optimize(o)
+	}
+	s.body = o.optimizeStmt(s.body)
+	return s
+}
+
+/*----------  Stmt: For Each  ----------*/
+
+func (s *StmtForEach) optimize(o *Optimizer) Stmt {
+	s.iterable = s.iterable.optimize(o)
+	s.body = o.optimizeStmt(s.body)
+	return s
+}
+
+/*----------  Stmt: Break  ----------*/
+
+func (s *StmtBreak) optimize(o *Optimizer) Stmt {
+	return s
+}
+
+/*----------  Stmt: Continue  ----------*/
+
+func (s *StmtContinue) optimize(o *Optimizer) Stmt {
+	return s
+}
+
+/*----------  Stmt: Function Declaration  ----------*/
+
+func (s *StmtFuncDecl) optimize(o *Optimizer) Stmt {
+	for i, value := range s.defaults {
+		if value != nil {
+			s.defaults[i] = value.optimize(o)
+		}
+	}
+	s.body = o.optimizeStmts(s.body)
+	return s
+}
+
+/*----------  Stmt: Class Declaration  ----------*/
+
+func (s *StmtClassDecl) optimize(o *Optimizer) Stmt {
+	for _, method := range s.methods {
+		method.optimize(o)
+	}
+	for _, method := range s.classMethods {
+		method.optimize(o)
+	}
+	return s
+}
+
+/*----------  Stmt: Return  ----------*/
+
+// a tail call stays a call, only its arguments are folded
+func (s *StmtReturn) optimize(o *Optimizer) Stmt {
+	if s.value != nil {
+		s.value = s.value.optimize(o)
+	}
+	return s
+}
+
+/*----------  Expr: Literal  ----------*/
+
+func (expr *ExprLiteral) optimize(o *Optimizer) Expr {
+	return expr
+}
+
+/*----------  Expr: Variable  ----------*/
+
+func (expr *ExprVariable) optimize(o *Optimizer) Expr {
+	return expr
+}
+
+/*----------  Expr: Assignment  ----------*/
+
+func (expr *ExprAssignment) optimize(o *Optimizer) Expr {
+	expr.val = expr.val.optimize(o)
+	return expr
+}
+
+/*----------  Expr: Unary  ----------*/
+
+func (expr *ExprUnary) optimize(o *Optimizer) Expr {
+	expr.operand = expr.operand.optimize(o)
+	if operand, ok := expr.operand.(*ExprLiteral); ok {
+		return o.fold(expr, func() Val { return unary(expr.operator, operand.value) })
+	}
+	return expr
+}
+
+/*----------  Expr: Binary  ----------*/
+
+func (expr *ExprBinary) optimize(o *Optimizer) Expr {
+	expr.left = expr.left.optimize(o)
+	expr.right = expr.right.optimize(o)
+	left, ok := expr.left.(*ExprLiteral)
+	if !ok {
+		return expr
+	}
+	if right, ok := expr.right.(*ExprLiteral); ok {
+		return o.fold(expr, func() Val { return binary(expr.operator, left.value, right.value) })
+	}
+	return expr
+}
+
+/*----------  Expr: Grouping  ----------*/
+
+func (expr *ExprGrouping) optimize(o *Optimizer) Expr {
+	expr.operand = expr.operand.optimize(o)
+	if operand, ok := expr.operand.(*ExprLiteral); ok {
+		return o.literal(expr, operand.value)
+	}
+	return expr
+}
+
+/*----------  Expr: Logical  ----------*/
+
+// a literal left operand decides whether the right one is the result
+func (expr *ExprLogical) optimize(o *Optimizer) Expr {
+	expr.left = expr.left.optimize(o)
+	expr.right = expr.right.optimize(o)
+	left, ok := expr.left.(*ExprLiteral)
+	if !ok {
+		return expr
+	}
+	if shortCircuits(expr.operator, left.value) {
+		return o.literal(expr, left.value)
+	}
+	return expr.right
+}
+
+/*----------  Expr: Ternary  ----------*/
+
+func (expr *ExprTernary) optimize(o *Optimizer) Expr {
+	expr.condition = expr.condition.optimize(o)
+	expr.thenBranch = expr.thenBranch.optimize(o)
+	expr.elseBranch = expr.elseBranch.optimize(o)
+	if condition, ok := expr.condition.(*ExprLiteral); ok {
+		if getTruthy(condition.value) {
+			return expr.thenBranch
+		}
+		return expr.elseBranch
+	}
+	return expr
+}
+
+/*----------  Expr: Comma  ----------*/
+
+func (expr *ExprComma) optimize(o *Optimizer) Expr {
+	o.optimizeExprs(expr.exprs)
+	return expr
+}
+
+/*----------  Expr: Update  ----------*/
+
+// target is a place rather than a value, it's left as is
+func (expr *ExprUpdate) optimize(o *Optimizer) Expr {
+	return expr
+}
+
+/*----------  Expr: Function Call  ----------*/
+
+func (expr *ExprCall) optimize(o *Optimizer) Expr {
+	expr.callee = expr.callee.optimize(o)
+	o.optimizeExprs(expr.arguments)
+	return expr
+}
+
+/*----------  Expr: Property Get  ----------*/
+
+func (expr *ExprGet) optimize(o *Optimizer) Expr {
+	expr.object = expr.object.optimize(o)
+	return expr
+}
+
+/*----------  Expr: Property Set  ----------*/
+
+func (expr *ExprSet) optimize(o *Optimizer) Expr {
+	expr.object = expr.object.optimize(o)
+	expr.val = expr.val.optimize(o)
+	return expr
+}
+
+/*----------  Expr: This  ----------*/
+
+func (expr *ExprThis) optimize(o *Optimizer) Expr {
+	return expr
+}
+
+/*----------  Expr: Super  ----------*/
+
+func (expr *ExprSuper) optimize(o *Optimizer) Expr {
+	return expr
+}
+
+/*----------  Expr: List Literal  ----------*/
+
+func (expr *ExprListLiteral) optimize(o *Optimizer) Expr {
+	o.optimizeExprs(expr.elements)
+	return expr
+}
+
+/*----------  Expr: Interpolation  ----------*/
+
+// literal parts are joined into one string
+func (expr *ExprInterpolation) optimize(o *Optimizer) Expr {
+	o.optimizeExprs(expr.parts)
+	vals := make([]Val, len(expr.parts))
+	for i, part := range expr.parts {
+		literal, ok := part.(*ExprLiteral)
+		if !ok {
+			return expr
+		}
+		vals[i] = literal.value
+	}
+	return o.literal(expr, interpolate(vals))
+}
+
+/*----------  Expr: Tuple  ----------*/
+
+func (expr *ExprTuple) optimize(o *Optimizer) Expr {
+	o.optimizeExprs(expr.elements)
+	return expr
+}
+
+/*----------  Expr: Map Literal  ----------*/
+
+func (expr *ExprMapLiteral) optimize(o *Optimizer) Expr {
+	o.optimizeExprs(expr.keys)
+	o.optimizeExprs(expr.values)
+	return expr
+}
+
+/*----------  Expr: Index Get  ----------*/
+
+func (expr *ExprIndexGet) optimize(o *Optimizer) Expr {
+	expr.object = expr.object.optimize(o)
+	expr.index = expr.index.optimize(o)
+	return expr
+}
+
+/*----------  Expr: Index Set  ----------*/
+
+func (expr *ExprIndexSet) optimize(o *Optimizer) Expr {
+	expr.object = expr.object.optimize(o)
+	expr.index = expr.index.optimize(o)
+	expr.val = expr.val.optimize(o)
+	return expr
+}
+
+/*----------  Expr: Block  ----------*/
+
+func (expr *ExprBlock) optimize(o *Optimizer) Expr {
+	expr.stmts = o.optimizeStmts(expr.stmts)
+	expr.value = expr.value.optimize(o)
+	return expr
+}
+
+/*----------  Expr: Function  ----------*/
+
+func (expr *ExprFunction) optimize(o *Optimizer) Expr {
+	expr.decl.optimize(o)
+	return expr
+}
+
+/*----------  Private Methods  ----------*/
+
+// removed statements are dropped from the list
+func (o *Optimizer) optimizeStmts(stmts []Stmt) []Stmt {
+	result := stmts[:0]
+	for _, stmt := range stmts {
+		if stmt = stmt.optimize(o); stmt != nil {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// where a statement is required, a removed one becomes an empty block
+func (o *Optimizer) optimizeStmt(stmt Stmt) Stmt {
+	result := stmt.optimize(o)
+	if result == nil {
+		result = NewStmtBlock(nil)
+		result.setPos(stmt.Pos())
+	}
+	return result
+}
+
+func (o *Optimizer) optimizeExprs(exprs []Expr) {
+	for i, expr := range exprs {
+		exprs[i] = expr.optimize(o)
+	}
+}
+
+// literal replacing expr keeps its source range
+func (o *Optimizer) literal(expr Expr, value Val) Expr {
+	literal := NewExprLiteral(value)
+	literal.setPos(expr.Pos())
+	return literal
+}
+
+// an operation that would raise an error, e.g. `1 / 0`, is left for
+// runtime to report
+func (o *Optimizer) fold(expr Expr, eval func() Val) (result Expr) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(*RuntimeError); !ok {
+				panic(e)
+			}
+			result = expr
+		}
+	}()
+	return o.literal(expr, eval())
+}
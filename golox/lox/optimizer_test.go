@@ -0,0 +1,130 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parse, resolve and optimize source, return the printed program
+func optimizeSource(t *testing.T, source string) string {
+	tokens, err := NewScanner().Scan(source)
+	assert.Nil(t, err)
+	program, err := NewParser().Parse(tokens)
+	assert.Nil(t, err)
+	assert.Nil(t, NewResolver().Resolve(program))
+	return NewAstPrinter().PrintStmts(NewOptimizer().Optimize(program))
+}
+
+func TestOptimizerFolding(t *testing.T) {
+	tests := map[string]string{
+		"print 2 + 3 * 4;":                            "(print 14)",
+		`print "a" + "b" + "c";`:                      `(print "abc")`,
+		"print -(1 + 2) ** 2;":                        "(print -9)",
+		"print !(1 < 2);":                             "(print false)",
+		"print 1 == 1 ? 10 : 20;":                     "(print 10)",
+		"print nil or 2 + 2;":                         "(print 4)",
+		"print false and f();":                        "(print false)",
+		"var a; print 1 + 2 + a;":                     "(var a)\n(print (+ 3 a))",
+		"var a; print a + 1 + 2;":                     "(var a)\n(print (+ (+ a 1) 2))",
+		"var a; print a > 0 ? 1 + 1 : 3;":             "(var a)\n(print (?: (> a 0) 2 3))",
+		"func f(n = 60 * 60) { return n * (2 + 2); }": "(func f (n=3600) (return (* n 4)))",
+		`print "a ${1 + 2} ${nil}";`:                  `(print "a 3 nil")`,
+		`var a; print "${a} ${2 * 3}";`:               "(var a)\n(print (interpolate a \" \" 6))",
+	}
+	for source, expected := range tests {
+		assert.Equal(t, expected, optimizeSource(t, source), source)
+	}
+}
+
+func TestOptimizerSideEffects(t *testing.T) {
+	// calls, assignments and updates are kept even if their operands fold
+	output := optimizeSource(t, "var a = 0;\nprint len(\"a\" + \"b\") + 1;\na = 1 + 1;\na++;")
+	assert.Equal(t, "(var a 0)\n(print (+ (len \"ab\") 1))\n(; (assign a 2))\n(; (post++ a))", output)
+}
+
+func TestOptimizerDivisionByZero(t *testing.T) {
+	// folding `1 / 0` would raise an error, it's left for runtime
+	assert.Equal(t, "(print (/ 1 0))", optimizeSource(t, "print 1 / 0;"))
+	assert.Equal(t, "(print (+ 1 (/ 5 0)))", optimizeSource(t, "print 1 + 5 / (2 - 2);"))
+
+	source := "print \"before\";\nprint 1 + 5 / (2 - 2);"
+	for _, optimize := range []bool{false, true} {
+		var buf bytes.Buffer
+		lox := New(Optimize(optimize))
+		lox.SetOutput(&buf)
+		err := lox.Run(source)
+		assert.EqualError(t, err, "runtime error: line 2, col 13, divide by zero")
+		assert.Equal(t, "before\n", buf.String())
+	}
+}
+
+func TestOptimizerMatchesUnoptimized(t *testing.T) {
+	programs := []string{
+		`print 1 + 2 * 3 - 4 / 2, 7 % 3, 2 ** 3 ** 2, -(1 + 2);`,
+		`print 6 & 3, 6 | 3, 6 ^ 3, 1 << 4, 256 >> 2, ~5;`,
+		`print "foo" + "bar", "n" + 1, 1 < 2, "a" > "b", [1] == [1];`,
+		`print nil or "default", 1 and 2, false and undefined, true ? 1 : 2;`,
+		`var a = 2; print a * (3 + 4), (a = 1 + 1), a;`,
+		`func f(x = 1 + 1) { return x * (2 + 3); } print f(), f(10);`,
+		`class A { get() { return "a" + "b"; } } print A().get();`,
+		`print !nil, !(1 + 1), -"a";`,
+		`var l = [1 + 1, 2 * 2]; l[0 + 1] = 3 ** 2; print l;`,
+	}
+	for _, source := range programs {
+		var outputs []string
+		var errs []error
+		for _, optimize := range []bool{false, true} {
+			var buf bytes.Buffer
+			lox := New(Optimize(optimize))
+			lox.SetOutput(&buf)
+			errs = append(errs, lox.Run(source))
+			outputs = append(outputs, buf.String())
+		}
+		assert.Equal(t, outputs[0], outputs[1], source)
+		if errs[0] == nil {
+			assert.Nil(t, errs[1], source)
+		} else {
+			assert.EqualError(t, errs[1], errs[0].Error(), source)
+		}
+	}
+}
+
+func TestOptimizerDeadBranches(t *testing.T) {
+	tests := map[string]string{
+		`if (true) print "a"; else print "b";`:              `(print "a")`,
+		`if (1 > 2) print "a"; else print "b";`:             `(print "b")`,
+		`if (nil) print "a"; print "after";`:                `(print "after")`,
+		`while (false) print "a"; print "after";`:           `(print "after")`,
+		`{ if (false) print "a"; }`:                         `(block)`,
+		`while (true) if (false) print "a";`:                `(while true (block))`,
+		`if (true) if (false) print "a"; else print 1 + 1;`: `(print 2)`,
+		// only literal conditions are folded
+		`func f() { return true; } if (f()) print "a";`: "(func f () (return true))\n(if (f) (print \"a\"))",
+		`var debug = false; if (debug) print "a";`:      "(var debug false)\n(if debug (print \"a\"))",
+	}
+	for source, expected := range tests {
+		assert.Equal(t, expected, optimizeSource(t, source), source)
+	}
+}
+
+func TestOptimizerUnreachableNeverRuns(t *testing.T) {
+	var buf bytes.Buffer
+	lox := New(Optimize(true))
+	lox.SetOutput(&buf)
+	err := lox.Run(`
+var calls = 0;
+func effect() { calls++; return true; }
+if (false) effect(); else print "else";
+if (1 == 1) print "then"; else effect();
+while (nil) effect();
+for (var i = 0; i < 2; i++) {
+  if ("" == "x") effect();
+}
+if (effect()) print "called";
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "else\nthen\ncalled\n", buf.String())
+	assert.Equal(t, Number(1), getGlobal(lox, "calls"))
+}
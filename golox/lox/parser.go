@@ -0,0 +1,1231 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Parser struct {
+	tokens  []*Token
+	current int
+	length  int
+	// number of enclosing loops, `break` and `continue` are only allowed
+	// inside loops
+	loopDepth int
+	// labels of enclosing loops in the current function, innermost last
+	labels []*Token
+	// number of enclosing function bodies, `return` is only allowed inside
+	// functions
+	funcDepth int
+	// number of enclosing blocks, error recovery stops at a `}` inside one
+	blockDepth int
+	// errors of declarations parsing recovered from
+	errors ParseErrors
+}
+
+type ParseError struct {
+	token *Token
+	msg   string
+}
+
+func NewParseError(token *Token, msg string) *ParseError {
+	return &ParseError{token, msg}
+}
+
+func (pe *ParseError) Error() string {
+	token := pe.token
+	position := ""
+	if token.typ == EOF {
+		position = "at end"
+	} else {
+		position = fmt.Sprintf(`at '%s'`, token.lexeme)
+	}
+	return fmt.Sprintf("line %d, col %d, %s, %s", token.line, token.column, position, pe.msg)
+}
+
+func (pe *ParseError) Position() (line, column int) {
+	return pe.token.line, pe.token.column
+}
+
+func (pe *ParseError) message() string {
+	return pe.msg
+}
+
+// ParseErrors holds every error found by a parse in source order, use
+// `errors.As` to get the first `*ParseError`
+type ParseErrors []*ParseError
+
+func (pes ParseErrors) Error() string {
+	msgs := make([]string, len(pes))
+	for i, pe := range pes {
+		msgs[i] = pe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (pes ParseErrors) Unwrap() []error {
+	errs := make([]error, len(pes))
+	for i, pe := range pes {
+		errs[i] = pe
+	}
+	return errs
+}
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// parsing goes on after an error from the next statement, the error is a
+// `ParseErrors` holding all errors found
+func (p *Parser) Parse(tokens []*Token) ([]Stmt, error) {
+	p.reset(tokens)
+	var result []Stmt
+	for !p.isAtEnd() {
+		result = append(result, p.Declaration())
+	}
+	if len(p.errors) > 0 {
+		return nil, p.errors
+	}
+	return result, nil
+}
+
+// parse tokens as a single expression, all tokens must be consumed
+func (p *Parser) ParseExpression(tokens []*Token) (expr Expr, err error) {
+	p.reset(tokens)
+	defer func() {
+		if e := recover(); e != nil {
+			if pe, ok := e.(*ParseError); ok {
+				err = pe
+			} else {
+				panic(e)
+			}
+		}
+	}()
+	expr = p.Expression()
+	if !p.isAtEnd() {
+		panic(NewParseError(p.peek(), "expect end of expression"))
+	}
+	// from declarations in function bodies
+	if len(p.errors) > 0 {
+		return nil, p.errors[0]
+	}
+	return
+}
+
+/*----------  Private Methods  ----------*/
+
+// an error is recorded and parsing resumes from the next statement, the
+// result is nil then
+func (p *Parser) Declaration() (result Stmt) {
+	defer func() {
+		if e := recover(); e != nil {
+			pe, ok := e.(*ParseError)
+			if !ok {
+				panic(e)
+			}
+			p.errors = append(p.errors, pe)
+			p.synchronize()
+			result = nil
+		}
+	}()
+
+	start := p.peek()
+	switch true {
+	case p.match(VAR):
+		result = p.VarDeclaration()
+	case p.match(CONST):
+		result = p.ConstDeclaration()
+	case p.match(CLASS):
+		result = p.ClassDeclaration()
+	case p.match(FUNC):
+		result = p.FuncDeclaration("function")
+	default:
+		result = p.Statement()
+	}
+
+	return p.spanStmt(start, result)
+}
+
+func (p *Parser) ClassDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "expect class name")
+
+	var superclass *ExprVariable
+	if p.match(LESS) {
+		name := p.consume(IDENTIFIER, "expect superclass name")
+		superclass = NewExprVariable(name)
+		p.spanExpr(name, superclass)
+	}
+
+	p.consume(LEFT_BRACE, "expect '{' before class body")
+
+	var methods, classMethods []*StmtFuncDecl
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		if p.match(CLASS) {
+			classMethods = append(classMethods, p.Method())
+		} else {
+			methods = append(methods, p.Method())
+		}
+	}
+
+	p.consume(RIGHT_BRACE, "expect '}' after class body")
+	return NewStmtClassDecl(name, superclass, methods, classMethods)
+}
+
+// kind should be one of: `function`, `method`
+func (p *Parser) FuncDeclaration(kind string) *StmtFuncDecl {
+	name := p.consume(IDENTIFIER, "expect "+kind+" name")
+	fn := p.finishFunction(name, kind)
+	p.spanStmt(name, fn)
+	return fn
+}
+
+// a method without parameter list is a getter
+func (p *Parser) Method() *StmtFuncDecl {
+	name := p.consume(IDENTIFIER, "expect method name")
+	var fn *StmtFuncDecl
+	if p.match(LEFT_BRACE) {
+		if name.lexeme == "init" {
+			panic(NewParseError(name, "initializer can't be a getter"))
+		}
+		fn = NewStmtFuncDecl(name, nil, nil, false, p.functionBody())
+		fn.getter = true
+	} else {
+		fn = p.finishFunction(name, "method")
+	}
+	p.spanStmt(name, fn)
+	return fn
+}
+
+// parse parameters and body, name is nil for anonymous function
+func (p *Parser) finishFunction(name *Token, kind string) *StmtFuncDecl {
+	p.consume(LEFT_PAREN, "expect '(' after "+kind+" name")
+	var parameters []*Token
+	var defaults []Expr
+	variadic := false
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(parameters) == 8 {
+				panic(NewParseError(p.peek(), "can't have more than 8 arguments"))
+			}
+			param := p.consume(IDENTIFIER, "expect parameter name")
+			var value Expr
+			if p.match(ELLIPSIS) {
+				variadic = true
+				if !p.check(RIGHT_PAREN) {
+					panic(NewParseError(p.previous(), "variadic parameter must be the last one"))
+				}
+			} else if p.match(EQUAL) {
+				value = p.Expression()
+			} else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+				panic(NewParseError(param, "parameter without default value can't follow one with default value"))
+			}
+			parameters = append(parameters, param)
+			defaults = append(defaults, value)
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_PAREN, "expect ')' after parameters")
+	p.consume(LEFT_BRACE, "expect '{' after "+kind+" body")
+	return NewStmtFuncDecl(name, parameters, defaults, variadic, p.functionBody())
+}
+
+// parse the rest of a function body after '{'
+func (p *Parser) functionBody() []Stmt {
+	// loops outside don't count in function body
+	loopDepth, labels := p.loopDepth, p.labels
+	p.loopDepth, p.labels = 0, nil
+	p.funcDepth++
+	body := p.BlockStatement()
+	p.funcDepth--
+	p.loopDepth, p.labels = loopDepth, labels
+	return body
+}
+
+func (p *Parser) VarDeclaration() Stmt {
+	if p.match(LEFT_PAREN) {
+		return p.finishVarUnpack(p.previous())
+	}
+	name := p.consume(IDENTIFIER, "expect variable name")
+	var value Expr
+	if p.match(EQUAL) {
+		value = p.Expression()
+	}
+	p.consume(SEMICOLON, "expect ';' after variable declaration")
+	return NewStmtVarDecl(name, value)
+}
+
+// `var (a, b) = value;` after '(', value must be a tuple of as many values
+func (p *Parser) finishVarUnpack(paren *Token) Stmt {
+	var names []*Token
+	for {
+		name := p.consume(IDENTIFIER, "expect variable name")
+		for _, other := range names {
+			if other.lexeme == name.lexeme {
+				panic(NewParseError(name, sprintf("variable '%s' is unpacked more than once", name.lexeme)))
+			}
+		}
+		names = append(names, name)
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	p.consume(RIGHT_PAREN, "expect ')' after variable names")
+	p.consume(EQUAL, "expect '=' after variable names, unpacked variables must be initialized")
+	value := p.Expression()
+	p.consume(SEMICOLON, "expect ';' after variable declaration")
+	return NewStmtVarUnpack(paren, names, value)
+}
+
+func (p *Parser) ConstDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "expect constant name")
+	p.consume(EQUAL, "expect '=' after constant name, constant must be initialized")
+	value := p.Expression()
+	p.consume(SEMICOLON, "expect ';' after constant declaration")
+	return NewStmtConstDecl(name, value)
+}
+
+func (p *Parser) Statement() Stmt {
+	start := p.peek()
+
+	if p.match(PRINT) {
+		return p.spanStmt(start, p.PrintStatement())
+	}
+
+	if p.match(ASSERT) {
+		return p.spanStmt(start, p.AssertStatement())
+	}
+
+	if p.match(IMPORT) {
+		return p.spanStmt(start, p.ImportStatement())
+	}
+
+	if p.match(LEFT_BRACE) {
+		return p.spanStmt(start, NewStmtBlock(p.BlockStatement()))
+	}
+
+	if p.match(IF) {
+		return p.spanStmt(start, p.IfStatement())
+	}
+
+	if p.match(WHILE) {
+		return p.spanStmt(start, p.WhileStatement())
+	}
+
+	if p.match(DO) {
+		return p.spanStmt(start, p.DoWhileStatement())
+	}
+
+	if p.match(FOR) {
+		return p.spanStmt(start, p.ForStatement())
+	}
+
+	if p.match(RETURN) {
+		return p.spanStmt(start, p.ReturnStatement())
+	}
+
+	if p.match(BREAK) {
+		return p.spanStmt(start, p.BreakStatement())
+	}
+
+	if p.match(CONTINUE) {
+		return p.spanStmt(start, p.ContinueStatement())
+	}
+
+	if p.match(TRY) {
+		return p.spanStmt(start, p.TryStatement())
+	}
+
+	if p.match(THROW) {
+		return p.spanStmt(start, p.ThrowStatement())
+	}
+
+	if p.match(DEFER) {
+		return p.spanStmt(start, p.DeferStatement())
+	}
+
+	if p.check(IDENTIFIER) && p.tokens[p.current+1].typ == COLON {
+		return p.spanStmt(start, p.LabeledStatement())
+	}
+
+	return p.spanStmt(start, p.ExpressionStatement())
+}
+
+// `label: loop`, the label can be the target of `break` and `continue`
+// inside the loop
+func (p *Parser) LabeledStatement() Stmt {
+	label := p.advance()
+	p.advance() // consume ':'
+	if p.findLabel(label) != nil {
+		panic(NewParseError(label, sprintf("label '%s' is already used by an enclosing loop", label.lexeme)))
+	}
+	if !p.check(WHILE) && !p.check(DO) && !p.check(FOR) {
+		panic(NewParseError(p.peek(), "expect loop after label"))
+	}
+
+	p.labels = append(p.labels, label)
+	defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+	loop := p.Statement()
+	loop.(interface{ setLabel(*Token) }).setLabel(label)
+	return loop
+}
+
+// at least one of catch and finally clauses is required
+func (p *Parser) TryStatement() Stmt {
+	p.consume(LEFT_BRACE, "expect '{' after 'try'")
+	tryBlock := p.BlockStatement()
+
+	var name *Token
+	var catchBlock, finallyBlock []Stmt
+	if p.match(CATCH) {
+		p.consume(LEFT_PAREN, "expect '(' after 'catch'")
+		name = p.consume(IDENTIFIER, "expect error variable name")
+		p.consume(RIGHT_PAREN, "expect ')' after error variable name")
+		p.consume(LEFT_BRACE, "expect '{' before catch body")
+		catchBlock = p.BlockStatement()
+	}
+	if p.match(FINALLY) {
+		p.consume(LEFT_BRACE, "expect '{' after 'finally'")
+		finallyBlock = p.BlockStatement()
+		if finallyBlock == nil {
+			finallyBlock = []Stmt{}
+		}
+	}
+	if name == nil && finallyBlock == nil {
+		panic(NewParseError(p.peek(), "expect 'catch' or 'finally' after try block"))
+	}
+	return NewStmtTry(tryBlock, name, catchBlock, finallyBlock)
+}
+
+func (p *Parser) ThrowStatement() Stmt {
+	keyword := p.previous()
+	value := p.Expression()
+	p.consume(SEMICOLON, "expect ';' after thrown value")
+	return NewStmtThrow(keyword, value)
+}
+
+func (p *Parser) DeferStatement() Stmt {
+	keyword := p.previous()
+	if p.funcDepth == 0 {
+		panic(NewParseError(keyword, "can't use 'defer' outside of a function"))
+	}
+	call, ok := p.Expression().(*ExprCall)
+	if !ok {
+		panic(NewParseError(p.previous(), "expect function call after 'defer'"))
+	}
+	p.consume(SEMICOLON, "expect ';' after deferred call")
+	return NewStmtDefer(keyword, call)
+}
+
+func (p *Parser) ReturnStatement() Stmt {
+	token := p.previous()
+	if p.funcDepth == 0 {
+		panic(NewParseError(token, "can't return from top-level code"))
+	}
+	var value Expr
+	if !p.check(SEMICOLON) {
+		start := p.peek()
+		value = p.Expression()
+		// `return a, b;` returns a tuple
+		if p.check(COMMA) {
+			elements := []Expr{value}
+			for p.match(COMMA) {
+				elements = append(elements, p.Expression())
+			}
+			value = p.spanExpr(start, NewExprTuple(elements))
+		}
+	}
+	p.consume(SEMICOLON, "expect ';' after return value")
+	return NewStmtReturn(token, value)
+}
+
+func (p *Parser) BreakStatement() Stmt {
+	keyword := p.previous()
+	if p.loopDepth == 0 {
+		panic(NewParseError(keyword, "can't use 'break' outside of a loop"))
+	}
+	label := p.targetLabel()
+	p.consume(SEMICOLON, "expect ';' after 'break'")
+	return NewStmtBreak(keyword, label)
+}
+
+func (p *Parser) ContinueStatement() Stmt {
+	keyword := p.previous()
+	if p.loopDepth == 0 {
+		panic(NewParseError(keyword, "can't use 'continue' outside of a loop"))
+	}
+	label := p.targetLabel()
+	p.consume(SEMICOLON, "expect ';' after 'continue'")
+	return NewStmtContinue(keyword, label)
+}
+
+// optional label after `break` or `continue`, it must name an enclosing
+// loop
+func (p *Parser) targetLabel() *Token {
+	if !p.match(IDENTIFIER) {
+		return nil
+	}
+	label := p.previous()
+	if p.findLabel(label) == nil {
+		panic(NewParseError(label, sprintf("no enclosing loop labeled '%s'", label.lexeme)))
+	}
+	return label
+}
+
+func (p *Parser) findLabel(name *Token) *Token {
+	for _, label := range p.labels {
+		if label.lexeme == name.lexeme {
+			return label
+		}
+	}
+	return nil
+}
+
+func (p *Parser) ForStatement() Stmt {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	p.consume(LEFT_PAREN, "expect '(' after for")
+
+	if p.check(IDENTIFIER) && p.tokens[p.current+1].typ == IN {
+		return p.forEachStatement()
+	}
+
+	var initializer Stmt
+	start := p.peek()
+	if p.match(SEMICOLON) {
+		// no initializer
+	} else if p.match(VAR) {
+		initializer = p.spanStmt(start, p.VarDeclaration())
+	} else {
+		initializer = p.spanStmt(start, p.ExpressionStatement())
+	}
+
+	var condition Expr
+	if !p.check(SEMICOLON) {
+		condition = p.Expression()
+	}
+	p.consume(SEMICOLON, "expect ';' after loop condition")
+
+	var increment Expr
+	if !p.check(RIGHT_PAREN) {
+		increment = p.Comma()
+	}
+	p.consume(RIGHT_PAREN, "expect ')' after clauses")
+
+	body := p.Statement()
+
+	return NewStmtFor(initializer, condition, increment, body)
+}
+
+// `for (name in iterable) body`, called after the open paren
+func (p *Parser) forEachStatement() Stmt {
+	name := p.advance()
+	keyword := p.advance()
+	iterable := p.Expression()
+	p.consume(RIGHT_PAREN, "expect ')' after iterated value")
+	body := p.Statement()
+	return NewStmtForEach(name, keyword, iterable, body)
+}
+
+func (p *Parser) WhileStatement() Stmt {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	p.consume(LEFT_PAREN, "expect '(' after while")
+	condition := p.Expression()
+	p.consume(RIGHT_PAREN, "expect ')' after condition")
+	body := p.Statement()
+	return NewStmtWhile(condition, body)
+}
+
+func (p *Parser) DoWhileStatement() Stmt {
+	p.loopDepth++
+	body := func() Stmt {
+		defer func() { p.loopDepth-- }()
+		return p.Statement()
+	}()
+
+	p.consume(WHILE, "expect 'while' after do body")
+	p.consume(LEFT_PAREN, "expect '(' after while")
+	condition := p.Expression()
+	p.consume(RIGHT_PAREN, "expect ')' after condition")
+	p.consume(SEMICOLON, "expect ';' after do while statement")
+	return NewStmtDoWhile(body, condition)
+}
+
+func (p *Parser) IfStatement() Stmt {
+	p.consume(LEFT_PAREN, "expect '(' after if")
+	condition := p.Expression()
+	p.consume(RIGHT_PAREN, "expect ')' after if condition")
+	trueBranch := p.Statement()
+	var falseBranch Stmt
+
+	if p.match(ELSE) {
+		falseBranch = p.Statement()
+	}
+	return NewStmtIf(condition, trueBranch, falseBranch)
+}
+
+func (p *Parser) BlockStatement() []Stmt {
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	var stmts []Stmt
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		stmts = append(stmts, p.Declaration())
+	}
+	p.consume(RIGHT_BRACE, "expect '}' after block")
+	return stmts
+}
+
+func (p *Parser) AssertStatement() Stmt {
+	keyword := p.previous()
+	condition := p.Expression()
+	var message Expr
+	if p.match(COMMA) {
+		message = p.Expression()
+	}
+	p.consume(SEMICOLON, "expect ';' after assertion")
+	return NewStmtAssert(keyword, condition, message)
+}
+
+func (p *Parser) ImportStatement() Stmt {
+	keyword := p.previous()
+	path := p.consume(STRING, "expect path string after 'import'")
+	p.consume(SEMICOLON, "expect ';' after import path")
+	return NewStmtImport(keyword, path.literal.(string))
+}
+
+func (p *Parser) PrintStatement() Stmt {
+	expr := p.Expression()
+	p.consume(SEMICOLON, "expect ';' after value")
+	return NewStmtPrint(expr)
+}
+
+func (p *Parser) ExpressionStatement() Stmt {
+	expr := p.Comma()
+	p.consume(SEMICOLON, "expect ';' after value")
+	return NewStmtExpression(expr)
+}
+
+// comma operator has the lowest precedence, it's only allowed where a
+// comma can't mean anything else: expression statements and for increment
+func (p *Parser) Comma() Expr {
+	start := p.peek()
+	return p.finishComma(start, p.Expression())
+}
+
+// first is the expression before the comma, if any
+func (p *Parser) finishComma(start *Token, first Expr) Expr {
+	if !p.check(COMMA) {
+		return first
+	}
+	exprs := []Expr{first}
+	for p.match(COMMA) {
+		exprs = append(exprs, p.Expression())
+	}
+	return p.spanExpr(start, NewExprComma(exprs))
+}
+
+func (p *Parser) Expression() Expr {
+	return p.Assignment()
+}
+
+func (p *Parser) Assignment() Expr {
+	start := p.peek()
+	expr := p.Ternary()
+
+	if p.match(EQUAL) {
+		equal := p.previous()
+		value := p.Assignment()
+		return p.spanExpr(start, p.assign(equal, expr, value))
+	}
+
+	// desugar `a += b` to `a = a + b`
+	if p.match(PLUS_EQUAL, MINUS_EQUAL, STAR_EQUAL, SLASH_EQUAL) {
+		operator := p.previous()
+		value := p.Assignment()
+
+		if e, ok := expr.(*ExprVariable); ok {
+			binary := p.spanExpr(start, NewExprBinary(e, compoundOperator(operator), value))
+			return p.spanExpr(start, NewExprAssignment(e.name, binary))
+		}
+
+		panic(NewParseError(operator, "invalid assignment target"))
+	}
+
+	return expr
+}
+
+// right associative: `a ? b : c ? d : e` is `a ? b : (c ? d : e)`
+func (p *Parser) Ternary() Expr {
+	start := p.peek()
+	expr := p.Coalesce()
+
+	if p.match(QUESTION) {
+		thenBranch := p.Expression()
+		p.consume(COLON, "expect ':' after then branch of conditional expression")
+		elseBranch := p.Ternary()
+		expr = p.spanExpr(start, NewExprTernary(expr, thenBranch, elseBranch))
+	}
+
+	return expr
+}
+
+// `??` shares ExprLogical with `and` and `or`
+func (p *Parser) Coalesce() Expr {
+	start := p.peek()
+	expr := p.LogicalOr()
+
+	for p.match(QUESTION_QUESTION) {
+		operator := p.previous()
+		right := p.LogicalOr()
+		expr = p.spanExpr(start, NewExprLogical(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) LogicalOr() Expr {
+	start := p.peek()
+	expr := p.LogicalAnd()
+
+	for p.match(OR) {
+		operator := p.previous()
+		right := p.LogicalAnd()
+		expr = p.spanExpr(start, NewExprLogical(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) LogicalAnd() Expr {
+	start := p.peek()
+	expr := p.Equality()
+
+	for p.match(AND) {
+		operator := p.previous()
+		right := p.Equality()
+		expr = p.spanExpr(start, NewExprLogical(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) Equality() Expr {
+	start := p.peek()
+	expr := p.Comparison()
+
+	for p.match(BANG_EQUAL, EQUAL_EQUAL) {
+		operator := p.previous()
+		right := p.Comparison()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+// comparisons don't chain, `a < b < c` would compare a boolean with `c`
+func (p *Parser) Comparison() Expr {
+	start := p.peek()
+	expr := p.BitwiseOr()
+
+	if p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, ISA) {
+		operator := p.previous()
+		right := p.BitwiseOr()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+		if p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL, ISA) {
+			panic(NewParseError(p.previous(), "comparisons can't be chained, use 'a < b and b < c' instead"))
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) BitwiseOr() Expr {
+	start := p.peek()
+	expr := p.BitwiseXor()
+
+	for p.match(PIPE) {
+		operator := p.previous()
+		right := p.BitwiseXor()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) BitwiseXor() Expr {
+	start := p.peek()
+	expr := p.BitwiseAnd()
+
+	for p.match(CARET) {
+		operator := p.previous()
+		right := p.BitwiseAnd()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) BitwiseAnd() Expr {
+	start := p.peek()
+	expr := p.Shift()
+
+	for p.match(AMPERSAND) {
+		operator := p.previous()
+		right := p.Shift()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) Shift() Expr {
+	start := p.peek()
+	expr := p.Addition()
+
+	for p.match(LESS_LESS, GREATER_GREATER) {
+		operator := p.previous()
+		right := p.Addition()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) Addition() Expr {
+	start := p.peek()
+	expr := p.Multiplication()
+
+	for p.match(PLUS, MINUS) {
+		operator := p.previous()
+		right := p.Multiplication()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) Multiplication() Expr {
+	start := p.peek()
+	expr := p.Unary()
+	for p.match(STAR, SLASH, PERCENT) {
+		operator := p.previous()
+		right := p.Unary()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+
+	return expr
+}
+
+func (p *Parser) Unary() Expr {
+	start := p.peek()
+	if p.match(BANG, MINUS) {
+		operator := p.previous()
+		operand := p.Unary()
+		return p.spanExpr(start, NewExprUnary(operator, operand))
+	}
+
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		operator := p.previous()
+		target := p.Unary()
+		p.checkUpdateTarget(operator, target)
+		return p.spanExpr(start, NewExprUpdate(operator, target, true))
+	}
+
+	return p.Exponent()
+}
+
+// binds tighter than unary on its left: `-2 ** 2` is `-(2 ** 2)`, right
+// operand is unary, which makes `**` right associative
+func (p *Parser) Exponent() Expr {
+	start := p.peek()
+	expr := p.Postfix()
+	if p.match(STAR_STAR) {
+		operator := p.previous()
+		right := p.Unary()
+		expr = p.spanExpr(start, NewExprBinary(expr, operator, right))
+	}
+	return expr
+}
+
+func (p *Parser) Postfix() Expr {
+	start := p.peek()
+	expr := p.Call()
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		operator := p.previous()
+		p.checkUpdateTarget(operator, expr)
+		return p.spanExpr(start, NewExprUpdate(operator, expr, false))
+	}
+	return expr
+}
+
+func (p *Parser) checkUpdateTarget(operator *Token, target Expr) {
+	if !isAssignable(target) {
+		panic(NewParseError(operator, "invalid "+operator.lexeme+" target"))
+	}
+}
+
+// variables, properties and elements can be assigned to, `a?.b` can't
+// since there may be no object
+func isAssignable(target Expr) bool {
+	switch target := target.(type) {
+	case *ExprVariable, *ExprIndexGet:
+		return true
+	case *ExprGet:
+		return !target.optional
+	}
+	return false
+}
+
+// the node storing value to target, an invalid target is reported at the
+// `=` operator
+func (p *Parser) assign(operator *Token, target Expr, value Expr) Expr {
+	if !isAssignable(target) {
+		panic(NewParseError(operator, "invalid assignment target"))
+	}
+	switch target := target.(type) {
+	case *ExprVariable:
+		return NewExprAssignment(target.name, value)
+	case *ExprGet:
+		return NewExprSet(target.object, target.name, value)
+	}
+	index := target.(*ExprIndexGet)
+	return NewExprIndexSet(index.object, index.bracket, index.index, value)
+}
+
+func (p *Parser) Call() Expr {
+	start := p.peek()
+	expr := p.Primary()
+	for true {
+		if p.match(LEFT_PAREN) {
+			expr = p.spanExpr(start, p.finishCall(expr))
+		} else if p.match(DOT, QUESTION_DOT) {
+			optional := p.previous().typ == QUESTION_DOT
+			name := p.consume(IDENTIFIER, "expect property name after '"+p.previous().lexeme+"'")
+			expr = p.spanExpr(start, NewExprGet(expr, name, optional))
+		} else if p.match(LEFT_BRACKET) {
+			index := p.Expression()
+			bracket := p.consume(RIGHT_BRACKET, "expect ']' after index")
+			expr = p.spanExpr(start, NewExprIndexGet(expr, bracket, index))
+		} else {
+			break
+		}
+	}
+	return expr
+}
+
+func (p *Parser) Primary() Expr {
+	start := p.peek()
+
+	if p.match(TRUE) {
+		return p.spanExpr(start, NewExprLiteral(true))
+	}
+
+	if p.match(FALSE) {
+		return p.spanExpr(start, NewExprLiteral(false))
+	}
+
+	if p.match(NIL) {
+		return p.spanExpr(start, NewExprLiteral(nil))
+	}
+
+	if p.match(NUMBER, STRING) {
+		return p.spanExpr(start, NewExprLiteral(p.previous().literal))
+	}
+
+	if p.match(INTERPOLATION) {
+		return p.spanExpr(start, p.finishInterpolation(start))
+	}
+
+	if p.match(LEFT_PAREN) {
+		expr := p.Expression()
+		p.consume(RIGHT_PAREN, "expect ')' after expression")
+		return p.spanExpr(start, NewExprGrouping(expr))
+	}
+
+	if p.match(LEFT_BRACKET) {
+		return p.spanExpr(start, p.finishList())
+	}
+
+	if p.match(FUNC) {
+		// named one can refer to itself
+		var name *Token
+		if p.check(IDENTIFIER) {
+			name = p.advance()
+		}
+		decl := p.finishFunction(name, "function")
+		p.spanStmt(start, decl)
+		return p.spanExpr(start, NewExprFunction(decl))
+	}
+
+	// a statement starting with `{` is a block, so `{` here is a map or a
+	// block expression
+	if p.match(LEFT_BRACE) {
+		return p.spanExpr(start, p.finishBrace())
+	}
+
+	if p.match(SUPER) {
+		keyword := p.previous()
+		p.consume(DOT, "expect '.' after 'super'")
+		method := p.consume(IDENTIFIER, "expect superclass method name")
+		return p.spanExpr(start, NewExprSuper(keyword, method))
+	}
+
+	if p.match(THIS) {
+		return p.spanExpr(start, NewExprThis(p.previous()))
+	}
+
+	if p.match(IDENTIFIER) {
+		return p.spanExpr(start, NewExprVariable(p.previous()))
+	}
+
+	panic(NewParseError(p.peek(), "expect expression"))
+}
+
+/*----------  Helper Mehtods  ----------*/
+func (p *Parser) reset(tokens []*Token) {
+	p.tokens = tokens
+	p.length = len(tokens)
+	p.current = 0
+	p.loopDepth = 0
+	p.funcDepth = 0
+	p.blockDepth = 0
+	p.errors = nil
+}
+
+func (p *Parser) isAtEnd() bool {
+	return p.peek().typ == EOF
+}
+
+func (p *Parser) peek() *Token {
+	return p.tokens[p.current]
+}
+
+func (p *Parser) previous() *Token {
+	return p.tokens[p.current-1]
+}
+
+func (p *Parser) check(typ TokenType) bool {
+	if p.isAtEnd() {
+		return false
+	}
+	return p.peek().typ == typ
+}
+
+// whether next token is a keyword beginning a statement
+func (p *Parser) checkStatementStart() bool {
+	switch p.peek().typ {
+	case CLASS, FUNC, VAR, CONST, FOR, IF, WHILE, DO, PRINT, RETURN,
+		BREAK, CONTINUE, TRY, THROW, DEFER, IMPORT, ASSERT:
+		return true
+	}
+	return false
+}
+
+// string parts after the first one start with the `}` closing an embedded
+// expression, so they can't be taken for a string literal
+func (p *Parser) checkStringPart() bool {
+	token := p.peek()
+	return (token.typ == INTERPOLATION || token.typ == STRING) &&
+		strings.HasPrefix(token.lexeme, "}")
+}
+
+func (p *Parser) advance() *Token {
+	if !p.isAtEnd() {
+		p.current++
+	}
+	return p.previous()
+}
+
+func (p *Parser) match(tokenTypes ...TokenType) bool {
+	for _, typ := range tokenTypes {
+		if p.check(typ) {
+			p.advance()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) consume(typ TokenType, msg string) *Token {
+	if p.check(typ) {
+		return p.advance()
+	}
+	panic(NewParseError(p.peek(), msg))
+}
+
+// set span of node from start to the last consumed token
+func (p *Parser) spanExpr(start *Token, expr Expr) Expr {
+	expr.setPos(start.start(), p.previous().end())
+	return expr
+}
+
+func (p *Parser) spanStmt(start *Token, stmt Stmt) Stmt {
+	stmt.setPos(start.start(), p.previous().end())
+	return stmt
+}
+
+// `"a ${b} c"` has parts `"a "`, `b` and `" c"`, empty parts are left out
+func (p *Parser) finishInterpolation(start *Token) Expr {
+	var parts []Expr
+	for {
+		part := p.previous()
+		if str := part.literal.(string); str != "" {
+			parts = append(parts, p.spanExpr(part, NewExprLiteral(str)))
+		}
+		if part.typ == STRING {
+			break
+		}
+		if p.checkStringPart() {
+			panic(NewParseError(p.peek(), "expect expression inside '${}'"))
+		}
+		parts = append(parts, p.Expression())
+		if !p.checkStringPart() {
+			panic(NewParseError(p.peek(), "expect '}' after interpolated expression"))
+		}
+		p.advance()
+	}
+	return p.spanExpr(start, NewExprInterpolation(parts))
+}
+
+func (p *Parser) finishCall(callee Expr) Expr {
+	var arguments []Expr
+	if !p.check(RIGHT_PAREN) {
+		arguments = append(arguments, p.Expression())
+		for p.match(COMMA) {
+			// in order to compitable with C implementation, we limit
+			// argument size
+			if len(arguments) >= 8 {
+				panic(NewParseError(p.peek(), "can't have more than 8 arguments"))
+			}
+			arguments = append(arguments, p.Expression())
+		}
+	}
+
+	paren := p.consume(RIGHT_PAREN, "exepct ')' after function arguments")
+	return NewExprCall(callee, paren, arguments)
+}
+
+// `+=` -> `+`, etc
+func compoundOperator(token *Token) *Token {
+	var typ TokenType
+	switch token.typ {
+	case PLUS_EQUAL:
+		typ = PLUS
+	case MINUS_EQUAL:
+		typ = MINUS
+	case STAR_EQUAL:
+		typ = STAR
+	case SLASH_EQUAL:
+		typ = SLASH
+	}
+	return NewToken(typ, token.lexeme[:1], nil, token.line, token.column)
+}
+
+func (p *Parser) finishList() Expr {
+	var elements []Expr
+	if !p.check(RIGHT_BRACKET) {
+		elements = append(elements, p.Expression())
+		for p.match(COMMA) {
+			elements = append(elements, p.Expression())
+		}
+	}
+	p.consume(RIGHT_BRACKET, "expect ']' after list elements")
+	return NewExprListLiteral(elements)
+}
+
+// `{}` is an empty map, `{` followed by an expression and `:` starts a
+// map, anything else a block expression
+func (p *Parser) finishBrace() Expr {
+	brace := p.previous()
+	if p.check(RIGHT_BRACE) {
+		return p.finishMap(brace, nil)
+	}
+	if p.check(LEFT_BRACE) || p.checkStatementStart() {
+		return p.finishBlockExpr(nil, nil)
+	}
+	start := p.peek()
+	first := p.Expression()
+	// neither a statement ending nor the value, most likely a map missing `:`
+	if p.check(COLON) || !(p.check(SEMICOLON) || p.check(COMMA) || p.check(RIGHT_BRACE)) {
+		return p.finishMap(brace, first)
+	}
+	return p.finishBlockExpr(start, first)
+}
+
+// key is the first key if already parsed
+func (p *Parser) finishMap(brace *Token, key Expr) Expr {
+	var keys, values []Expr
+	if key != nil || !p.check(RIGHT_BRACE) {
+		for {
+			if key == nil {
+				key = p.Expression()
+			}
+			keys = append(keys, key)
+			key = nil
+			p.consume(COLON, "expect ':' after map key")
+			values = append(values, p.Expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_BRACE, "expect '}' after map entries")
+	return NewExprMapLiteral(brace, keys, values)
+}
+
+// statements are parsed as in a block, the expression not followed by `;`
+// before `}` is the value. first is the beginning of the first expression
+// statement if already parsed, start is where it begins.
+func (p *Parser) finishBlockExpr(start *Token, first Expr) Expr {
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	var stmts []Stmt
+	for {
+		if first == nil {
+			if p.check(RIGHT_BRACE) || p.isAtEnd() {
+				panic(NewParseError(p.peek(), "expect expression at end of block expression"))
+			}
+			if p.check(LEFT_BRACE) || p.checkStatementStart() {
+				stmts = append(stmts, p.Declaration())
+				continue
+			}
+			start = p.peek()
+			first = p.Expression()
+		}
+		expr := p.finishComma(start, first)
+		first = nil
+		if p.match(SEMICOLON) {
+			stmts = append(stmts, p.spanStmt(start, NewStmtExpression(expr)))
+			continue
+		}
+		if !p.check(RIGHT_BRACE) {
+			panic(NewParseError(p.peek(), "expect ';' after value"))
+		}
+		p.advance()
+		return NewExprBlock(stmts, expr)
+	}
+}
+
+// discard tokens until the start of next statement, that is after a `;`
+// or at a keyword beginning a statement. A `}` inside a block is kept, the
+// block ends there and parsing goes on after it.
+func (p *Parser) synchronize() {
+	for !p.isAtEnd() {
+		if p.check(RIGHT_BRACE) && p.blockDepth > 0 {
+			return
+		}
+		p.advance()
+		if p.previous().typ == SEMICOLON {
+			return
+		}
+		if p.checkStatementStart() {
+			return
+		}
+	}
+}
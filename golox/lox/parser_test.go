@@ -0,0 +1,262 @@
+package lox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserParse(t *testing.T) {
+	// 1 + 2 * 3 - 4
+	scanner := NewScanner()
+	tokens, _ := scanner.Scan("1 + 2 * 3 - 4;")
+	parser := NewParser()
+	stmts, err := parser.Parse(tokens)
+	expected := []Stmt{
+		atStmt(NewStmtExpression(
+			atExpr(NewExprBinary(
+				atExpr(NewExprBinary(
+					atExpr(NewExprLiteral(Number(1)), 1, 1, 2),
+					NewToken(PLUS, "+", nil, 1, 3),
+					atExpr(NewExprBinary(
+						atExpr(NewExprLiteral(Number(2)), 1, 5, 6),
+						NewToken(STAR, "*", nil, 1, 7),
+						atExpr(NewExprLiteral(Number(3)), 1, 9, 10),
+					), 1, 5, 10),
+				), 1, 1, 10),
+				NewToken(MINUS, "-", nil, 1, 11),
+				atExpr(NewExprLiteral(Number(4)), 1, 13, 14),
+			), 1, 1, 14),
+		), 1, 1, 15),
+	}
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, stmts)
+}
+
+// set span of a node on a single line
+func atExpr(expr Expr, line, startColumn, endColumn int) Expr {
+	expr.setPos(Position{line, startColumn}, Position{line, endColumn})
+	return expr
+}
+
+func atStmt(stmt Stmt, line, startColumn, endColumn int) Stmt {
+	stmt.setPos(Position{line, startColumn}, Position{line, endColumn})
+	return stmt
+}
+
+func TestParserPos(t *testing.T) {
+	tokens, _ := NewScanner().Scan(`var total = (a +
+  b) * f(c);
+func g(x) {
+  return "multi
+line";
+}`)
+	stmts, err := NewParser().Parse(tokens)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	pos := func(node interface {
+		Pos() (Position, Position)
+	}) [2]Position {
+		start, end := node.Pos()
+		return [2]Position{start, end}
+	}
+	span := func(startLine, startColumn, endLine, endColumn int) [2]Position {
+		return [2]Position{{startLine, startColumn}, {endLine, endColumn}}
+	}
+
+	decl := stmts[0].(*StmtVarDecl)
+	assert.Equal(t, span(1, 1, 2, 13), pos(decl))
+	// binary expression covers both operands
+	product := decl.value.(*ExprBinary)
+	assert.Equal(t, span(1, 13, 2, 12), pos(product))
+	assert.Equal(t, span(1, 13, 2, 5), pos(product.left))
+	assert.Equal(t, span(1, 14, 2, 4), pos(product.left.(*ExprGrouping).operand))
+	assert.Equal(t, span(2, 8, 2, 12), pos(product.right))
+	assert.Equal(t, span(2, 10, 2, 11), pos(product.right.(*ExprCall).arguments[0]))
+
+	fn := stmts[1].(*StmtFuncDecl)
+	assert.Equal(t, span(3, 1, 6, 2), pos(fn))
+	// string token spans lines
+	ret := fn.body[0].(*StmtReturn)
+	assert.Equal(t, span(4, 3, 5, 7), pos(ret))
+	assert.Equal(t, span(4, 10, 5, 6), pos(ret.value))
+}
+
+func TestParserLoopControl(t *testing.T) {
+	parse := func(source string) error {
+		tokens, _ := NewScanner().Scan(source)
+		_, err := NewParser().Parse(tokens)
+		return err
+	}
+
+	assert.EqualError(t, parse("break;"), "line 1, col 1, at 'break', can't use 'break' outside of a loop")
+	assert.EqualError(t, parse("if (true) continue;"), "line 1, col 11, at 'continue', can't use 'continue' outside of a loop")
+	assert.EqualError(
+		t,
+		parse("while (true) { func f() { break; } }"),
+		"line 1, col 27, at 'break', can't use 'break' outside of a loop",
+	)
+	assert.Nil(t, parse("while (true) { if (true) break; else continue; }"))
+	assert.Nil(t, parse("for (var i = 0;;) { { break; } }"))
+
+	// labels
+	assert.Nil(t, parse("a: while (true) { b: for (;;) { do { continue a; } while (true); break b; } }"))
+	assert.EqualError(t, parse("a: while (true) break b;"), "line 1, col 23, at 'b', no enclosing loop labeled 'b'")
+	assert.EqualError(
+		t,
+		parse("a: while (true) {}\nwhile (true) continue a;"),
+		"line 2, col 23, at 'a', no enclosing loop labeled 'a'",
+	)
+	assert.EqualError(
+		t,
+		parse("a: while (true) { func f() { while (true) break a; } }"),
+		"line 1, col 49, at 'a', no enclosing loop labeled 'a'",
+	)
+	var pe *ParseError
+	if assert.True(t, errors.As(parse("a: while (true) a: for (;;) {}"), &pe)) {
+		assert.EqualError(t, pe, "line 1, col 17, at 'a', label 'a' is already used by an enclosing loop")
+	}
+	assert.EqualError(t, parse("a: print 1;"), "line 1, col 4, at 'print', expect loop after label")
+	assert.EqualError(t, parse("while (true) break 1;"), "line 1, col 20, at '1', expect ';' after 'break'")
+
+	// a bad do while body doesn't leave the parser inside the loop
+	assert.EqualError(t, parse("do print 1 +;\nbreak;"), `line 1, col 13, at ';', expect expression
+line 2, col 1, at 'break', can't use 'break' outside of a loop`)
+}
+
+func TestParserReturn(t *testing.T) {
+	parse := func(source string) error {
+		tokens, _ := NewScanner().Scan(source)
+		_, err := NewParser().Parse(tokens)
+		return err
+	}
+
+	assert.EqualError(t, parse("return;"), "line 1, col 1, at 'return', can't return from top-level code")
+	assert.EqualError(
+		t,
+		parse("func f() {}\n{\n  return 1;\n}"),
+		"line 3, col 3, at 'return', can't return from top-level code",
+	)
+	assert.Nil(t, parse("func f() { { return 1; } }"))
+	assert.Nil(t, parse("var f = func() { while (true) return; };"))
+	assert.Nil(t, parse("class A { m() { return 1; } g { return 2; } }"))
+}
+
+func TestParserChainedComparison(t *testing.T) {
+	parse := func(source string) error {
+		tokens, _ := NewScanner().Scan(source)
+		_, err := NewParser().Parse(tokens)
+		return err
+	}
+
+	msg := "comparisons can't be chained, use 'a < b and b < c' instead"
+	assert.EqualError(t, parse("1 < 2 < 3;"), "line 1, col 7, at '<', "+msg)
+	assert.EqualError(t, parse("a >= b <= c;"), "line 1, col 8, at '<=', "+msg)
+	assert.Nil(t, parse("1 < 2 and 2 < 3;"))
+	assert.Nil(t, parse("(1 < 2) == (2 < 3);"))
+	assert.Nil(t, parse("1 < 2 == true;"))
+}
+
+func TestParserGrouping(t *testing.T) {
+	parse := func(source string) error {
+		tokens, _ := NewScanner().Scan(source)
+		_, err := NewParser().Parse(tokens)
+		return err
+	}
+
+	// grouping overrides precedence
+	printer := NewAstPrinter()
+	for source, expected := range map[string]string{
+		"(1 + 2) * 3 == 9": "(== (* (group (+ 1 2)) 3) 9)",
+		"-(1) + 2 == 1":    "(== (+ (- (group 1)) 2) 1)",
+	} {
+		tokens, _ := NewScanner().Scan(source)
+		parser := NewParser()
+		parser.reset(tokens)
+		assert.Equal(t, expected, printer.PrintExpr(parser.Expression()), source)
+		assert.Equal(t, true, evalExpr(t, source), source)
+	}
+
+	// a grouping is a value rather than a place
+	assert.EqualError(t, parse("(a) = 1;"), "line 1, col 5, at '=', invalid assignment target")
+	assert.EqualError(t, parse("(a.b) = 1;"), "line 1, col 7, at '=', invalid assignment target")
+	assert.EqualError(t, parse("(a) += 1;"), "line 1, col 5, at '+=', invalid assignment target")
+	assert.EqualError(t, parse("(a)++;"), "line 1, col 4, at '++', invalid ++ target")
+	assert.Nil(t, parse("a = (b = 1);"))
+}
+
+func TestParserAssignmentTarget(t *testing.T) {
+	parse := func(source string) error {
+		tokens, _ := NewScanner().Scan(source)
+		_, err := NewParser().Parse(tokens)
+		return err
+	}
+
+	printer := NewAstPrinter()
+	for source, expected := range map[string]string{
+		"a = 1":        "(assign a 1)",
+		"a.b = 1":      "(set b a 1)",
+		"a[0] = 1":     "(index-set a 0 1)",
+		"a.b[0].c = 1": "(set c (index (get b a) 0) 1)",
+		"a = b[0] = 1": "(assign a (index-set b 0 1))",
+		"f().b[1] = 2": "(index-set (get b (f)) 1 2)",
+	} {
+		tokens, _ := NewScanner().Scan(source)
+		parser := NewParser()
+		parser.reset(tokens)
+		assert.Equal(t, expected, printer.PrintExpr(parser.Expression()), source)
+	}
+
+	for source, msg := range map[string]string{
+		"1 = 2;":         "line 1, col 3, at '=', invalid assignment target",
+		"a + b = 3;":     "line 1, col 7, at '=', invalid assignment target",
+		"(x) = 4;":       "line 1, col 5, at '=', invalid assignment target",
+		"a +\n  b = 3;":  "line 2, col 5, at '=', invalid assignment target",
+		"f() = 1;":       "line 1, col 5, at '=', invalid assignment target",
+		"a?.b = 1;":      "line 1, col 6, at '=', invalid assignment target",
+		`"s" = 1;`:       "line 1, col 5, at '=', invalid assignment target",
+		"a ? b : c = 1;": "line 1, col 11, at '=', invalid assignment target",
+		"-a = 1;":        "line 1, col 4, at '=', invalid assignment target",
+	} {
+		assert.EqualError(t, parse(source), msg, source)
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tokens, _ := NewScanner().Scan(`
+var a = ;
+print a;
+if (a) {
+  print a
+}
+class B < {}
+func ok() { return 1; }
+`)
+	program, err := NewParser().Parse(tokens)
+	assert.Nil(t, program)
+	assert.EqualError(t, err, `line 2, col 9, at ';', expect expression
+line 6, col 1, at '}', expect ';' after value
+line 7, col 11, at '{', expect superclass name`)
+
+	// every error is reported with its phase, the first one is found by
+	// errors.As
+	err = New().Run("print 1 +;\nvar 2;\nwhile (true) break")
+	assert.EqualError(t, err, `parse error: line 1, col 10, at ';', expect expression
+parse error: line 2, col 5, at '2', expect variable name
+parse error: line 3, col 19, at end, expect ';' after 'break'`)
+	var pe *ParseError
+	if assert.True(t, errors.As(err, &pe)) {
+		line, column := pe.Position()
+		assert.Equal(t, []int{1, 10}, []int{line, column})
+	}
+
+	// errors in a function body don't hide the rest of the file
+	tokens, _ = NewScanner().Scan("func f() {\n  return +;\n  var b = 1;\n}\nprint );")
+	_, err = NewParser().Parse(tokens)
+	assert.EqualError(t, err, `line 2, col 10, at '+', expect expression
+line 5, col 7, at ')', expect expression`)
+}
@@ -0,0 +1,624 @@
+package lox
+
+import "sort"
+
+// Resolver computes the scope distance of every local variable before
+// execution, so a variable always refers to the same declaration no matter
+// how envs change at runtime. Global variables are left unresolved.
+type Resolver struct {
+	// innermost scope is the last one
+	scopes []*scope
+
+	// report locals that are never read when enabled
+	warnUnused bool
+	// locals not read yet in each scope, parallel to scopes
+	unused []map[string]*Token
+	// report expression statements without effects when enabled
+	warnDiscarded bool
+	warnings      []string
+
+	// whether a return here can be a tail call, which is true inside a
+	// function body but not inside `try`, where the call must finish before
+	// catch and finally
+	tailCalls bool
+	// index of the scope of the innermost function body in scopes
+	functionScope int
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// variables declared in a local scope
+type scope struct {
+	// whether the variable has been defined
+	defined map[string]bool
+	// slot of each variable, in declaration order
+	slots map[string]int
+	// line each variable is declared at
+	lines map[string]int
+}
+
+func newScope() *scope {
+	return &scope{map[string]bool{}, map[string]int{}, map[string]int{}}
+}
+
+// a name is declared at most once in a scope
+func (s *scope) declare(name string, line int) int {
+	slot := len(s.slots)
+	s.slots[name] = slot
+	s.lines[name] = line
+	s.defined[name] = false
+	return slot
+}
+
+func (s *scope) define(name string) {
+	s.defined[name] = true
+}
+
+// static errors are reported as `ParseErrors`, resolving goes on from the
+// next top-level statement after an error
+func (r *Resolver) Resolve(stmts []Stmt) error {
+	r.warnings = nil
+	var errs ParseErrors
+	for _, stmt := range stmts {
+		if pe := r.resolveTopLevel(stmt); pe != nil {
+			errs = append(errs, pe)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// a top-level statement starts without scopes, so nothing is left to
+// restore after an error
+func (r *Resolver) resolveTopLevel(stmt Stmt) (pe *ParseError) {
+	r.scopes = nil
+	r.unused = nil
+	r.tailCalls = false
+	defer func() {
+		if e := recover(); e != nil {
+			var ok bool
+			if pe, ok = e.(*ParseError); !ok {
+				panic(e)
+			}
+		}
+	}()
+	stmt.Resolve(r)
+	return nil
+}
+
+/*----------  Stmt: Print  ----------*/
+
+func (s *StmtPrint) Resolve(r *Resolver) {
+	s.expr.Resolve(r)
+}
+
+/*----------  Stmt: Assert  ----------*/
+
+func (s *StmtAssert) Resolve(r *Resolver) {
+	s.condition.Resolve(r)
+	if s.message != nil {
+		s.message.Resolve(r)
+	}
+}
+
+/*----------  Stmt: Import  ----------*/
+
+// imported file is resolved when it is run
+func (s *StmtImport) Resolve(r *Resolver) {}
+
+/*----------  Stmt: Try  ----------*/
+
+// the error variable and catch body share the same scope
+func (s *StmtTry) Resolve(r *Resolver) {
+	tailCalls := r.tailCalls
+	r.tailCalls = false
+	defer func() { r.tailCalls = tailCalls }()
+
+	r.beginScope()
+	r.resolveStmts(s.tryBlock)
+	r.endScope()
+
+	if s.name != nil {
+		// the error variable takes the first slot
+		r.beginScope()
+		r.declare(s.name)
+		r.define(s.name)
+		r.resolveStmts(s.catchBlock)
+		r.endScope()
+	}
+
+	if s.finallyBlock != nil {
+		r.beginScope()
+		r.resolveStmts(s.finallyBlock)
+		r.endScope()
+	}
+}
+
+/*----------  Stmt: Throw  ----------*/
+
+func (s *StmtThrow) Resolve(r *Resolver) {
+	s.value.Resolve(r)
+}
+
+/*----------  Stmt: Defer  ----------*/
+
+func (s *StmtDefer) Resolve(r *Resolver) {
+	s.call.Resolve(r)
+	s.depth = len(r.scopes) - 1 - r.functionScope
+}
+
+/*----------  Stmt: Expression  ----------*/
+
+// e.g. `a + b;` computes a value only to throw it away
+func (s *StmtExpression) Resolve(r *Resolver) {
+	s.expr.Resolve(r)
+	if r.warnDiscarded && isPure(s.expr) {
+		start, _ := s.expr.Pos()
+		r.warnings = append(r.warnings, sprintf("line %d, col %d, expression has no effect", start.Line, start.Column))
+	}
+}
+
+/*----------  Stmt: Variable Declaration  ----------*/
+
+func (s *StmtVarDecl) Resolve(r *Resolver) {
+	s.slot = r.declare(s.name)
+	if s.value != nil {
+		s.value.Resolve(r)
+	}
+	r.define(s.name)
+	r.trackUsage(s.name)
+}
+
+/*----------  Stmt: Variable Unpacking  ----------*/
+
+func (s *StmtVarUnpack) Resolve(r *Resolver) {
+	for i, name := range s.names {
+		s.slots[i] = r.declare(name)
+	}
+	s.value.Resolve(r)
+	for _, name := range s.names {
+		r.define(name)
+		r.trackUsage(name)
+	}
+}
+
+/*----------  Stmt: Constant Declaration  ----------*/
+
+func (s *StmtConstDecl) Resolve(r *Resolver) {
+	s.slot = r.declare(s.name)
+	s.value.Resolve(r)
+	r.define(s.name)
+	r.trackUsage(s.name)
+}
+
+/*----------  Stmt: Block  ----------*/
+
+func (s *StmtBlock) Resolve(r *Resolver) {
+	r.beginScope()
+	r.resolveStmts(s.stmts)
+	r.endScope()
+}
+
+/*----------  Stmt: If  ----------*/
+
+func (s *StmtIf) Resolve(r *Resolver) {
+	s.condition.Resolve(r)
+	s.trueBranch.Resolve(r)
+	if s.falseBranch != nil {
+		s.falseBranch.Resolve(r)
+	}
+}
+
+/*----------  Stmt: While  ----------*/
+
+func (s *StmtWhile) Resolve(r *Resolver) {
+	s.condition.Resolve(r)
+	s.body.Resolve(r)
+}
+
+/*----------  Stmt: Do While  ----------*/
+
+func (s *StmtDoWhile) Resolve(r *Resolver) {
+	s.body.Resolve(r)
+	s.condition.Resolve(r)
+}
+
+/*----------  Stmt: For  ----------*/
+
+// initializer has its own scope, the same as `StmtFor.Run`
+func (s *StmtFor) Resolve(r *Resolver) {
+	r.beginScope()
+	if s.initializer != nil {
+		s.initializer.Resolve(r)
+	}
+	if s.condition != nil {
+		s.condition.Resolve(r)
+	}
+	if s.increment != nil {
+		s.increment.Resolve(r)
+	}
+	s.body.Resolve(r)
+	r.endScope()
+}
+
+/*----------  Stmt: For Each  ----------*/
+
+// loop variable takes the first slot of its own scope, the same as
+// `StmtForEach.Run`
+func (s *StmtForEach) Resolve(r *Resolver) {
+	s.iterable.Resolve(r)
+	r.beginScope()
+	r.declare(s.name)
+	r.define(s.name)
+	s.body.Resolve(r)
+	r.endScope()
+}
+
+/*----------  Stmt: Break  ----------*/
+
+func (s *StmtBreak) Resolve(r *Resolver) {}
+
+/*----------  Stmt: Continue  ----------*/
+
+func (s *StmtContinue) Resolve(r *Resolver) {}
+
+/*----------  Stmt: Function Declaration  ----------*/
+
+func (s *StmtFuncDecl) Resolve(r *Resolver) {
+	// define eagerly so function can refer to itself recursively
+	s.slot = r.declare(s.name)
+	r.define(s.name)
+	r.resolveFunction(s)
+}
+
+/*----------  Stmt: Class Declaration  ----------*/
+
+func (s *StmtClassDecl) Resolve(r *Resolver) {
+	s.slot = r.declare(s.name)
+	r.define(s.name)
+
+	if s.superclass != nil {
+		s.superclass.Resolve(r)
+		r.beginScope()
+		r.peekScope().declare("super", s.name.line)
+		r.peekScope().define("super")
+	}
+
+	// methods are bound in an env defining `this`
+	r.beginScope()
+	r.peekScope().declare("this", s.name.line)
+	r.peekScope().define("this")
+	for _, method := range s.methods {
+		r.resolveFunction(method)
+	}
+	r.endScope()
+
+	if s.superclass != nil {
+		r.endScope()
+	}
+
+	// class methods see neither `this` nor `super`
+	for _, method := range s.classMethods {
+		r.resolveFunction(method)
+	}
+}
+
+/*----------  Stmt: Return  ----------*/
+
+func (s *StmtReturn) Resolve(r *Resolver) {
+	if s.value != nil {
+		s.value.Resolve(r)
+		_, isCall := s.value.(*ExprCall)
+		s.tail = isCall && r.tailCalls
+	}
+}
+
+/*----------  Expr: Assignment  ----------*/
+
+func (expr *ExprAssignment) Resolve(r *Resolver) {
+	expr.val.Resolve(r)
+	expr.depth, expr.slot = r.resolveLocal(expr.name)
+}
+
+/*----------  Expr: Literal  ----------*/
+
+func (expr *ExprLiteral) Resolve(r *Resolver) {}
+
+/*----------  Expr: Unary  ----------*/
+
+func (expr *ExprUnary) Resolve(r *Resolver) {
+	expr.operand.Resolve(r)
+}
+
+/*----------  Expr: Comma  ----------*/
+
+func (expr *ExprComma) Resolve(r *Resolver) {
+	for _, e := range expr.exprs {
+		e.Resolve(r)
+	}
+}
+
+/*----------  Expr: Update  ----------*/
+
+func (expr *ExprUpdate) Resolve(r *Resolver) {
+	expr.target.Resolve(r)
+}
+
+/*----------  Expr: Binary  ----------*/
+
+func (expr *ExprBinary) Resolve(r *Resolver) {
+	expr.left.Resolve(r)
+	expr.right.Resolve(r)
+}
+
+/*----------  Expr: Grouping  ----------*/
+
+func (expr *ExprGrouping) Resolve(r *Resolver) {
+	expr.operand.Resolve(r)
+}
+
+/*----------  Expr: Variable  ----------*/
+
+func (expr *ExprVariable) Resolve(r *Resolver) {
+	if len(r.scopes) > 0 {
+		if defined, ok := r.peekScope().defined[expr.name.lexeme]; ok && !defined {
+			panic(NewParseError(expr.name, "can't read local variable in its own initializer"))
+		}
+	}
+	expr.depth, expr.slot = r.resolveLocal(expr.name)
+	if expr.depth >= 0 {
+		delete(r.unused[len(r.unused)-1-expr.depth], expr.name.lexeme)
+	}
+}
+
+/*----------  Expr: Logical  ----------*/
+
+func (expr *ExprLogical) Resolve(r *Resolver) {
+	expr.left.Resolve(r)
+	expr.right.Resolve(r)
+}
+
+/*----------  Expr: Ternary  ----------*/
+
+func (expr *ExprTernary) Resolve(r *Resolver) {
+	expr.condition.Resolve(r)
+	expr.thenBranch.Resolve(r)
+	expr.elseBranch.Resolve(r)
+}
+
+/*----------  Expr: Function Call  ----------*/
+
+func (expr *ExprCall) Resolve(r *Resolver) {
+	expr.callee.Resolve(r)
+	for _, arg := range expr.arguments {
+		arg.Resolve(r)
+	}
+}
+
+/*----------  Expr: Property Get  ----------*/
+
+func (expr *ExprGet) Resolve(r *Resolver) {
+	expr.object.Resolve(r)
+}
+
+/*----------  Expr: Property Set  ----------*/
+
+func (expr *ExprSet) Resolve(r *Resolver) {
+	expr.val.Resolve(r)
+	expr.object.Resolve(r)
+}
+
+/*----------  Expr: This  ----------*/
+
+func (expr *ExprThis) Resolve(r *Resolver) {
+	expr.depth, _ = r.resolveLocal(expr.keyword)
+	if expr.depth < 0 {
+		panic(NewParseError(expr.keyword, "can't use 'this' outside of a method"))
+	}
+}
+
+/*----------  Expr: Super  ----------*/
+
+func (expr *ExprSuper) Resolve(r *Resolver) {
+	expr.depth, _ = r.resolveLocal(expr.keyword)
+	if expr.depth < 0 {
+		panic(NewParseError(expr.keyword, "can't use 'super' outside of a subclass method"))
+	}
+}
+
+/*----------  Expr: List Literal  ----------*/
+
+func (expr *ExprListLiteral) Resolve(r *Resolver) {
+	for _, element := range expr.elements {
+		element.Resolve(r)
+	}
+}
+
+/*----------  Expr: Interpolation  ----------*/
+
+func (expr *ExprInterpolation) Resolve(r *Resolver) {
+	for _, part := range expr.parts {
+		part.Resolve(r)
+	}
+}
+
+/*----------  Expr: Tuple  ----------*/
+
+func (expr *ExprTuple) Resolve(r *Resolver) {
+	for _, element := range expr.elements {
+		element.Resolve(r)
+	}
+}
+
+/*----------  Expr: Map Literal  ----------*/
+
+func (expr *ExprMapLiteral) Resolve(r *Resolver) {
+	for i := range expr.keys {
+		expr.keys[i].Resolve(r)
+		expr.values[i].Resolve(r)
+	}
+}
+
+/*----------  Expr: Index Get  ----------*/
+
+func (expr *ExprIndexGet) Resolve(r *Resolver) {
+	expr.object.Resolve(r)
+	expr.index.Resolve(r)
+}
+
+/*----------  Expr: Index Set  ----------*/
+
+func (expr *ExprIndexSet) Resolve(r *Resolver) {
+	expr.object.Resolve(r)
+	expr.index.Resolve(r)
+	expr.val.Resolve(r)
+}
+
+/*----------  Expr: Block  ----------*/
+
+func (expr *ExprBlock) Resolve(r *Resolver) {
+	r.beginScope()
+	r.resolveStmts(expr.stmts)
+	expr.value.Resolve(r)
+	r.endScope()
+}
+
+/*----------  Expr: Function  ----------*/
+
+// name of a named function expression lives in a scope of its own
+// enclosing the function, it isn't reported if unused
+func (expr *ExprFunction) Resolve(r *Resolver) {
+	name := expr.decl.name
+	if name == nil {
+		r.resolveFunction(expr.decl)
+		return
+	}
+	r.beginScope()
+	r.peekScope().declare(name.lexeme, name.line)
+	r.peekScope().define(name.lexeme)
+	r.resolveFunction(expr.decl)
+	r.endScope()
+}
+
+/*----------  Private Methods  ----------*/
+
+func (r *Resolver) resolveStmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		stmt.Resolve(r)
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, newScope())
+	r.unused = append(r.unused, map[string]*Token{})
+}
+
+func (r *Resolver) endScope() {
+	if r.warnUnused {
+		var names []*Token
+		for _, name := range r.unused[len(r.unused)-1] {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if names[i].line != names[j].line {
+				return names[i].line < names[j].line
+			}
+			return names[i].column < names[j].column
+		})
+		for _, name := range names {
+			r.warnings = append(r.warnings, sprintf("line %d, col %d, local variable '%s' is never used", name.line, name.column, name.lexeme))
+		}
+	}
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.unused = r.unused[:len(r.unused)-1]
+}
+
+// the variable is reported at the end of scope if it's never read,
+// globals are not tracked
+func (r *Resolver) trackUsage(name *Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.unused[len(r.unused)-1][name.lexeme] = name
+}
+
+func (r *Resolver) peekScope() *scope {
+	return r.scopes[len(r.scopes)-1]
+}
+
+// globals are not tracked and can be redeclared, a local can't be
+// declared again in the same scope. Return the slot of the variable, -1 if
+// it's a global.
+func (r *Resolver) declare(name *Token) int {
+	if len(r.scopes) == 0 {
+		return -1
+	}
+	scope := r.peekScope()
+	if line, ok := scope.lines[name.lexeme]; ok {
+		panic(NewParseError(name, sprintf("'%s' is already declared in this scope at line %d", name.lexeme, line)))
+	}
+	return scope.declare(name.lexeme, name.line)
+}
+
+func (r *Resolver) define(name *Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.peekScope().define(name.lexeme)
+}
+
+// return scope distance and slot of the variable, -1 if it's a global
+func (r *Resolver) resolveLocal(name *Token) (depth, slot int) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if slot, ok := r.scopes[i].slots[name.lexeme]; ok {
+			return len(r.scopes) - 1 - i, slot
+		}
+	}
+	return -1, -1
+}
+
+// parameters and body share the same scope, the same as `StmtFuncDecl.Call`,
+// parameters take the first slots in order
+func (r *Resolver) resolveFunction(fn *StmtFuncDecl) {
+	tailCalls, functionScope := r.tailCalls, r.functionScope
+	r.tailCalls = true
+	defer func() { r.tailCalls, r.functionScope = tailCalls, functionScope }()
+
+	r.beginScope()
+	r.functionScope = len(r.scopes) - 1
+	for i, param := range fn.parameters {
+		// default value can refer to earlier parameters
+		if fn.defaults[i] != nil {
+			fn.defaults[i].Resolve(r)
+		}
+		if line, ok := r.peekScope().lines[param.lexeme]; ok {
+			panic(NewParseError(param, sprintf("duplicate parameter '%s', first declared at line %d", param.lexeme, line)))
+		}
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolveStmts(fn.body)
+	r.endScope()
+}
+
+// expr computes a value from literals and variables only, with no call or
+// assignment on the way
+func isPure(expr Expr) bool {
+	switch expr := expr.(type) {
+	case *ExprLiteral, *ExprVariable:
+		return true
+	case *ExprGrouping:
+		return isPure(expr.operand)
+	case *ExprUnary:
+		return isPure(expr.operand)
+	case *ExprBinary:
+		return isPure(expr.left) && isPure(expr.right)
+	case *ExprLogical:
+		return isPure(expr.left) && isPure(expr.right)
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolverClosureCapture(t *testing.T) {
+	lox := evalSource(t, `
+var a = "global";
+var first;
+var second;
+{
+  func showA() {
+    return a;
+  }
+
+  first = showA();
+  var a = "block";
+  second = showA();
+}
+`)
+	assert.Equal(t, "global", getGlobal(lox, "first"))
+	assert.Equal(t, "global", getGlobal(lox, "second"))
+}
+
+func TestResolverDepth(t *testing.T) {
+	tokens, _ := NewScanner().Scan(`
+var a;
+{
+  var b;
+  {
+    a = b;
+  }
+}
+`)
+	program, err := NewParser().Parse(tokens)
+	assert.Nil(t, err)
+	assert.Nil(t, NewResolver().Resolve(program))
+
+	outer := program[1].(*StmtBlock)
+	inner := outer.stmts[1].(*StmtBlock)
+	assignment := inner.stmts[0].(*StmtExpression).expr.(*ExprAssignment)
+	assert.Equal(t, -1, assignment.depth)
+	assert.Equal(t, 1, assignment.val.(*ExprVariable).depth)
+}
+
+func TestResolverSlots(t *testing.T) {
+	tokens, _ := NewScanner().Scan(`
+var g;
+func f(a, b) {
+  var c = a;
+  var d = b;
+  return d;
+}
+`)
+	program, err := NewParser().Parse(tokens)
+	assert.Nil(t, err)
+	assert.Nil(t, NewResolver().Resolve(program))
+
+	assert.Equal(t, -1, program[0].(*StmtVarDecl).slot)
+	fn := program[1].(*StmtFuncDecl)
+	assert.Equal(t, -1, fn.slot)
+	c := fn.body[0].(*StmtVarDecl)
+	assert.Equal(t, 2, c.slot)
+	assert.Equal(t, 0, c.value.(*ExprVariable).slot)
+	d := fn.body[1].(*StmtVarDecl)
+	assert.Equal(t, 3, d.slot)
+	assert.Equal(t, 1, d.value.(*ExprVariable).slot)
+	assert.Equal(t, 3, fn.body[2].(*StmtReturn).value.(*ExprVariable).slot)
+}
+
+func TestResolverOwnInitializer(t *testing.T) {
+	err := New().Run(`
+var a = 1;
+{
+  var a = a;
+}
+`)
+	assert.EqualError(t, err, "resolve error: line 4, col 11, at 'a', can't read local variable in its own initializer")
+
+	lox := evalSource(t, `
+var a = 1;
+var a = a + 1;
+`)
+	assert.Equal(t, Number(2), getGlobal(lox, "a"))
+}
+
+func TestResolverDuplicates(t *testing.T) {
+	errors := map[string]string{
+		"func f(a, b,\n  a) {}":              "resolve error: line 2, col 3, at 'a', duplicate parameter 'a', first declared at line 1",
+		"var f = func(x, x = 1) {};":         "resolve error: line 1, col 17, at 'x', duplicate parameter 'x', first declared at line 1",
+		"{\n  var a = 1;\n  var a = 2;\n}":   "resolve error: line 3, col 7, at 'a', 'a' is already declared in this scope at line 2",
+		"func f(a) {\n  var a;\n}":           "resolve error: line 2, col 7, at 'a', 'a' is already declared in this scope at line 1",
+		"{\n  var a;\n  func a() {}\n}":      "resolve error: line 3, col 8, at 'a', 'a' is already declared in this scope at line 2",
+		"{\n  const a = 1;\n  class a {}\n}": "resolve error: line 3, col 9, at 'a', 'a' is already declared in this scope at line 2",
+		"try {} catch (e) { var e; }":        "resolve error: line 1, col 24, at 'e', 'e' is already declared in this scope at line 1",
+	}
+	for source, expected := range errors {
+		assert.EqualError(t, New().Run(source), expected, source)
+	}
+
+	// shadowing in nested scopes and redefining globals are allowed
+	lox := evalSource(t, `
+var a = "global";
+var a = "redefined";
+var seen = [];
+{
+  var a = "outer";
+  {
+    var a = "inner";
+    push(seen, a);
+  }
+  push(seen, a);
+}
+func f(a) {
+  { var a = "shadow"; push(seen, a); }
+  return a;
+}
+push(seen, f("param"));
+for (var i = 0; i < 1; i++) { var i = "shadowed"; push(seen, i); }
+`)
+	assert.Equal(t, "[inner, outer, shadow, param, shadowed]", stringify(getGlobal(lox, "seen")))
+	assert.Equal(t, "redefined", getGlobal(lox, "a"))
+}
+
+func TestResolverWarnUnused(t *testing.T) {
+	source := `
+var global = 1;
+{
+  var used = 1;
+  var unused = 2;
+  const alsoUnused = 3;
+  var assigned;
+  assigned = used;
+}
+func f(param) {
+  var counter = 0;
+  counter++;
+  var shadowed = 1;
+  {
+    var shadowed = 2;
+    print shadowed;
+  }
+}
+`
+	lox := New(WarnUnused(true))
+	assert.Nil(t, lox.Run(source))
+	assert.Equal(t, []string{
+		"line 5, col 7, local variable 'unused' is never used",
+		"line 6, col 9, local variable 'alsoUnused' is never used",
+		"line 7, col 7, local variable 'assigned' is never used",
+		"line 13, col 7, local variable 'shadowed' is never used",
+	}, lox.Warnings())
+
+	// disabled by default
+	lox = New()
+	assert.Nil(t, lox.Run(source))
+	assert.Empty(t, lox.Warnings())
+}
+
+func TestResolverWarnDiscarded(t *testing.T) {
+	source := `
+var a = 1;
+var b = 2;
+func f() { return a; }
+a + b;
+a == b;
+-a;
+(a);
+"literal";
+f();
+a = b;
+a++;
+f() + 1;
+a and f();
+{
+  a * 2 ** b;
+}
+func g() {
+  b;
+}
+`
+	lox := New(WarnDiscarded(true))
+	assert.Nil(t, lox.Run(source))
+	assert.Equal(t, []string{
+		"line 5, col 1, expression has no effect",
+		"line 6, col 1, expression has no effect",
+		"line 7, col 1, expression has no effect",
+		"line 8, col 1, expression has no effect",
+		"line 9, col 1, expression has no effect",
+		"line 16, col 3, expression has no effect",
+		"line 19, col 3, expression has no effect",
+	}, lox.Warnings())
+
+	// disabled by default
+	lox = New()
+	assert.Nil(t, lox.Run(source))
+	assert.Empty(t, lox.Warnings())
+}
@@ -0,0 +1,508 @@
+package lox
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+type ScanError struct {
+	line, column int
+	msg          string
+}
+
+func NewScanError(line, column int, msg string) *ScanError {
+	return &ScanError{line, column, msg}
+}
+
+func (se *ScanError) Error() string {
+	return fmt.Sprintf("line %d, col %d, %s", se.line, se.column, se.msg)
+}
+
+func (se *ScanError) Position() (line, column int) {
+	return se.line, se.column
+}
+
+func (se *ScanError) message() string {
+	return se.msg
+}
+
+type Scanner struct {
+	source    []rune
+	start     int
+	next      int
+	line      int
+	lineStart int // index of the first character of current line
+
+	// position of the token being scanned
+	startLine   int
+	startColumn int
+
+	// lexemes scanned so far, kept across scans, so repeated identifiers
+	// share the same string
+	interned map[string]string
+	buf      []byte
+
+	// interpolations being scanned, innermost last
+	interpolations []*interpolation
+}
+
+// an embedded expression of an interpolated string, `}` closing it
+// resumes the string
+type interpolation struct {
+	braces       int // `{` opened inside the expression and not closed yet
+	line, column int // where the string starts
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{
+		line:     1,
+		interned: map[string]string{},
+	}
+}
+
+func (s *Scanner) Scan(source string) ([]*Token, error) {
+	s.reset(source)
+	var tokens []*Token
+	for !s.isAtEnd() {
+		s.start = s.next
+		s.startLine = s.line
+		s.startColumn = s.column()
+		token, err := s.scanToken()
+		if err != nil {
+			return nil, NewScanError(s.startLine, s.startColumn, err.Error())
+		}
+		if token != nil {
+			tokens = append(tokens, token)
+		}
+	}
+
+	if n := len(s.interpolations); n > 0 {
+		last := s.interpolations[n-1]
+		return nil, NewScanError(last.line, last.column, "unterminated string interpolation")
+	}
+
+	tokens = append(
+		tokens,
+		// can't use `s.newToken`, it would use last character as lexeme
+		NewToken(EOF, "", nil, s.line, s.column()),
+	)
+
+	return tokens, nil
+}
+
+/*----------  Private Methods  ----------*/
+func (s *Scanner) reset(source string) {
+	s.source = []rune(source)
+	s.start = 0
+	s.next = 0
+	s.line = 1
+	s.lineStart = 0
+	s.interpolations = s.interpolations[:0]
+}
+
+// column of the next character
+func (s *Scanner) column() int {
+	return s.next - s.lineStart + 1
+}
+
+// must be called after consuming a '\n'
+func (s *Scanner) newLine() {
+	s.line++
+	s.lineStart = s.next
+}
+
+func (s *Scanner) isAtEnd() bool {
+	return s.next == len(s.source)
+}
+
+func (s *Scanner) advance() rune {
+	s.next++
+	return s.source[s.next-1]
+}
+
+// the lookup doesn't allocate, only a new lexeme does
+func (s *Scanner) currentStr() string {
+	s.buf = s.buf[:0]
+	for _, c := range s.source[s.start:s.next] {
+		s.buf = utf8.AppendRune(s.buf, c)
+	}
+	if str, ok := s.interned[string(s.buf)]; ok {
+		return str
+	}
+	str := string(s.buf)
+	s.interned[str] = str
+	return str
+}
+
+func (s *Scanner) peek() rune {
+	return s.peekN(1)
+}
+
+// return 0x00 if index out of bound
+func (s *Scanner) peekN(n int) rune {
+	i := s.next + n - 1
+	if i >= len(s.source) {
+		return '\x00'
+	}
+	return s.source[i]
+}
+
+func (s *Scanner) newToken(typ TokenType, literal interface{}) *Token {
+	return NewToken(
+		typ,
+		s.currentStr(),
+		literal,
+		s.startLine,
+		s.startColumn,
+	)
+}
+
+// escape sequences are decoded in the literal, lexeme is kept verbatim,
+// `${` ends the token as INTERPOLATION, scanning resumes here after the
+// embedded expression
+func (s *Scanner) scanString() (*Token, error) {
+	var value []rune
+	for s.peek() != '"' && !s.isAtEnd() {
+		c := s.advance()
+		switch c {
+		case '\n':
+			s.newLine()
+		case '$':
+			if s.peek() == '{' {
+				s.advance()
+				s.interpolations = append(s.interpolations, &interpolation{
+					line:   s.startLine,
+					column: s.startColumn,
+				})
+				return s.newToken(INTERPOLATION, string(value)), nil
+			}
+		case '\\':
+			r, err := s.scanEscape()
+			if err != nil {
+				return nil, err
+			}
+			c = r
+		}
+		value = append(value, c)
+	}
+
+	if s.isAtEnd() {
+		return nil, fmt.Errorf("unterminated string")
+	}
+
+	// swallow the closing "
+	s.advance()
+
+	return s.newToken(STRING, string(value)), nil
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// the leading `\` has been consumed
+func (s *Scanner) scanEscape() (rune, error) {
+	if s.isAtEnd() {
+		return 0, fmt.Errorf("unterminated string")
+	}
+	c := s.advance()
+	if r, ok := escapes[c]; ok {
+		return r, nil
+	}
+	if c != 'u' {
+		return 0, fmt.Errorf("unknown escape sequence '\\%c'", c)
+	}
+
+	// \uXXXX
+	var digits []rune
+	for i := 0; i < 4; i++ {
+		if !isHexDigit(s.peek()) {
+			return 0, fmt.Errorf("invalid unicode escape, expect 4 hex digits")
+		}
+		digits = append(digits, s.advance())
+	}
+	n, _ := strconv.ParseInt(string(digits), 16, 32)
+	return rune(n), nil
+}
+
+// the first digit has been consumed, `_` may separate digits, `0x` and
+// `0b` start hexadecimal and binary literals, decimal ones may have an
+// exponent like `2.5e-4`
+func (s *Scanner) scanNumber() (*Token, error) {
+	if s.source[s.start] == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			return s.scanInteger(16, "hex", isHexDigit)
+		case 'b', 'B':
+			return s.scanInteger(2, "binary", isBinaryDigit)
+		}
+	}
+
+	if err := s.scanDigits(isDigit); err != nil {
+		return nil, err
+	}
+
+	if s.peek() == '.' && isDigit(s.peekN(2)) {
+		s.advance()
+		if err := s.scanDigits(isDigit); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.peek() == 'e' || s.peek() == 'E' {
+		s.advance()
+		if s.peek() == '+' || s.peek() == '-' {
+			s.advance()
+		}
+		if !isDigit(s.peek()) {
+			return nil, fmt.Errorf("expect digits in exponent")
+		}
+		if err := s.scanDigits(isDigit); err != nil {
+			return nil, err
+		}
+	}
+
+	n, e := strconv.ParseFloat(strings.Replace(s.currentStr(), "_", "", -1), 64)
+
+	if e != nil {
+		return nil, errors.Wrap(e, "can't parse number")
+	}
+
+	return s.newToken(NUMBER, Number(n)), nil
+}
+
+// the leading 0 has been consumed, the base letter is next
+func (s *Scanner) scanInteger(base int, name string, isDigit func(rune) bool) (*Token, error) {
+	prefix := string([]rune{'0', s.advance()})
+	if !isDigit(s.peek()) {
+		return nil, fmt.Errorf("expect %s digits after '%s'", name, prefix)
+	}
+	if err := s.scanDigits(isDigit); err != nil {
+		return nil, err
+	}
+	if isAlphaNumeric(s.peek()) {
+		return nil, fmt.Errorf("invalid digit '%c' in %s literal", s.peek(), name)
+	}
+
+	digits := strings.Replace(s.currentStr()[2:], "_", "", -1)
+	i, _ := new(big.Int).SetString(digits, base)
+	n, _ := new(big.Float).SetInt(i).Float64()
+	return s.newToken(NUMBER, Number(n)), nil
+}
+
+// consume a run of digits, each `_` must be followed by a digit
+func (s *Scanner) scanDigits(isDigit func(rune) bool) error {
+	for isDigit(s.peek()) || s.peek() == '_' {
+		if s.advance() == '_' && !isDigit(s.peek()) {
+			return fmt.Errorf("'_' must separate digits")
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) scanIdentifier() *Token {
+	for isAlphaNumeric(s.peek()) {
+		s.advance()
+	}
+	typ, ok := KeywordToken[s.currentStr()]
+	if !ok {
+		typ = IDENTIFIER
+	}
+	return s.newToken(typ, nil)
+}
+
+// support nested block comment, the leading `/*` has been consumed
+func (s *Scanner) scanBlockComment() error {
+	depth := 1
+	for !s.isAtEnd() {
+		switch {
+		case s.peek() == '/' && s.peekN(2) == '*':
+			s.advance()
+			s.advance()
+			depth++
+		case s.peek() == '*' && s.peekN(2) == '/':
+			s.advance()
+			s.advance()
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		default:
+			if s.advance() == '\n' {
+				s.newLine()
+			}
+		}
+	}
+	return fmt.Errorf("unterminated block comment")
+}
+
+func (s *Scanner) scanToken() (*Token, error) {
+	var token *Token
+
+	c := s.advance()
+
+	switch c {
+	case '(':
+		token = s.newToken(LEFT_PAREN, nil)
+	case ')':
+		token = s.newToken(RIGHT_PAREN, nil)
+	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1].braces++
+		}
+		token = s.newToken(LEFT_BRACE, nil)
+	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1].braces == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				return s.scanString()
+			}
+			s.interpolations[n-1].braces--
+		}
+		token = s.newToken(RIGHT_BRACE, nil)
+	case '[':
+		token = s.newToken(LEFT_BRACKET, nil)
+	case ']':
+		token = s.newToken(RIGHT_BRACKET, nil)
+	case ',':
+		token = s.newToken(COMMA, nil)
+	case '?':
+		if s.peek() == '?' {
+			s.advance()
+			token = s.newToken(QUESTION_QUESTION, nil)
+		} else if s.peek() == '.' {
+			s.advance()
+			token = s.newToken(QUESTION_DOT, nil)
+		} else {
+			token = s.newToken(QUESTION, nil)
+		}
+	case ':':
+		token = s.newToken(COLON, nil)
+	case '-':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(MINUS_EQUAL, nil)
+		} else if s.peek() == '-' {
+			s.advance()
+			token = s.newToken(MINUS_MINUS, nil)
+		} else {
+			token = s.newToken(MINUS, nil)
+		}
+	case '+':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(PLUS_EQUAL, nil)
+		} else if s.peek() == '+' {
+			s.advance()
+			token = s.newToken(PLUS_PLUS, nil)
+		} else {
+			token = s.newToken(PLUS, nil)
+		}
+	case '%':
+		token = s.newToken(PERCENT, nil)
+	case '&':
+		token = s.newToken(AMPERSAND, nil)
+	case '|':
+		token = s.newToken(PIPE, nil)
+	case '^':
+		token = s.newToken(CARET, nil)
+	case ';':
+		token = s.newToken(SEMICOLON, nil)
+	case '*':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(STAR_EQUAL, nil)
+		} else if s.peek() == '*' {
+			s.advance()
+			token = s.newToken(STAR_STAR, nil)
+		} else {
+			token = s.newToken(STAR, nil)
+		}
+	case '.':
+		if s.peek() == '.' && s.peekN(2) == '.' {
+			s.advance()
+			s.advance()
+			token = s.newToken(ELLIPSIS, nil)
+		} else {
+			token = s.newToken(DOT, nil)
+		}
+	case '!':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(BANG_EQUAL, nil)
+		} else {
+			token = s.newToken(BANG, nil)
+		}
+	case '=':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(EQUAL_EQUAL, nil)
+		} else {
+			token = s.newToken(EQUAL, nil)
+		}
+	case '<':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(LESS_EQUAL, nil)
+		} else if s.peek() == '<' {
+			s.advance()
+			token = s.newToken(LESS_LESS, nil)
+		} else {
+			token = s.newToken(LESS, nil)
+		}
+	case '>':
+		if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(GREATER_EQUAL, nil)
+		} else if s.peek() == '>' {
+			s.advance()
+			token = s.newToken(GREATER_GREATER, nil)
+		} else {
+			token = s.newToken(GREATER, nil)
+		}
+	case '/':
+		// line comment
+		if s.peek() == '/' {
+			for !s.isAtEnd() && s.peek() != '\n' {
+				s.advance()
+			}
+			// block comment
+		} else if s.peek() == '*' {
+			s.advance() // consume *
+			if err := s.scanBlockComment(); err != nil {
+				return nil, err
+			}
+		} else if s.peek() == '=' {
+			s.advance()
+			token = s.newToken(SLASH_EQUAL, nil)
+		} else {
+			token = s.newToken(SLASH, nil)
+		}
+	case ' ':
+	case '\r':
+	case '\t':
+	case '\n':
+		s.newLine()
+	case '"':
+		return s.scanString()
+	default:
+		if isDigit(c) {
+			return s.scanNumber()
+		} else if isAlpha(c) {
+			token = s.scanIdentifier()
+		} else {
+			return nil, fmt.Errorf("unexpected character: %c", c)
+		}
+	}
+	return token, nil
+}
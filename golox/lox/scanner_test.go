@@ -0,0 +1,391 @@
+package lox
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestScannerOverall(t *testing.T) {
+	assert := assert.New(t)
+	src := `( ) { } , . - + ; / *
+  ! != = == > >= < <=
+  identifier "string" 1.234
+  and class else func for if nil or print return super this true false var while
+`
+	scanner := NewScanner()
+	tokens, err := scanner.Scan(src)
+
+	assert.Nil(err)
+
+	expected := []*Token{
+		{LEFT_PAREN, "(", nil, 1, 1},
+		{RIGHT_PAREN, ")", nil, 1, 3},
+		{LEFT_BRACE, "{", nil, 1, 5},
+		{RIGHT_BRACE, "}", nil, 1, 7},
+		{COMMA, ",", nil, 1, 9},
+		{DOT, ".", nil, 1, 11},
+		{MINUS, "-", nil, 1, 13},
+		{PLUS, "+", nil, 1, 15},
+		{SEMICOLON, ";", nil, 1, 17},
+		{SLASH, "/", nil, 1, 19},
+		{STAR, "*", nil, 1, 21},
+		{BANG, "!", nil, 2, 3},
+		{BANG_EQUAL, "!=", nil, 2, 5},
+		{EQUAL, "=", nil, 2, 8},
+		{EQUAL_EQUAL, "==", nil, 2, 10},
+		{GREATER, ">", nil, 2, 13},
+		{GREATER_EQUAL, ">=", nil, 2, 15},
+		{LESS, "<", nil, 2, 18},
+		{LESS_EQUAL, "<=", nil, 2, 20},
+		{IDENTIFIER, "identifier", nil, 3, 3},
+		{STRING, `"string"`, "string", 3, 14},
+		{NUMBER, "1.234", Number(1.234), 3, 23},
+		{AND, "and", nil, 4, 3},
+		{CLASS, "class", nil, 4, 7},
+		{ELSE, "else", nil, 4, 13},
+		{FUNC, "func", nil, 4, 18},
+		{FOR, "for", nil, 4, 23},
+		{IF, "if", nil, 4, 27},
+		{NIL, "nil", nil, 4, 30},
+		{OR, "or", nil, 4, 34},
+		{PRINT, "print", nil, 4, 37},
+		{RETURN, "return", nil, 4, 43},
+		{SUPER, "super", nil, 4, 50},
+		{THIS, "this", nil, 4, 56},
+		{TRUE, "true", nil, 4, 61},
+		{FALSE, "false", nil, 4, 66},
+		{VAR, "var", nil, 4, 72},
+		{WHILE, "while", nil, 4, 76},
+		{EOF, "", nil, 5, 1},
+	}
+
+	for i := range tokens {
+		assert.Equal(expected[i], tokens[i])
+	}
+}
+
+func TestScannerCompoundAssignment(t *testing.T) {
+	tokens, err := NewScanner().Scan("+= -= *= /= + - * / **")
+	assert.Nil(t, err)
+
+	var types []TokenType
+	for _, token := range tokens {
+		types = append(types, token.typ)
+	}
+	assert.Equal(t, []TokenType{
+		PLUS_EQUAL, MINUS_EQUAL, STAR_EQUAL, SLASH_EQUAL,
+		PLUS, MINUS, STAR, SLASH, STAR_STAR, EOF,
+	}, types)
+}
+
+func TestScannerError(t *testing.T) {
+	t.Run("unterminated string", func(t *testing.T) {
+		scanner := NewScanner()
+		_, err := scanner.Scan(`"unterminated string`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestScannerComment(t *testing.T) {
+	t.Run("line comment", func(t *testing.T) {
+		scanner := NewScanner()
+		tokens, err := scanner.Scan(`
+      // this should be ignored
+      +
+    `)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(tokens))
+	})
+
+	// `//` is never floor division, the rest of the line is dropped
+	t.Run("line comment after operand", func(t *testing.T) {
+		tokens, err := NewScanner().Scan("7 // 2\n;")
+		assert.Nil(t, err)
+		if assert.Equal(t, 3, len(tokens)) {
+			assert.Equal(t, TokenType(NUMBER), tokens[0].typ)
+			assert.Equal(t, TokenType(SEMICOLON), tokens[1].typ)
+		}
+	})
+
+	t.Run("block comment", func(t *testing.T) {
+		scanner := NewScanner()
+		tokens, err := scanner.Scan(`
+      /*
+        /*
+           hello world
+        */
+      */
+      +
+    `)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(tokens))
+	})
+}
+
+func TestScannerColumn(t *testing.T) {
+	type pos struct {
+		lexeme       string
+		line, column int
+	}
+	positions := func(source string) []pos {
+		tokens, err := NewScanner().Scan(source)
+		assert.Nil(t, err)
+		var result []pos
+		for _, token := range tokens {
+			result = append(result, pos{token.lexeme, token.line, token.column})
+		}
+		return result
+	}
+
+	// a tab counts as a single column
+	assert.Equal(t, []pos{
+		{"var", 1, 2},
+		{"a", 1, 6},
+		{"=", 1, 8},
+		{"1", 1, 10},
+		{";", 1, 11},
+		{"", 1, 12},
+	}, positions("\tvar a = 1;"))
+
+	// columns are counted in characters rather than bytes
+	assert.Equal(t, []pos{
+		{`"你好"`, 1, 1},
+		{"+", 1, 6},
+		{"x", 1, 8},
+		{"", 1, 9},
+	}, positions(`"你好" + x`))
+
+	// a multi-line token is located at its start, columns restart after it
+	assert.Equal(t, []pos{
+		{"a", 1, 1},
+		{"\"b\nc\"", 1, 3},
+		{"d", 2, 4},
+		{"e", 3, 3},
+		{"", 3, 4},
+	}, positions("a \"b\nc\" d\n\t\te"))
+}
+
+func TestScannerErrorColumn(t *testing.T) {
+	_, err := NewScanner().Scan("var a = 1;\n  var b = #;")
+	assert.EqualError(t, err, "line 2, col 11, unexpected character: #")
+
+	_, err = NewScanner().Scan(`print "abc`)
+	assert.EqualError(t, err, "line 1, col 7, unterminated string")
+}
+
+func TestScannerEscape(t *testing.T) {
+	cases := map[string]string{
+		`"a\nb"`:           "a\nb",
+		`"a\tb"`:           "a\tb",
+		`"a\rb"`:           "a\rb",
+		`"a\\b"`:           `a\b`,
+		`"say \"hi\""`:     `say "hi"`,
+		`"\u4f60\u597D!"`:  "你好!",
+		`"\u00e9"`:         "é",
+		`"no escapes"`:     "no escapes",
+		`"\\n is newline"`: `\n is newline`,
+	}
+	for source, expected := range cases {
+		tokens, err := NewScanner().Scan(source)
+		if assert.Nil(t, err, source) {
+			assert.Equal(t, expected, tokens[0].literal, source)
+			assert.Equal(t, source, tokens[0].lexeme)
+		}
+	}
+
+	errors := map[string]string{
+		`"a\qb"`:        `line 1, col 1, unknown escape sequence '\q'`,
+		"\n  \"\\u12\"": "line 2, col 3, invalid unicode escape, expect 4 hex digits",
+		`"\u12g4"`:      "line 1, col 1, invalid unicode escape, expect 4 hex digits",
+		`"abc\`:         "line 1, col 1, unterminated string",
+	}
+	for source, msg := range errors {
+		_, err := NewScanner().Scan(source)
+		assert.EqualError(t, err, msg, source)
+	}
+}
+
+func TestScannerInterpolation(t *testing.T) {
+	tokens, err := NewScanner().Scan(`"a ${b} c ${ {"k": d}["k"] } e" "\${f}"`)
+	assert.Nil(t, err)
+	var types []TokenType
+	var literals []interface{}
+	for _, token := range tokens {
+		types = append(types, token.typ)
+		literals = append(literals, token.literal)
+	}
+	assert.Equal(t, []TokenType{
+		INTERPOLATION, IDENTIFIER, INTERPOLATION,
+		LEFT_BRACE, STRING, COLON, IDENTIFIER, RIGHT_BRACE,
+		LEFT_BRACKET, STRING, RIGHT_BRACKET,
+		STRING, STRING, EOF,
+	}, types)
+	assert.Equal(t, []interface{}{
+		"a ", nil, " c ",
+		nil, "k", nil, nil, nil,
+		nil, "k", nil,
+		" e", "${f}", nil,
+	}, literals)
+	assert.Equal(t, `} e"`, tokens[11].lexeme)
+
+	_, err = NewScanner().Scan("print 1;\n  \"a ${b")
+	assert.EqualError(t, err, "line 2, col 3, unterminated string interpolation")
+	_, err = NewScanner().Scan(`"a ${b} c`)
+	assert.EqualError(t, err, "line 1, col 7, unterminated string")
+}
+
+func TestScannerNumberLiterals(t *testing.T) {
+	cases := map[string]Number{
+		"0":                  0,
+		"007":                7,
+		"1_000_000":          1000000,
+		"3.141_592":          3.141592,
+		"1_0.5":              10.5,
+		"0xFF":               255,
+		"0Xff":               255,
+		"0x7fff_ffff":        0x7fffffff,
+		"0x1_0000_0000_0000": 1 << 48,
+		"0b1010":             10,
+		"0B1":                1,
+		"0b1111_0000":        240,
+		"1e3":                1000,
+		"2.5e-4":             0.00025,
+		"6.022E23":           6.022e23,
+		"1E+2":               100,
+		"5e0":                5,
+		"1e1_0":              1e10,
+	}
+	for source, expected := range cases {
+		tokens, err := NewScanner().Scan(source)
+		if assert.Nil(t, err, source) {
+			assert.Equal(t, TokenType(NUMBER), tokens[0].typ, source)
+			assert.Equal(t, expected, tokens[0].literal, source)
+			assert.Equal(t, source, tokens[0].lexeme)
+			assert.Equal(t, TokenType(EOF), tokens[1].typ, source)
+		}
+	}
+
+	errors := map[string]string{
+		"0x":                "line 1, col 1, expect hex digits after '0x'",
+		"0b;":               "line 1, col 1, expect binary digits after '0b'",
+		"0x_1":              "line 1, col 1, expect hex digits after '0x'",
+		"0xFG":              "line 1, col 1, invalid digit 'G' in hex literal",
+		"0b102":             "line 1, col 1, invalid digit '2' in binary literal",
+		"1__0":              "line 1, col 1, '_' must separate digits",
+		"1_":                "line 1, col 1, '_' must separate digits",
+		"var a = 10_;":      "line 1, col 9, '_' must separate digits",
+		"1.5_":              "line 1, col 1, '_' must separate digits",
+		"0b1_":              "line 1, col 1, '_' must separate digits",
+		"1e":                "line 1, col 1, expect digits in exponent",
+		"1e+":               "line 1, col 1, expect digits in exponent",
+		"print 1;\n 2.5E-;": "line 2, col 2, expect digits in exponent",
+		"1e_1":              "line 1, col 1, expect digits in exponent",
+	}
+	for source, msg := range errors {
+		_, err := NewScanner().Scan(source)
+		assert.EqualError(t, err, msg, source)
+	}
+}
+
+func TestScannerBlockComment(t *testing.T) {
+	tokens, err := NewScanner().Scan(`a /* one
+two /* nested
+*/ still comment */ b
+/**/ c /* * / */ d`)
+	assert.Nil(t, err)
+	var lexemes []string
+	var lines []int
+	for _, token := range tokens {
+		lexemes = append(lexemes, token.lexeme)
+		lines = append(lines, token.line)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", ""}, lexemes)
+	assert.Equal(t, []int{1, 3, 4, 4, 4}, lines)
+
+	_, err = NewScanner().Scan("a;\n  /* open /* nested */\n\n")
+	assert.EqualError(t, err, "line 2, col 3, unterminated block comment")
+}
+
+func TestScan(t *testing.T) {
+	tokens, err := Scan(`var s = "hi";
+if (n >= 1.5) print s + n;`)
+	assert.Nil(t, err)
+
+	expected := []struct {
+		typ     TokenType
+		lexeme  string
+		literal interface{}
+		line    int
+	}{
+		{VAR, "var", nil, 1},
+		{IDENTIFIER, "s", nil, 1},
+		{EQUAL, "=", nil, 1},
+		{STRING, `"hi"`, "hi", 1},
+		{SEMICOLON, ";", nil, 1},
+		{IF, "if", nil, 2},
+		{LEFT_PAREN, "(", nil, 2},
+		{IDENTIFIER, "n", nil, 2},
+		{GREATER_EQUAL, ">=", nil, 2},
+		{NUMBER, "1.5", Number(1.5), 2},
+		{RIGHT_PAREN, ")", nil, 2},
+		{PRINT, "print", nil, 2},
+		{IDENTIFIER, "s", nil, 2},
+		{PLUS, "+", nil, 2},
+		{IDENTIFIER, "n", nil, 2},
+		{SEMICOLON, ";", nil, 2},
+		{EOF, "", nil, 2},
+	}
+	if !assert.Len(t, tokens, len(expected)) {
+		return
+	}
+	for i, e := range expected {
+		token := tokens[i]
+		line, _ := token.Position()
+		assert.Equal(t, e.typ, token.Type(), token.String())
+		assert.Equal(t, e.lexeme, token.Lexeme(), token.String())
+		assert.Equal(t, e.literal, token.Literal(), token.String())
+		assert.Equal(t, e.line, line, token.String())
+	}
+	_, column := tokens[8].Position()
+	assert.Equal(t, 7, column)
+
+	_, err = Scan(`"unterminated`)
+	assert.EqualError(t, err, "scan error: line 1, col 1, unterminated string")
+}
+
+func TestScannerIntern(t *testing.T) {
+	scanner := NewScanner()
+	tokens, err := scanner.Scan("foo bar foo fooBar")
+	assert.Nil(t, err)
+	foo, bar, again, fooBar := tokens[0].lexeme, tokens[1].lexeme, tokens[2].lexeme, tokens[3].lexeme
+
+	assert.Equal(t, "foo", foo)
+	assert.Equal(t, foo, again)
+	assert.True(t, unsafe.StringData(foo) == unsafe.StringData(again))
+	assert.NotEqual(t, foo, bar)
+	assert.NotEqual(t, foo, fooBar)
+
+	// the table is kept across scans
+	tokens, err = scanner.Scan("foo")
+	assert.Nil(t, err)
+	assert.True(t, unsafe.StringData(foo) == unsafe.StringData(tokens[0].lexeme))
+
+	m := map[string]int{foo: 1, bar: 2}
+	assert.Equal(t, 1, m[again])
+	assert.Equal(t, 1, m["foo"])
+	_, ok := m[fooBar]
+	assert.False(t, ok)
+}
+
+func BenchmarkScan(b *testing.B) {
+	source := strings.Repeat("var total = total + count * 2; print total;\n", 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewScanner().Scan(source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
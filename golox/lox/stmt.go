@@ -0,0 +1,495 @@
+package lox
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Stmt interface {
+	Print() string // for debug
+	Resolve(r *Resolver)
+	Run(in *Interpreter)
+	compile(c *Compiler)
+	optimize(o *Optimizer) Stmt
+	// source range of the node, end is right after its last character
+	Pos() (start, end Position)
+	setPos(start, end Position)
+}
+
+/*----------  Print Stmt  ----------*/
+
+type StmtPrint struct {
+	span
+	expr Expr
+}
+
+func NewStmtPrint(expr Expr) *StmtPrint {
+	return &StmtPrint{span{}, expr}
+}
+
+func (s *StmtPrint) Print() string {
+	return parenthesize("print", s.expr)
+}
+
+/*----------  Assert Stmt  ----------*/
+
+type StmtAssert struct {
+	span
+	keyword   *Token
+	condition Expr
+	message   Expr // optional
+}
+
+func NewStmtAssert(keyword *Token, condition Expr, message Expr) *StmtAssert {
+	return &StmtAssert{span{}, keyword, condition, message}
+}
+
+func (s *StmtAssert) Print() string {
+	if s.message == nil {
+		return parenthesize("assert", s.condition)
+	}
+	return parenthesize("assert", s.condition, s.message)
+}
+
+/*----------  Import Stmt  ----------*/
+
+type StmtImport struct {
+	span
+	keyword *Token
+	path    string
+}
+
+func NewStmtImport(keyword *Token, path string) *StmtImport {
+	return &StmtImport{span{}, keyword, path}
+}
+
+func (s *StmtImport) Print() string {
+	return parenthesize("import " + strconv.Quote(s.path))
+}
+
+/*----------  Expression Stmt  ----------*/
+
+type StmtExpression struct {
+	span
+	expr Expr
+}
+
+func NewStmtExpression(expr Expr) *StmtExpression {
+	return &StmtExpression{span{}, expr}
+}
+
+func (s *StmtExpression) Print() string {
+	return parenthesize(";", s.expr)
+}
+
+/*----------  Var Decl Stmt  ----------*/
+type StmtVarDecl struct {
+	span
+	name  *Token
+	value Expr
+	// slot computed by resolver, -1 means global
+	slot int
+}
+
+func NewStmtVarDecl(name *Token, value Expr) *StmtVarDecl {
+	return &StmtVarDecl{span{}, name, value, -1}
+}
+
+func (s *StmtVarDecl) Print() string {
+	if s.value == nil {
+		return parenthesize("var " + s.name.lexeme)
+	}
+	return parenthesize("var "+s.name.lexeme, s.value)
+}
+
+/*----------  Var Unpack Stmt  ----------*/
+type StmtVarUnpack struct {
+	span
+	// open paren before names
+	paren *Token
+	names []*Token
+	value Expr
+	// slots computed by resolver, parallel to names, -1 means global
+	slots []int
+}
+
+func NewStmtVarUnpack(paren *Token, names []*Token, value Expr) *StmtVarUnpack {
+	slots := make([]int, len(names))
+	for i := range slots {
+		slots[i] = -1
+	}
+	return &StmtVarUnpack{span{}, paren, names, value, slots}
+}
+
+func (s *StmtVarUnpack) Print() string {
+	names := make([]string, len(s.names))
+	for i, name := range s.names {
+		names[i] = name.lexeme
+	}
+	return parenthesize("var ("+strings.Join(names, " ")+")", s.value)
+}
+
+/*----------  Const Decl Stmt  ----------*/
+type StmtConstDecl struct {
+	span
+	name  *Token
+	value Expr
+	// slot computed by resolver, -1 means global
+	slot int
+}
+
+func NewStmtConstDecl(name *Token, value Expr) *StmtConstDecl {
+	return &StmtConstDecl{span{}, name, value, -1}
+}
+
+func (s *StmtConstDecl) Print() string {
+	return parenthesize("const "+s.name.lexeme, s.value)
+}
+
+/*----------  Block Stmt  ----------*/
+type StmtBlock struct {
+	span
+	stmts []Stmt
+}
+
+func NewStmtBlock(stmts []Stmt) *StmtBlock {
+	return &StmtBlock{span{}, stmts}
+}
+
+func (s *StmtBlock) Print() string {
+	return parenthesizeStmts("block", s.stmts...)
+}
+
+/*----------  If Stmt  ----------*/
+type StmtIf struct {
+	span
+	condition   Expr
+	trueBranch  Stmt
+	falseBranch Stmt
+}
+
+func NewStmtIf(condition Expr, trueBranch, falseBranch Stmt) *StmtIf {
+	return &StmtIf{span{}, condition, trueBranch, falseBranch}
+}
+
+func (s *StmtIf) Print() string {
+	if s.falseBranch == nil {
+		return parenthesizeParts("if", s.condition.Print(), s.trueBranch.Print())
+	}
+	return parenthesizeParts("if", s.condition.Print(), s.trueBranch.Print(), s.falseBranch.Print())
+}
+
+/*----------  While Stmt  ----------*/
+type StmtWhile struct {
+	span
+	loopLabel
+	condition Expr
+	body      Stmt
+}
+
+func NewStmtWhile(condition Expr, body Stmt) *StmtWhile {
+	return &StmtWhile{span{}, loopLabel{}, condition, body}
+}
+
+func (s *StmtWhile) Print() string {
+	return s.printLabel(parenthesizeParts("while", s.condition.Print(), s.body.Print()))
+}
+
+/*----------  Do While Stmt  ----------*/
+// body runs once before condition is first tested
+type StmtDoWhile struct {
+	span
+	loopLabel
+	body      Stmt
+	condition Expr
+}
+
+func NewStmtDoWhile(body Stmt, condition Expr) *StmtDoWhile {
+	return &StmtDoWhile{span{}, loopLabel{}, body, condition}
+}
+
+func (s *StmtDoWhile) Print() string {
+	return s.printLabel(parenthesizeParts("do", s.body.Print(), s.condition.Print()))
+}
+
+/*----------  For Stmt  ----------*/
+// initializer, condition and increment are optional
+type StmtFor struct {
+	span
+	loopLabel
+	initializer Stmt
+	condition   Expr
+	increment   Expr
+	body        Stmt
+}
+
+func NewStmtFor(initializer Stmt, condition, increment Expr, body Stmt) *StmtFor {
+	return &StmtFor{span{}, loopLabel{}, initializer, condition, increment, body}
+}
+
+// omitted clauses are printed as `_`
+func (s *StmtFor) Print() string {
+	initializer, condition, increment := "_", "_", "_"
+	if s.initializer != nil {
+		initializer = s.initializer.Print()
+	}
+	if s.condition != nil {
+		condition = s.condition.Print()
+	}
+	if s.increment != nil {
+		increment = s.increment.Print()
+	}
+	return s.printLabel(parenthesizeParts("for", initializer, condition, increment, s.body.Print()))
+}
+
+/*----------  For Each Stmt  ----------*/
+// iterates elements of a list or keys of a map
+type StmtForEach struct {
+	span
+	loopLabel
+	name *Token
+	// `in` keyword, for error reporting
+	keyword  *Token
+	iterable Expr
+	body     Stmt
+}
+
+func NewStmtForEach(name, keyword *Token, iterable Expr, body Stmt) *StmtForEach {
+	return &StmtForEach{span{}, loopLabel{}, name, keyword, iterable, body}
+}
+
+func (s *StmtForEach) Print() string {
+	return s.printLabel(parenthesizeParts("for-in", s.name.lexeme, s.iterable.Print(), s.body.Print()))
+}
+
+/*----------  Loop Label  ----------*/
+// embedded by loop statements, `outer: while (...) {}` is labeled `outer`
+type loopLabel struct {
+	label *Token
+}
+
+func (l *loopLabel) setLabel(label *Token) {
+	l.label = label
+}
+
+// "" if there is no label
+func labelName(label *Token) string {
+	if label == nil {
+		return ""
+	}
+	return label.lexeme
+}
+
+func (l *loopLabel) printLabel(loop string) string {
+	if l.label == nil {
+		return loop
+	}
+	return parenthesizeParts("label", l.label.lexeme, loop)
+}
+
+/*----------  Break Stmt  ----------*/
+// label is nil if the innermost loop is the target
+type StmtBreak struct {
+	span
+	keyword *Token
+	label   *Token
+}
+
+func NewStmtBreak(keyword, label *Token) *StmtBreak {
+	return &StmtBreak{span{}, keyword, label}
+}
+
+func (s *StmtBreak) Print() string {
+	if s.label != nil {
+		return "(break " + s.label.lexeme + ")"
+	}
+	return "(break)"
+}
+
+/*----------  Continue Stmt  ----------*/
+// label is nil if the innermost loop is the target
+type StmtContinue struct {
+	span
+	keyword *Token
+	label   *Token
+}
+
+func NewStmtContinue(keyword, label *Token) *StmtContinue {
+	return &StmtContinue{span{}, keyword, label}
+}
+
+func (s *StmtContinue) Print() string {
+	if s.label != nil {
+		return "(continue " + s.label.lexeme + ")"
+	}
+	return "(continue)"
+}
+
+/*----------  Function Declaration Stmt  ----------*/
+type StmtFuncDecl struct {
+	span
+	// nil for anonymous function
+	name       *Token
+	parameters []*Token
+	// default value of each parameter, nil if the parameter is required
+	defaults []Expr
+	// the last parameter collects remaining arguments as a list
+	variadic bool
+	// method declared without parameter list, called on property access
+	getter bool
+	body   []Stmt
+	// 运行时赋值
+	closure *Env
+	// the node function values are copied from, identifies self-recursion
+	declaration *StmtFuncDecl
+	// slot of the name computed by resolver, -1 means global
+	slot int
+}
+
+func NewStmtFuncDecl(name *Token, parameters []*Token, defaults []Expr, variadic bool, body []Stmt) *StmtFuncDecl {
+	s := &StmtFuncDecl{span{}, name, parameters, defaults, variadic, false, body, nil, nil, -1}
+	s.declaration = s
+	return s
+}
+
+func (s *StmtFuncDecl) Print() string {
+	name := "func"
+	if s.name != nil {
+		name += " " + s.name.lexeme
+	}
+	var parameters []string
+	for i, param := range s.parameters {
+		if s.defaults[i] != nil {
+			parameters = append(parameters, param.lexeme+"="+s.defaults[i].Print())
+		} else {
+			parameters = append(parameters, param.lexeme)
+		}
+	}
+	if s.variadic {
+		parameters[len(parameters)-1] += "..."
+	}
+	if s.getter {
+		name = "getter " + s.name.lexeme
+	} else {
+		name += " (" + strings.Join(parameters, " ") + ")"
+	}
+	return parenthesizeStmts(name, s.body...)
+}
+
+/*----------  Try Stmt  ----------*/
+type StmtTry struct {
+	span
+	tryBlock []Stmt
+	// nil if there is no catch clause
+	name         *Token
+	catchBlock   []Stmt
+	finallyBlock []Stmt // nil if there is no finally clause
+}
+
+func NewStmtTry(tryBlock []Stmt, name *Token, catchBlock []Stmt, finallyBlock []Stmt) *StmtTry {
+	return &StmtTry{span{}, tryBlock, name, catchBlock, finallyBlock}
+}
+
+func (s *StmtTry) Print() string {
+	parts := []string{parenthesizeStmts("block", s.tryBlock...)}
+	if s.name != nil {
+		parts = append(parts, parenthesizeStmts("catch "+s.name.lexeme, s.catchBlock...))
+	}
+	if s.finallyBlock != nil {
+		parts = append(parts, parenthesizeStmts("finally", s.finallyBlock...))
+	}
+	return parenthesizeParts("try", parts...)
+}
+
+/*----------  Throw Stmt  ----------*/
+type StmtThrow struct {
+	span
+	keyword *Token
+	value   Expr
+}
+
+func NewStmtThrow(keyword *Token, value Expr) *StmtThrow {
+	return &StmtThrow{span{}, keyword, value}
+}
+
+func (s *StmtThrow) Print() string {
+	return parenthesize("throw", s.value)
+}
+
+/*----------  Defer Stmt  ----------*/
+type StmtDefer struct {
+	span
+	keyword *Token
+	call    *ExprCall
+	// scope distance to the body of the enclosing function, computed by
+	// resolver
+	depth int
+}
+
+func NewStmtDefer(keyword *Token, call *ExprCall) *StmtDefer {
+	return &StmtDefer{span{}, keyword, call, 0}
+}
+
+func (s *StmtDefer) Print() string {
+	return parenthesize("defer", s.call)
+}
+
+/*----------  Class Declaration Stmt  ----------*/
+type StmtClassDecl struct {
+	span
+	name       *Token
+	superclass *ExprVariable // nil if no superclass
+	methods    []*StmtFuncDecl
+	// methods declared with `class`, called on the class itself
+	classMethods []*StmtFuncDecl
+	// slot computed by resolver, -1 means global
+	slot int
+}
+
+func NewStmtClassDecl(name *Token, superclass *ExprVariable, methods []*StmtFuncDecl, classMethods []*StmtFuncDecl) *StmtClassDecl {
+	return &StmtClassDecl{span{}, name, superclass, methods, classMethods, -1}
+}
+
+func (s *StmtClassDecl) Print() string {
+	name := "class " + s.name.lexeme
+	if s.superclass != nil {
+		name += " < " + s.superclass.name.lexeme
+	}
+	parts := make([]string, 0, len(s.methods)+len(s.classMethods))
+	for _, method := range s.methods {
+		parts = append(parts, method.Print())
+	}
+	for _, method := range s.classMethods {
+		parts = append(parts, parenthesizeParts("class", method.Print()))
+	}
+	return parenthesizeParts(name, parts...)
+}
+
+/*----------  Return Stmt  ----------*/
+type StmtReturn struct {
+	span
+	token *Token
+	value Expr
+	// value is a call that can replace the current one, set by resolver
+	tail bool
+}
+
+func NewStmtReturn(token *Token, value Expr) *StmtReturn {
+	return &StmtReturn{span{}, token, value, false}
+}
+
+func (s *StmtReturn) Print() string {
+	if s.value == nil {
+		return "(return)"
+	}
+	return parenthesize("return", s.value)
+}
+
+/*----------  Helper Methods  ----------*/
+func parenthesizeStmts(name string, stmts ...Stmt) string {
+	parts := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		parts = append(parts, stmt.Print())
+	}
+	return parenthesizeParts(name, parts...)
+}
@@ -0,0 +1,207 @@
+package lox
+
+import "fmt"
+
+type TokenType string
+
+const (
+	// Single-character tokens
+	LEFT_PAREN    TokenType = "Left_Paren"    // (
+	RIGHT_PAREN             = "Right_Paren"   // )
+	LEFT_BRACE              = "Left_Brace"    // {
+	RIGHT_BRACE             = "Right_Brace"   // }
+	LEFT_BRACKET            = "Left_Bracket"  // [
+	RIGHT_BRACKET           = "Right_Bracket" // ]
+	COLON                   = "Colon"         // :
+	COMMA                   = "Comma"         // ,
+	DOT                     = "Dot"           // .
+	MINUS                   = "Minus"         // -
+	PERCENT                 = "Percent"       // %
+	PLUS                    = "Plus"          // +
+	QUESTION                = "Question"      // ?
+	SEMICOLON               = "Semicolon"     // ;
+	SLASH                   = "Slash"         // /
+	STAR                    = "Star"          // *
+	AMPERSAND               = "Ampersand"     // &
+	CARET                   = "Caret"         // ^
+	PIPE                    = "Pipe"          // |
+
+	// One or two character tokens
+	BANG              = "Bang"              // !
+	BANG_EQUAL        = "Bang_Equal"        // !=
+	EQUAL             = "Equal"             // =
+	EQUAL_EQUAL       = "Equal_Equal"       // ==
+	GREATER           = "Greater"           // >
+	GREATER_EQUAL     = "Greater_Equal"     // >=
+	GREATER_GREATER   = "Greater_Greater"   // >>
+	LESS              = "Less"              // <
+	LESS_EQUAL        = "Less_Equal"        // <=
+	LESS_LESS         = "Less_Less"         // <<
+	MINUS_EQUAL       = "Minus_Equal"       // -=
+	MINUS_MINUS       = "Minus_Minus"       // --
+	PLUS_EQUAL        = "Plus_Equal"        // +=
+	PLUS_PLUS         = "Plus_Plus"         // ++
+	QUESTION_QUESTION = "Question_Question" // ??
+	QUESTION_DOT      = "Question_Dot"      // ?.
+	SLASH_EQUAL       = "Slash_Equal"       // /=
+	STAR_EQUAL        = "Star_Equal"        // *=
+	STAR_STAR         = "Star_Star"         // **
+	ELLIPSIS          = "Ellipsis"          // ...
+
+	// Literals
+	IDENTIFIER = "Identifier"
+	STRING     = "String"
+	NUMBER     = "Number"
+
+	// part of an interpolated string before `${`, the embedded expression
+	// follows, the string goes on after the matching `}`
+	INTERPOLATION = "Interpolation"
+
+	// Keywords
+	AND      = "And"
+	ASSERT   = "Assert"
+	BREAK    = "Break"
+	CATCH    = "Catch"
+	CLASS    = "Class"
+	CONST    = "Const"
+	CONTINUE = "Continue"
+	DEFER    = "Defer"
+	DO       = "Do"
+	ELSE     = "Else"
+	FINALLY  = "Finally"
+	FUNC     = "Func"
+	FOR      = "For"
+	IF       = "If"
+	IMPORT   = "Import"
+	IN       = "In"
+	ISA      = "Isa"
+	NIL      = "Nil"
+	OR       = "Or"
+	PRINT    = "Print"
+	RETURN   = "Return"
+	SUPER    = "Super"
+	THIS     = "This"
+	THROW    = "Throw"
+	TRUE     = "True"
+	TRY      = "Try"
+	FALSE    = "False"
+	VAR      = "Var"
+	WHILE    = "While"
+
+	EOF = "EOF"
+)
+
+type Token struct {
+	typ     TokenType
+	lexeme  string
+	literal interface{} // string or number
+	line    int
+	column  int // 1-based, counted in characters
+}
+
+func (t *Token) String() string {
+	return fmt.Sprintf("[%d:%d] %s: %s (%#v)", t.line, t.column, t.typ, t.lexeme, t.literal)
+}
+
+var KeywordToken = map[string]TokenType{
+	"and":      AND,
+	"assert":   ASSERT,
+	"break":    BREAK,
+	"catch":    CATCH,
+	"class":    CLASS,
+	"const":    CONST,
+	"continue": CONTINUE,
+	"defer":    DEFER,
+	"do":       DO,
+	"else":     ELSE,
+	"false":    FALSE,
+	"finally":  FINALLY,
+	"for":      FOR,
+	"func":     FUNC,
+	"if":       IF,
+	"import":   IMPORT,
+	"in":       IN,
+	"isa":      ISA,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"throw":    THROW,
+	"true":     TRUE,
+	"try":      TRY,
+	"var":      VAR,
+	"while":    WHILE,
+}
+
+func NewToken(
+	typ TokenType,
+	lexeme string,
+	literal interface{},
+	line int,
+	column int,
+) *Token {
+	return &Token{
+		typ:     typ,
+		lexeme:  lexeme,
+		literal: literal,
+		line:    line,
+		column:  column,
+	}
+}
+
+func (t *Token) Type() TokenType {
+	return t.typ
+}
+
+func (t *Token) Lexeme() string {
+	return t.lexeme
+}
+
+// string for STRING and INTERPOLATION, Number for NUMBER, nil otherwise
+func (t *Token) Literal() interface{} {
+	return t.literal
+}
+
+// Position is where the token starts, both are 1-based
+func (t *Token) Position() (line, column int) {
+	return t.line, t.column
+}
+
+// Position is a location in source, both are 1-based
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (t *Token) start() Position {
+	return Position{t.line, t.column}
+}
+
+// position right after the last character of token
+func (t *Token) end() Position {
+	pos := t.start()
+	for _, r := range t.lexeme {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// embedded by AST nodes, set by parser
+type span struct {
+	start, end Position
+}
+
+func (s *span) Pos() (start, end Position) {
+	return s.start, s.end
+}
+
+func (s *span) setPos(start, end Position) {
+	s.start, s.end = start, end
+}
@@ -0,0 +1,125 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryCatch(t *testing.T) {
+	lox := evalSource(t, `
+var thrown;
+try {
+  throw "boom";
+} catch (e) {
+  thrown = e;
+}
+
+var runtime;
+try {
+  var a = 1;
+  a = -"x";
+} catch (e) {
+  runtime = e;
+}
+
+var nothing = "untouched";
+try {
+  var ok = 1;
+} catch (e) {
+  nothing = e;
+}
+
+func fail() { throw [1, 2]; }
+var fromFunction;
+try {
+  fail();
+} catch (e) {
+  fromFunction = e;
+}
+`)
+	assert.Equal(t, "boom", getGlobal(lox, "thrown"))
+	assert.Equal(t, "{message: operand must be a number, line: 12, column: 7}", stringify(getGlobal(lox, "runtime")))
+	assert.Equal(t, "untouched", getGlobal(lox, "nothing"))
+	assert.Equal(t, "[1, 2]", stringify(getGlobal(lox, "fromFunction")))
+}
+
+func TestRethrow(t *testing.T) {
+	lox := evalSource(t, `
+var log = [];
+try {
+  try {
+    throw "inner";
+  } catch (e) {
+    push(log, "caught " + e);
+    throw e + " again";
+  }
+} catch (e) {
+  push(log, "caught " + e);
+}
+`)
+	assert.Equal(t, "[caught inner, caught inner again]", stringify(getGlobal(lox, "log")))
+
+	err := New().Run(`
+try {
+  throw "x";
+} catch (e) {
+  throw {"code": 1};
+}`)
+	assert.EqualError(t, err, "runtime error: line 5, col 3, uncaught exception: {code: 1}")
+}
+
+func TestFinally(t *testing.T) {
+	lox := evalSource(t, `
+var log = [];
+try {
+  push(log, "try");
+} catch (e) {
+  push(log, "catch");
+} finally {
+  push(log, "finally");
+}
+
+try {
+  push(log, "try");
+  throw 1;
+  push(log, "unreachable");
+} catch (e) {
+  push(log, "catch");
+} finally {
+  push(log, "finally");
+}
+
+func early() {
+  try {
+    return "returned";
+  } finally {
+    push(log, "finally on return");
+  }
+}
+var result = early();
+
+for (var i = 0; i < 3; i += 1) {
+  try {
+    if (i == 1) break;
+  } finally {
+    push(log, i);
+  }
+}
+
+try {
+  try {
+    throw "escape";
+  } finally {
+    push(log, "finally on throw");
+  }
+} catch (e) {
+  push(log, "outer " + e);
+}
+`)
+	assert.Equal(t, "[try, finally, try, catch, finally, finally on return, 0, 1, finally on throw, outer escape]", stringify(getGlobal(lox, "log")))
+	assert.Equal(t, "returned", getGlobal(lox, "result"))
+
+	err := New().Run("try {}")
+	assert.EqualError(t, err, "parse error: line 1, col 7, at end, expect 'catch' or 'finally' after try block")
+}
@@ -0,0 +1,26 @@
+package lox
+
+import "strings"
+
+// LoxTuple holds the values of `return a, b;`, it can't be modified and is
+// unpacked by `var (a, b) = ...;`
+type LoxTuple struct {
+	elements []Val
+}
+
+func NewLoxTuple(elements []Val) *LoxTuple {
+	return &LoxTuple{elements}
+}
+
+func (t *LoxTuple) String() string {
+	return t.format(map[Val]bool{})
+}
+
+// a tuple can't contain itself, but it may contain a list which does
+func (t *LoxTuple) format(visiting map[Val]bool) string {
+	strs := make([]string, 0, len(t.elements))
+	for _, element := range t.elements {
+		strs = append(strs, stringifyVisiting(element, visiting))
+	}
+	return "(" + strings.Join(strs, ", ") + ")"
+}
@@ -0,0 +1,54 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTuple(t *testing.T) {
+	lox := evalSource(t, `
+func divmod(a, b) {
+  return floor(a / b), a % b;
+}
+var (q, r) = divmod(17, 5);
+var pair = divmod(7, 2);
+var kind = typeof(pair);
+var same = pair == divmod(10, 3) and pair != divmod(9, 4);
+var printed = string(pair);
+func swap() {
+  var (a, b) = divmod(10, 3);
+  var (x, y) = (func () { return b, a; })();
+  return x * 10 + y;
+}
+var swapped = swap();
+var nested;
+{
+  func wrap() { return [1], (func () { return "a", nil; })(); }
+  var (list, inner) = wrap();
+  var (s, n) = inner;
+  nested = string(list) + s + string(n);
+}
+`)
+	assert.Equal(t, Number(3), getGlobal(lox, "q"))
+	assert.Equal(t, Number(2), getGlobal(lox, "r"))
+	assert.Equal(t, "tuple", getGlobal(lox, "kind"))
+	assert.Equal(t, true, getGlobal(lox, "same"))
+	assert.Equal(t, "(3, 1)", getGlobal(lox, "printed"))
+	assert.Equal(t, Number(13), getGlobal(lox, "swapped"))
+	assert.Equal(t, "[1]anil", getGlobal(lox, "nested"))
+
+	errors := map[string]string{
+		"func f() { return 1, 2, 3; }\nvar (a, b) = f();":     "runtime error: line 2, col 5, expect 2 values to unpack but got 3",
+		"func f() { return 1, 2; }\n{ var (a, b, c) = f(); }": "runtime error: line 2, col 7, expect 3 values to unpack but got 2",
+		"func f() { return 1, 2; }\nvar (a) = f();":           "runtime error: line 2, col 5, expect 1 value to unpack but got 2",
+		"var (a, b) = [1, 2];":                                "runtime error: line 1, col 5, can only unpack a tuple",
+		"var (a, a) = nil;":                                   "parse error: line 1, col 9, at 'a', variable 'a' is unpacked more than once",
+		"var (a, b);":                                         "parse error: line 1, col 11, at ';', expect '=' after variable names, unpacked variables must be initialized",
+		"var (a, 1) = nil;":                                   "parse error: line 1, col 9, at '1', expect variable name",
+		"{ var a; var (b, a) = nil; }":                        "resolve error: line 1, col 18, at 'a', 'a' is already declared in this scope at line 1",
+	}
+	for source, msg := range errors {
+		assert.EqualError(t, New().Run(source), msg, source)
+	}
+}
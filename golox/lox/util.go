@@ -1,4 +1,4 @@
-package main
+package lox
 
 import "fmt"
 
@@ -8,6 +8,14 @@ func isDigit(r rune) bool {
 	return r >= '0' && r <= '9'
 }
 
+func isBinaryDigit(r rune) bool {
+	return r == '0' || r == '1'
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
 func isAlpha(c rune) bool {
 	return (c >= 'a' && c <= 'z') ||
 		(c >= 'A' && c <= 'Z') ||
@@ -0,0 +1,101 @@
+package lox
+
+import "fmt"
+
+// VM runs bytecode produced by Compiler on a value stack, globals, builtins
+// and output are shared with the interpreter it belongs to
+type VM struct {
+	in    *Interpreter
+	stack []Val
+}
+
+func NewVM(in *Interpreter) *VM {
+	return &VM{in: in}
+}
+
+// execution halts at the first runtime error, which is returned, errors
+// are the same as the interpreter would raise
+func (vm *VM) Run(chunk *Chunk) (err error) {
+	vm.stack = vm.stack[:0]
+	defer vm.in.recoverRuntime(&err)
+
+	for ip := 0; ip < len(chunk.code); ip++ {
+		ins := chunk.code[ip]
+		switch ins.op {
+		case OpConstant:
+			vm.push(chunk.constants[ins.operand])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpDup:
+			vm.push(vm.peek())
+		case OpDefineGlobal:
+			vm.in.globals.Define(ins.token.lexeme, vm.pop())
+		case OpGetGlobal:
+			vm.push(vm.in.globals.Get(ins.token))
+		case OpSetGlobal:
+			vm.in.globals.Set(ins.token, vm.peek())
+		case OpGetLocal:
+			vm.push(vm.stack[ins.operand])
+		case OpSetLocal:
+			vm.stack[ins.operand] = vm.peek()
+		case OpNegate, OpNot:
+			vm.push(unary(ins.token, vm.pop()))
+		case OpIncrement, OpDecrement:
+			vm.push(increment(ins.token, vm.pop()))
+		case OpAdd, OpSubtract, OpMultiply, OpDivide, OpModulo, OpPower,
+			OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual,
+			OpBitAnd, OpBitOr, OpBitXor, OpShiftLeft, OpShiftRight, OpIsa:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(vm.in.binary(ins.token, left, right))
+		case OpJump:
+			// the loop increments ip
+			ip = ins.operand - 1
+		case OpJumpIfFalse:
+			if !getTruthy(vm.peek()) {
+				ip = ins.operand - 1
+			}
+		case OpJumpIfNotNil:
+			if vm.peek() != nil {
+				ip = ins.operand - 1
+			}
+		case OpCall:
+			call := chunk.calls[ins.operand]
+			n := len(call.arguments)
+			arguments := make([]Val, n)
+			copy(arguments, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(call.call(vm.in, vm.pop(), arguments))
+		case OpInterpolate:
+			n := ins.operand
+			s := interpolate(vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(s)
+		case OpPrint:
+			fmt.Fprintln(vm.in.stdout, stringify(vm.pop()))
+		default:
+			panic(sprintf("unknown op code %d", ins.op))
+		}
+	}
+	return nil
+}
+
+func (vm *VM) push(val Val) {
+	vm.stack = append(vm.stack, val)
+}
+
+func (vm *VM) pop() Val {
+	val := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return val
+}
+
+func (vm *VM) peek() Val {
+	return vm.stack[len(vm.stack)-1]
+}
@@ -0,0 +1,257 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run source with the interpreter and VM, return output and error of both
+func runBoth(source string) (outputs []string, errs []error) {
+	for _, useVM := range []bool{false, true} {
+		var buf bytes.Buffer
+		lox := New(UseVM(useVM))
+		lox.SetOutput(&buf)
+		err := lox.Run(source)
+		outputs = append(outputs, buf.String())
+		errs = append(errs, err)
+	}
+	return
+}
+
+func TestVMMatchesInterpreter(t *testing.T) {
+	programs := map[string]string{
+		"arithmetic": `
+print 1 + 2 * 3 - 4 / 2;
+print 7 % 3;
+print 2 ** 3 ** 2;
+print -(1 + 2);
+print 6 & 3, 6 | 3, 6 ^ 3, 1 << 4, 256 >> 2;
+print "foo" + "bar";
+print "${1 + 2} is ${"three"}";
+`,
+		"comparison and logic": `
+print 1 < 2, 2 <= 1, "a" > "b", 3 >= 3;
+print 1 == 1, [1] == [1], nil != false;
+print !nil, !0;
+print nil or "default", 1 and 2, false and undefined, true or undefined;
+print 1 > 2 ? "yes" : "no";
+print nil ?? "default", false ?? 1, 0 ?? undefined, nil ?? nil ?? 3;
+`,
+		"globals": `
+var a = 1;
+var b;
+print b;
+a = a + 1;
+a += 10;
+print a;
+print a++, a, --a;
+var a = "redefined";
+print a;
+`,
+		"locals": `
+var a = "global";
+{
+  var a = "outer";
+  {
+    var a = a + " inner";
+    print a;
+  }
+  var b = 1;
+  b = b + 1;
+  print a, b;
+  var c = b * 10;
+  print c;
+}
+print a;
+`,
+		"if": `
+if (1 < 2) print "then"; else print "else";
+if (nil) print "then"; else print "else";
+if (false) print "no else";
+`,
+		"while": `
+var i = 0;
+var sum = 0;
+while (i < 10) {
+  i++;
+  if (i % 2 == 0) continue;
+  if (i > 7) break;
+  var odd = i;
+  sum += odd;
+}
+print i, sum;
+`,
+		"do while": `
+var n = 0;
+do {
+  var m = n;
+  n = m + 1;
+} while (n < 0);
+print n;
+`,
+		"for": `
+var total = 0;
+for (var i = 0; i < 5; i++) {
+  for (var j = 0; j < 5; j++) {
+    if (j == i) break;
+    if (j % 2 == 1) continue;
+    total += j;
+  }
+}
+print total;
+for (;;) {
+  var x = 1;
+  { var y = 2; break; }
+}
+print "done";
+`,
+		"labeled loops": `
+var found = 0;
+outer: for (var i = 0; i < 5; i++) {
+  var k = i;
+  inner: for (var j = 0; j < 5; j++) {
+    var m = j;
+    if (m == 1) continue inner;
+    if (k * m == 6) { found = k * 10 + m; break outer; }
+    if (m > k) continue outer;
+  }
+}
+print found;
+var n = 0;
+a: while (n < 4) {
+  n++;
+  do { var d = n; if (d % 2 == 0) continue a; } while (false);
+  print n;
+}
+`,
+		"native calls": `
+print len("hello"), substr("hello", 1, 3);
+print typeof(1), string(2) + "!";
+println("via println");
+print max(1, 5, 3);
+`,
+		"runtime error": `
+print "before";
+var a = 1;
+{
+  var b = "x";
+  print a - b;
+}
+print "after";
+`,
+		"undefined variable": `
+print missing;
+`,
+		"native error": `
+len(1);
+`,
+		"arity error": `
+substr("a");
+`,
+	}
+
+	for name, source := range programs {
+		outputs, errs := runBoth(source)
+		assert.Equal(t, outputs[0], outputs[1], name)
+		if errs[0] == nil {
+			assert.Nil(t, errs[1], name)
+		} else {
+			assert.EqualError(t, errs[1], errs[0].Error(), name)
+		}
+	}
+}
+
+func TestVMCompileError(t *testing.T) {
+	lox := New(UseVM(true))
+	err := lox.Run("var a = 1;\nfunc f() {}")
+	assert.EqualError(t, err, "compile error: line 2, col 1, vm doesn't support functions yet")
+	assert.Equal(t, exitCodeStaticError, lox.ExitCode())
+	// nothing runs if compiling fails
+	_, ok := lox.GetGlobal("a")
+	assert.False(t, ok)
+
+	assert.EqualError(
+		t,
+		New(UseVM(true)).Run("var l;\nprint l[0];"),
+		"compile error: line 2, col 7, vm doesn't support indexing yet",
+	)
+	assert.EqualError(
+		t,
+		New(UseVM(true)).Run("var o;\no.a++;"),
+		"compile error: line 2, col 1, vm doesn't support updating properties and elements yet",
+	)
+}
+
+func TestVMGlobals(t *testing.T) {
+	lox := New(UseVM(true))
+	assert.Nil(t, lox.Run("var answer = 6 * 7;"))
+	// globals persist across runs and backends
+	lox.useVM = false
+	assert.Nil(t, lox.Run("func double(n) { return n * 2; }"))
+	lox.useVM = true
+	assert.Nil(t, lox.Run("var doubled = double(answer);"))
+	val, _ := lox.GetGlobal("doubled")
+	assert.Equal(t, Number(84), val)
+}
+
+func TestChunkDisassemble(t *testing.T) {
+	tokens, _ := NewScanner().Scan(`
+var a = 1;
+{
+  var b = a;
+  while (b < 3) b++;
+  print len("ab");
+}
+`)
+	program, err := NewParser().Parse(tokens)
+	assert.Nil(t, err)
+	chunk, err := NewCompiler().Compile(program)
+	assert.Nil(t, err)
+	assert.Equal(t, `0000 OpConstant 0 (1)
+0001 OpDefineGlobal a
+0002 OpGetGlobal a
+0003 OpGetLocal 0
+0004 OpConstant 1 (3)
+0005 OpLess
+0006 OpJumpIfFalse 15
+0007 OpPop
+0008 OpGetLocal 0
+0009 OpDup
+0010 OpIncrement
+0011 OpSetLocal 0
+0012 OpPop
+0013 OpPop
+0014 OpJump 3
+0015 OpPop
+0016 OpGetGlobal len
+0017 OpConstant 2 (ab)
+0018 OpCall 1
+0019 OpPrint
+0020 OpPop`, chunk.Disassemble())
+}
+
+func BenchmarkLoop(b *testing.B) {
+	source := `
+var sum = 0;
+for (var i = 0; i < 10000; i++) {
+  if (i % 3 == 0) continue;
+  sum = sum + i * 2;
+}
+`
+	for _, useVM := range []bool{false, true} {
+		name := "interpreter"
+		if useVM {
+			name = "vm"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := New(UseVM(useVM)).Run(source); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -5,15 +5,34 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/fate-lovely/golox/lox"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	scriptPath string
+	scriptPath    string
+	printAst      bool
+	check         bool
+	warnUnused    bool
+	warnDiscarded bool
+	maxDepth      int
+	trace         bool
+	useVM         bool
+	optimize      bool
+	errorFormat   string
 )
 
 func parseFlags() {
 	kingpin.Arg("script", "specify script path, if none, start REPL").StringVar(&scriptPath)
+	kingpin.Flag("ast", "print AST of the script instead of executing it").BoolVar(&printAst)
+	kingpin.Flag("check", "report static errors of the script without executing it").BoolVar(&check)
+	kingpin.Flag("warn-unused", "report local variables that are never used").BoolVar(&warnUnused)
+	kingpin.Flag("warn-discarded", "report expression statements that have no effect").BoolVar(&warnDiscarded)
+	kingpin.Flag("max-depth", "maximum call depth before reporting stack overflow").Default("1000").IntVar(&maxDepth)
+	kingpin.Flag("trace", "print statements and expressions as they are evaluated").BoolVar(&trace)
+	kingpin.Flag("vm", "compile the script to bytecode and run it on a virtual machine").BoolVar(&useVM)
+	kingpin.Flag("optimize", "fold constant expressions before running the script").BoolVar(&optimize)
+	kingpin.Flag("errors", "format of reported errors, json objects are written to stderr").Default("text").EnumVar(&errorFormat, "text", "json")
 	kingpin.CommandLine.HelpFlag.Short('h')
 	kingpin.Parse()
 }
@@ -21,18 +40,63 @@ func parseFlags() {
 func main() {
 	parseFlags()
 
-	lox := NewLox()
+	sink := lox.NewTextSink(os.Stdout)
+	if errorFormat == "json" {
+		sink = lox.NewJSONSink(os.Stderr)
+	}
+
+	options := []lox.Option{
+		lox.WarnUnused(warnUnused),
+		lox.WarnDiscarded(warnDiscarded),
+		lox.MaxCallDepth(maxDepth),
+		lox.Trace(trace),
+		lox.UseVM(useVM),
+		lox.Optimize(optimize),
+		lox.ReportErrors(sink),
+	}
+	// relative imports are resolved against the directory of the script
+	if scriptPath != "" {
+		options = append(options, lox.Script(scriptPath))
+	}
+	in := lox.New(options...)
 
 	if scriptPath == "" {
-		lox.REPL()
+		in.REPL(os.Stdin, os.Stdout)
+		os.Exit(in.ExitCode())
 	} else {
 		buf, err := ioutil.ReadFile(scriptPath)
 		if err != nil {
 			fmt.Printf("could not open file: %v\n", err)
 			os.Exit(1)
 		}
-		if err := lox.Eval(string(buf)); err != nil {
-			fmt.Println(err)
+		if printAst {
+			ast, err := in.PrintAst(string(buf))
+			if err != nil {
+				sink.Report(err)
+				os.Exit(in.ExitCode())
+			}
+			fmt.Println(ast)
+		} else if check {
+			err := in.Check(string(buf))
+			printWarnings(in)
+			if err != nil {
+				sink.Report(err)
+				os.Exit(in.ExitCode())
+			}
+		} else {
+			err := in.Run(string(buf))
+			printWarnings(in)
+			if err != nil {
+				sink.Report(err)
+			}
+			// also set by `exit` without an error
+			os.Exit(in.ExitCode())
 		}
 	}
 }
+
+func printWarnings(in *lox.Interpreter) {
+	for _, warning := range in.Warnings() {
+		fmt.Fprintln(os.Stderr, "warning: "+warning)
+	}
+}